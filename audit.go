@@ -0,0 +1,92 @@
+package migrate
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/pkg/errors"
+)
+
+/*
+执行者审计，历史表记录每次执行的主机名、应用版本及操作人，满足审计及合规要求；
+升级前创建的历史表缺少审计字段，创建历史表时自动补充
+*/
+
+const auditColumn = "hostname"
+
+// AuditDialect 支持在历史表记录执行者的方言
+type AuditDialect interface {
+	HistoryDialect
+	// AddAuditColumns 为升级前创建的历史表补充 hostname、app_version、operator 字段
+	AddAuditColumns(table string) []string
+	// InsertAuditHistory 插入含执行者的历史记录，参数为 InsertHistory 的参数及 hostname、app_version、operator
+	InsertAuditHistory(table string) string
+}
+
+func (f *formatDialect) AddAuditColumns(table string) []string {
+	queries := make([]string, 0, len(f.addAuditColumns))
+	for _, query := range f.addAuditColumns {
+		queries = append(queries, fmt.Sprintf(query, table))
+	}
+	return queries
+}
+
+func (f *formatDialect) InsertAuditHistory(table string) string {
+	return fmt.Sprintf(f.insertAuditHistory, table)
+}
+
+// WithAppVersion 指定记录到历史表的应用版本，如部署的镜像版本或 git 提交
+func WithAppVersion(version string) Option {
+	return func(m *migrate) {
+		m.appVersion = version
+	}
+}
+
+// WithOperator 指定记录到历史表的操作人，如发起部署的用户或流水线
+func WithOperator(operator string) Option {
+	return func(m *migrate) {
+		m.operator = operator
+	}
+}
+
+// auditDialect 返回支持审计字段的方言，不支持时返回 nil
+func (m *migrate) auditDialect() AuditDialect {
+	dialect, ok := m.dialect.(AuditDialect)
+	if !ok || dialect.InsertAuditHistory(m.historyTable) == "" {
+		return nil
+	}
+	return dialect
+}
+
+// addAuditColumns 历史表缺少审计字段时补充
+func (m *migrate) addAuditColumns(ctx context.Context) error {
+	dialect := m.auditDialect()
+	if dialect == nil {
+		return nil
+	}
+	table := m.qualify(m.historyTable)
+	// 1.查询历史表的字段，不返回记录
+	rows, err := m.db.QueryContext(ctx, fmt.Sprintf(tableProbe, table))
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	columns, err := rows.Columns()
+	rows.Close()
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	for _, column := range columns {
+		if column == auditColumn {
+			return nil
+		}
+	}
+	// 2.补充审计字段
+	m.logger.Info("add audit columns", "table", m.historyTable)
+	for _, query := range dialect.AddAuditColumns(table) {
+		_, err = m.db.ExecContext(ctx, query)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+	}
+	return nil
+}
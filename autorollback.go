@@ -0,0 +1,108 @@
+package migrate
+
+import (
+	"context"
+	"time"
+)
+
+/*
+失败自动回滚，处理程序执行失败且支持回滚时立即执行其回滚，version 保持为执行前的值且不标记 dirty，减少部署失败时的人工介入；
+失败的处理程序可能只执行了一部分，回滚语句需可在部分执行后执行，如 DROP TABLE IF EXISTS；回滚失败时标记 dirty
+*/
+
+// WithAutoRollback 处理程序执行失败且实现 DownHandler 时立即执行回滚，version 保持为执行前的值而不标记 dirty，
+// 执行仍以失败结束，返回的 HandlerError 中 RolledBack 为 true
+func WithAutoRollback() Option {
+	return func(m *migrate) {
+		m.autoRollback = true
+	}
+}
+
+// canRollback 判断失败的处理程序是否可自动回滚，单事务模式及调用方传入的事务中由事务回滚；
+// 处理程序索引是否大于 version 在 rollback 中以存储的 version 判断
+func (m *migrate) canRollback(handler Handler, direction string) bool {
+	if !m.autoRollback || direction != DirectionUp || m.tx != nil || m.singleTx {
+		return false
+	}
+	_, ok := handler.(DownHandler)
+	return ok
+}
+
+// rollback 记录失败历史后执行处理程序的回滚，将 version 恢复为存储中执行前的值，回滚失败时标记 dirty；
+// 处理程序索引不大于存储中的 version 时不回滚，标记 dirty
+func (m *migrate) rollback(ctx context.Context, handler Handler, h history, version int, cause *HandlerError) error {
+	// 处理程序可能因超时失败，回滚不受 ctx 取消影响
+	ctx = context.WithoutCancel(ctx)
+	// 1.执行失败时 version 未更新，存储中的 version 即为执行前的值；
+	// 重新执行或乱序执行的处理程序已计入 version，回滚后 version 与表结构不一致，改为标记 dirty
+	previous, _, err := m.store.GetVersion(ctx)
+	if err != nil {
+		return err
+	}
+	if handler.GetIndex() <= previous {
+		return m.fail(ctx, h, version, cause)
+	}
+	m.logger.Error("handler failed", "index", h.version, "name", h.name, "direction", h.direction, "duration", h.duration, "error", cause)
+	err = m.recordHistory(ctx, h)
+	if err != nil {
+		return err
+	}
+	// 2.执行回滚
+	down, err := m.runDown(ctx, handler, previous)
+	if err != nil {
+		// 回滚失败时以回滚中的处理程序标记 dirty，由 fail 触发 OnError
+		return m.fail(ctx, down, handler.GetIndex(), handlerError(handler, DirectionDown, err))
+	}
+	// 3.记录回滚历史
+	m.logger.Warn("auto rollback finish", "index", down.version, "name", down.name, "version", previous, "duration", down.duration)
+	err = m.recordHistory(ctx, down)
+	if err != nil {
+		return err
+	}
+	// 4.回滚成功后触发 OnError，version 为回滚后的 version
+	cause.RolledBack = true
+	m.onError(ctx, HandlerEvent{Index: h.version, Name: h.name, Direction: h.direction, Version: previous, Duration: h.duration,
+		RolledBack: true}, cause)
+	return cause
+}
+
+// rollbackAfter 回滚并发批次中首个失败之后失败的处理程序，version 已由首个失败确定，不再更新 version 及标记 dirty；
+// 回滚失败时仅记录日志及回滚历史，同批次执行结束时返回首个失败
+func (m *migrate) rollbackAfter(ctx context.Context, handler Handler, h history, version int, cause *HandlerError) error {
+	ctx = context.WithoutCancel(ctx)
+	m.logger.Error("handler failed", "index", h.version, "name", h.name, "direction", h.direction, "duration", h.duration, "error", cause)
+	err := m.recordHistory(ctx, h)
+	if err != nil {
+		return err
+	}
+	down, err := m.runDown(ctx, handler, version)
+	if err != nil {
+		m.logger.Error("auto rollback failed", "index", down.version, "name", down.name, "duration", down.duration, "error", err)
+		return m.recordHistory(ctx, down)
+	}
+	m.logger.Warn("auto rollback finish", "index", down.version, "name", down.name, "version", version, "duration", down.duration)
+	err = m.recordHistory(ctx, down)
+	if err != nil {
+		return err
+	}
+	cause.RolledBack = true
+	m.onError(ctx, HandlerEvent{Index: h.version, Name: h.name, Direction: h.direction, Version: version, Duration: h.duration,
+		RolledBack: true}, cause)
+	return nil
+}
+
+// runDown 执行失败的处理程序的回滚，version 为回滚后的 version，返回回滚的历史记录
+func (m *migrate) runDown(ctx context.Context, handler Handler, version int) (history, error) {
+	m.logger.Warn("auto rollback start", "index", handler.GetIndex(), "name", nameOf(handler), "version", version)
+	start := time.Now()
+	handlerCtx, cancel := m.handlerContext(ctx, handler)
+	err := m.applyWithRetry(handlerCtx, handler, DirectionDown, version)
+	cancel()
+	return newHistory(handler, DirectionDown, start, err), err
+}
+
+// rolledBack 判断错误是否为已自动回滚的处理程序失败
+func rolledBack(err error) bool {
+	e, ok := err.(*HandlerError)
+	return ok && e.RolledBack
+}
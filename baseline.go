@@ -0,0 +1,53 @@
+package migrate
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+/*
+基线，将已有数据库中早已执行过的处理程序标记为已执行而不实际执行，便于在存量数据库上引入
+*/
+
+const (
+	ErrBaselineConflictFormat = "schema version is %d, conflict with baseline version %d"
+)
+
+func (m *migrate) Baseline(ctx context.Context, version int) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return m.operate(ctx, "migrate.Baseline", func(ctx context.Context) error {
+		return m.baseline(ctx, version)
+	})
+}
+
+// baseline 将版本设置为 version，并为其间的处理程序记录执行历史，仅允许在未执行过或版本相同时调用
+func (m *migrate) baseline(ctx context.Context, version int) error {
+	handlers, schema, err := m.prepare(ctx)
+	if err != nil {
+		return err
+	}
+	if schema.version != 0 && schema.version != version {
+		return errors.Errorf(ErrBaselineConflictFormat, schema.version, version)
+	}
+	pos := searchPending(handlers, version)
+	if version != 0 && (pos == 0 || handlers[pos-1].GetIndex() != version) {
+		return errors.Errorf(ErrVersionNotFoundFormat, version)
+	}
+	err = m.store.SetVersion(ctx, version)
+	if err != nil {
+		return err
+	}
+	// 记录被跳过的处理程序，用于后续的校验和校验
+	now := time.Now()
+	for _, handler := range handlers[searchPending(handlers, schema.version):pos] {
+		err = m.recordHistory(ctx, newHistory(handler, DirectionUp, now, nil))
+		if err != nil {
+			return err
+		}
+	}
+	m.logger.Info("baseline schema", "version", version)
+	return nil
+}
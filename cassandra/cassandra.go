@@ -0,0 +1,151 @@
+package cassandra
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/fs"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gocql/gocql"
+	"github.com/pkg/errors"
+
+	"powerlaw.ai/powerlib/migrate"
+	"powerlaw.ai/powerlib/migrate/concrete"
+)
+
+/*
+Cassandra/ScyllaDB 执行器，读取 CQL 文件并按分号拆分后逐条执行，用于版本化管理 keyspace、表及物化视图的变更；
+CQL 不支持事务，文件执行失败时已执行的语句不会回滚，版本记录及迁移锁见 NewStore
+*/
+
+// filePattern CQL 文件名，如 0001_users.cql、0001_users.up.cql 及 0001_users.down.cql
+var filePattern = regexp.MustCompile(`^(\d+)_(.+?)(\.up|\.down)?\.cql$`)
+
+// executor CQL 执行器
+type executor struct {
+	session *gocql.Session
+	fsys    fs.FS
+}
+
+// NewExecutor 创建读取 fsys 根目录 CQL 文件的执行器，语句在 session 上执行
+func NewExecutor(session *gocql.Session, fsys fs.FS) migrate.Executor {
+	return &executor{session: session, fsys: fsys}
+}
+
+func (e *executor) ListHandlers() ([]migrate.Handler, error) {
+	entries, err := fs.ReadDir(e.fsys, ".")
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	byIndex := make(map[int]*handler)
+	for _, entry := range entries {
+		match := filePattern.FindStringSubmatch(entry.Name())
+		if entry.IsDir() || match == nil {
+			continue
+		}
+		index, err := strconv.Atoi(match[1])
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		content, err := fs.ReadFile(e.fsys, entry.Name())
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		h, ok := byIndex[index]
+		if !ok {
+			h = &handler{session: e.session, index: index, name: match[2]}
+			byIndex[index] = h
+		}
+		if match[3] == ".down" {
+			h.down, h.downFile = string(content), entry.Name()
+		} else {
+			h.up, h.upFile = string(content), entry.Name()
+		}
+	}
+	indexes := make([]int, 0, len(byIndex))
+	for index := range byIndex {
+		indexes = append(indexes, index)
+	}
+	sort.Ints(indexes)
+	handlers := make([]migrate.Handler, 0, len(indexes))
+	for _, index := range indexes {
+		h := byIndex[index]
+		if h.downFile != "" {
+			handlers = append(handlers, &downHandler{handler: h})
+			continue
+		}
+		handlers = append(handlers, h)
+	}
+	return handlers, nil
+}
+
+// handler 单个 CQL 文件的处理程序
+type handler struct {
+	session  *gocql.Session
+	index    int
+	name     string
+	up       string
+	upFile   string
+	down     string
+	downFile string // 回滚文件名，为空时不支持回滚
+}
+
+func (h *handler) GetIndex() int {
+	return h.index
+}
+
+func (h *handler) GetName() string {
+	return h.name
+}
+
+func (h *handler) Exec(ctx context.Context) error {
+	return execCQL(ctx, h.session, h.upFile, h.up)
+}
+
+// GetQuery 返回 CQL 文件内容，用于 dry-run
+func (h *handler) GetQuery() string {
+	return h.up
+}
+
+// GetSource 返回 CQL 文件名
+func (h *handler) GetSource(direction string) string {
+	if direction == migrate.DirectionDown {
+		return h.downFile
+	}
+	return h.upFile
+}
+
+// GetChecksum 返回 CQL 文件内容的 sha256
+func (h *handler) GetChecksum() string {
+	sum := sha256.Sum256([]byte(h.up))
+	return hex.EncodeToString(sum[:])
+}
+
+type downHandler struct {
+	*handler
+}
+
+func (h *downHandler) DownExec(ctx context.Context) error {
+	return execCQL(ctx, h.session, h.downFile, h.down)
+}
+
+func (h *downHandler) GetDownQuery() string {
+	return h.down
+}
+
+// execCQL 逐条执行 CQL 语句，DDL 由 gocql 等待集群 schema 一致后返回
+func execCQL(ctx context.Context, session *gocql.Session, file, content string) error {
+	n := 0
+	return concrete.StreamStatements(strings.NewReader(content), func(query string, line int) error {
+		n++
+		err := session.Query(query).WithContext(ctx).Exec()
+		if err != nil {
+			return errors.WithStack(concrete.NewStatementError(file, n, line, query, err))
+		}
+		return nil
+	})
+}
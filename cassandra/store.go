@@ -0,0 +1,143 @@
+package cassandra
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/gocql/gocql"
+	"github.com/pkg/errors"
+
+	"powerlaw.ai/powerlib/migrate"
+)
+
+/*
+版本存储，version 及 dirty 记录于 keyspace 中的概要表，迁移锁基于轻量级事务 (LWT) 的 INSERT IF NOT EXISTS，
+锁记录带有 TTL，持有锁的进程异常退出后锁在 TTL 后自动释放
+*/
+
+const (
+	defaultTable     = "schema_migrations"
+	defaultLockTTL   = 10 * time.Minute
+	lockPollInterval = time.Second
+	versionID        = "version"
+	lockID           = "lock"
+)
+
+// store 基于 keyspace 概要表的版本存储
+type store struct {
+	session  *gocql.Session
+	keyspace string
+	table    string
+	lockTTL  time.Duration
+	owner    string // 锁持有者标识，释放时校验
+}
+
+type StoreOption func(s *store)
+
+// NewStore 创建记录于 keyspace 概要表的版本存储，配合 migrate.WithSchemaStore 使用
+func NewStore(session *gocql.Session, keyspace string, options ...StoreOption) migrate.SchemaStore {
+	s := &store{
+		session:  session,
+		keyspace: keyspace,
+		table:    defaultTable,
+		lockTTL:  defaultLockTTL,
+	}
+	for _, option := range options {
+		option(s)
+	}
+	hostname, _ := os.Hostname()
+	s.owner = fmt.Sprintf("%s-%d-%d", hostname, os.Getpid(), time.Now().UnixNano())
+	return s
+}
+
+// WithTable 指定概要表名，锁表为表名加 _lock，默认为 schema_migrations
+func WithTable(table string) StoreOption {
+	return func(s *store) {
+		s.table = table
+	}
+}
+
+// WithLockTTL 指定锁记录的 TTL，需大于最长的迁移耗时，默认为 10 分钟
+func WithLockTTL(ttl time.Duration) StoreOption {
+	return func(s *store) {
+		s.lockTTL = ttl
+	}
+}
+
+func (s *store) tableName() string {
+	return s.keyspace + "." + s.table
+}
+
+func (s *store) lockTableName() string {
+	return s.keyspace + "." + s.table + "_lock"
+}
+
+func (s *store) Init(ctx context.Context) error {
+	for _, query := range []string{
+		fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (id text PRIMARY KEY, version int, dirty boolean)", s.tableName()),
+		fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (id text PRIMARY KEY, owner text)", s.lockTableName()),
+	} {
+		err := s.session.Query(query).WithContext(ctx).Exec()
+		if err != nil {
+			return errors.WithStack(err)
+		}
+	}
+	return nil
+}
+
+func (s *store) GetVersion(ctx context.Context) (int, bool, error) {
+	var version int
+	var dirty bool
+	err := s.session.Query(fmt.Sprintf("SELECT version, dirty FROM %s WHERE id = ?", s.tableName()), versionID).
+		WithContext(ctx).Consistency(gocql.Quorum).Scan(&version, &dirty)
+	if err != nil && !errors.Is(err, gocql.ErrNotFound) {
+		return 0, false, errors.WithStack(err)
+	}
+	return version, dirty, nil
+}
+
+func (s *store) SetVersion(ctx context.Context, version int) error {
+	err := s.session.Query(fmt.Sprintf("UPDATE %s SET version = ? WHERE id = ?", s.tableName()), version, versionID).
+		WithContext(ctx).Consistency(gocql.Quorum).Exec()
+	return errors.WithStack(err)
+}
+
+func (s *store) SetDirty(ctx context.Context, version int, dirty bool) error {
+	err := s.session.Query(fmt.Sprintf("UPDATE %s SET version = ?, dirty = ? WHERE id = ?", s.tableName()), version, dirty, versionID).
+		WithContext(ctx).Consistency(gocql.Quorum).Exec()
+	return errors.WithStack(err)
+}
+
+// Lock 以 LWT 写入锁记录，已被其他进程持有时轮询直至获取或 ctx 结束
+func (s *store) Lock(ctx context.Context) error {
+	// 锁表在首次执行前可能不存在
+	err := s.Init(ctx)
+	if err != nil {
+		return err
+	}
+	query := fmt.Sprintf("INSERT INTO %s (id, owner) VALUES (?, ?) IF NOT EXISTS USING TTL ?", s.lockTableName())
+	for {
+		applied, err := s.session.Query(query, lockID, s.owner, int(s.lockTTL.Seconds())).
+			WithContext(ctx).MapScanCAS(map[string]interface{}{})
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		if applied {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return errors.WithStack(ctx.Err())
+		case <-time.After(lockPollInterval):
+		}
+	}
+}
+
+// Unlock 删除本进程持有的锁记录
+func (s *store) Unlock(ctx context.Context) error {
+	_, err := s.session.Query(fmt.Sprintf("DELETE FROM %s WHERE id = ? IF owner = ?", s.lockTableName()), lockID, s.owner).
+		WithContext(ctx).MapScanCAS(map[string]interface{}{})
+	return errors.WithStack(err)
+}
@@ -0,0 +1,86 @@
+package migrate
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+)
+
+/*
+校验和校验，已执行的处理程序内容被修改时拒绝执行，避免环境间产生偏差
+*/
+
+// checksumOf 返回处理程序的校验和，未实现 Checksummer 时返回空
+func checksumOf(handler Handler) string {
+	if c, ok := handler.(Checksummer); ok {
+		return c.GetChecksum()
+	}
+	return ""
+}
+
+// verifyChecksums 校验已执行处理程序的校验和与历史记录一致，记录或当前校验和为空及基线时跳过
+func (m *migrate) verifyChecksums(ctx context.Context, applied []Handler) error {
+	histories, err := m.loadHistory(ctx)
+	if err != nil {
+		return err
+	}
+	records := lastApplied(histories)
+	for _, handler := range applied {
+		record, ok := records[handler.GetIndex()]
+		checksum := checksumOf(handler)
+		// 基线的内容与压缩前已执行的处理程序不同
+		if !ok || record.checksum == "" || checksum == "" || isBaseline(handler) {
+			continue
+		}
+		if record.checksum != checksum {
+			return errors.WithStack(&ChecksumMismatchError{Index: handler.GetIndex(), Recorded: record.checksum, Current: checksum})
+		}
+	}
+	return nil
+}
+
+func (m *migrate) VerifyChecksums(ctx context.Context) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	err := m.waitForDB(ctx)
+	if err != nil {
+		return err
+	}
+	handlers, schema, err := m.load(ctx)
+	if err != nil {
+		return err
+	}
+	return m.verifyChecksums(ctx, handlers[:searchPending(handlers, schema.version)])
+}
+
+func (m *migrate) Repair(ctx context.Context) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return m.operate(ctx, "migrate.Repair", func(ctx context.Context) error {
+		dialect := m.historyDialect()
+		if dialect == nil {
+			return nil
+		}
+		handlers, schema, err := m.load(ctx)
+		if err != nil {
+			return err
+		}
+		histories, err := m.loadHistory(ctx)
+		if err != nil {
+			return err
+		}
+		records := lastApplied(histories)
+		for _, handler := range handlers[:searchPending(handlers, schema.version)] {
+			record, ok := records[handler.GetIndex()]
+			checksum := checksumOf(handler)
+			if !ok || checksum == "" || record.checksum == checksum {
+				continue
+			}
+			_, err = m.db.ExecContext(ctx, dialect.UpdateChecksum(m.qualify(m.historyTable)), checksum, handler.GetIndex())
+			if err != nil {
+				return errors.WithStack(err)
+			}
+		}
+		return nil
+	})
+}
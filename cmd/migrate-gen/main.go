@@ -0,0 +1,247 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+/*
+migrate-gen 代码生成工具，在迁移包中通过 go:generate 调用：
+扫描包内的 go 文件，为 up0005、down0005 形式的函数生成注册代码，并校验索引是否重复或与文件名不一致，
+索引冲突时生成失败，使索引冲突在 go generate 时而非生产环境运行时发现，生成的文件以 map 字面量列出全部索引及声明的文件
+*/
+
+const usage = `Usage: migrate-gen [flags]
+
+Add to a file of the migrations package:
+  //go:generate go run powerlaw.ai/powerlib/migrate/cmd/migrate-gen
+
+Each migration is a file like 0005_add_users.go declaring
+  func up0005(ctx context.Context) error                  and optionally
+  func down0005(ctx context.Context) error, or
+  func up0005(ctx context.Context, tx *sql.Tx) error      to run in a transaction,
+or registering itself by concrete.Register(5, ...) in init.
+
+Flags:
+`
+
+var (
+	ErrDuplicateIndex = errors.New("duplicate index")
+	ErrIndexMismatch  = errors.New("index does not match the file name")
+	ErrDownWithoutUp  = errors.New("down function has no matching up function")
+	ErrTxDown         = errors.New("transactional up function does not support down")
+	ErrNoPackage      = errors.New("no go files found")
+)
+
+var (
+	// filePattern 迁移文件名，如 0005_add_users.go
+	filePattern = regexp.MustCompile(`^(\d+)_(.+)\.go$`)
+	// funcPattern 迁移函数名，如 up0005 及 down0005
+	funcPattern = regexp.MustCompile(`^(up|down)(\d+)$`)
+	// registerFuncs 带有索引参数的注册函数
+	registerFuncs = map[string]bool{"Register": true, "RegisterTx": true}
+)
+
+// migration 包内声明的单个迁移
+type migration struct {
+	index int
+	name  string
+	file  string
+	up    string // 生成注册代码的 up 函数，为空表示文件自行注册
+	down  string
+	tx    bool
+}
+
+func main() {
+	dir := flag.String("dir", ".", "migrations package dir")
+	output := flag.String("output", "migrations_gen.go", "generated file name in the dir")
+	flag.Usage = func() {
+		fmt.Fprint(flag.CommandLine.Output(), usage)
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	err := generate(*dir, *output)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "migrate-gen: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// generate 扫描 dir 并写入生成文件
+func generate(dir, output string) error {
+	pkg, migrations, err := scan(dir, output)
+	if err != nil {
+		return err
+	}
+	content, err := render(pkg, migrations)
+	if err != nil {
+		return err
+	}
+	return errors.WithStack(os.WriteFile(filepath.Join(dir, output), content, 0o644))
+}
+
+// scan 解析包内的 go 文件，返回包名及按索引排序的迁移
+func scan(dir, output string) (string, []migration, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.go"))
+	if err != nil {
+		return "", nil, errors.WithStack(err)
+	}
+	fset := token.NewFileSet()
+	var pkg string
+	byIndex := make(map[int]*migration)
+	for _, path := range paths {
+		base := filepath.Base(path)
+		if base == output || strings.HasSuffix(base, "_test.go") {
+			continue
+		}
+		file, err := parser.ParseFile(fset, path, nil, 0)
+		if err != nil {
+			return "", nil, errors.WithStack(err)
+		}
+		pkg = file.Name.Name
+		found, err := scanFile(base, file)
+		if err != nil {
+			return "", nil, err
+		}
+		for i := range found {
+			m := &found[i]
+			if exist, ok := byIndex[m.index]; ok {
+				return "", nil, errors.Wrapf(ErrDuplicateIndex, "index %d in %s and %s", m.index, exist.file, m.file)
+			}
+			byIndex[m.index] = m
+		}
+	}
+	if pkg == "" {
+		return "", nil, errors.Wrap(ErrNoPackage, dir)
+	}
+	migrations := make([]migration, 0, len(byIndex))
+	for _, m := range byIndex {
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].index < migrations[j].index
+	})
+	return pkg, migrations, nil
+}
+
+// scanFile 查找文件中的迁移函数及注册调用，文件名带有索引时两者需与其一致
+func scanFile(base string, file *ast.File) ([]migration, error) {
+	fileIndex, name := -1, ""
+	if match := filePattern.FindStringSubmatch(base); match != nil {
+		fileIndex, _ = strconv.Atoi(match[1])
+		name = match[2]
+	}
+	byIndex := make(map[int]*migration)
+	var indexes []int
+	get := func(index int) *migration {
+		m, ok := byIndex[index]
+		if !ok {
+			m = &migration{index: index, name: name, file: base}
+			byIndex[index] = m
+			indexes = append(indexes, index)
+		}
+		return m
+	}
+	// 1.up0005 及 down0005 函数
+	for _, decl := range file.Decls {
+		fn, ok := decl.(*ast.FuncDecl)
+		if !ok || fn.Recv != nil {
+			continue
+		}
+		match := funcPattern.FindStringSubmatch(fn.Name.Name)
+		if match == nil {
+			continue
+		}
+		index, _ := strconv.Atoi(match[2])
+		m := get(index)
+		if match[1] == "up" {
+			m.up, m.tx = fn.Name.Name, fn.Type.Params.NumFields() == 2
+		} else {
+			m.down = fn.Name.Name
+		}
+	}
+	// 2.concrete.Register 等调用
+	ast.Inspect(file, func(node ast.Node) bool {
+		call, ok := node.(*ast.CallExpr)
+		if !ok || len(call.Args) == 0 {
+			return true
+		}
+		selector, ok := call.Fun.(*ast.SelectorExpr)
+		if !ok || !registerFuncs[selector.Sel.Name] {
+			return true
+		}
+		// 按 go 字面量解析，0010 为八进制的 8
+		if lit, ok := call.Args[0].(*ast.BasicLit); ok && lit.Kind == token.INT {
+			index, err := strconv.ParseInt(lit.Value, 0, 0)
+			if err == nil {
+				get(int(index))
+			}
+		}
+		return true
+	})
+	migrations := make([]migration, 0, len(indexes))
+	for _, index := range indexes {
+		m := byIndex[index]
+		switch {
+		case fileIndex >= 0 && index != fileIndex:
+			return nil, errors.Wrapf(ErrIndexMismatch, "%s declares %d", base, index)
+		case m.down != "" && m.up == "":
+			return nil, errors.Wrapf(ErrDownWithoutUp, "%s: %s", base, m.down)
+		case m.down != "" && m.tx:
+			return nil, errors.Wrapf(ErrTxDown, "%s: %s", base, m.up)
+		}
+		migrations = append(migrations, *m)
+	}
+	return migrations, nil
+}
+
+// render 生成注册代码
+func render(pkg string, migrations []migration) ([]byte, error) {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "// Code generated by migrate-gen. DO NOT EDIT.\n\npackage %s\n\n", pkg)
+	var registers []string
+	for _, m := range migrations {
+		var handler string
+		switch {
+		case m.up == "":
+			continue
+		case m.tx:
+			handler = fmt.Sprintf("concrete.NewGoTxHandler(%d, %s)", m.index, m.up)
+		case m.down != "":
+			handler = fmt.Sprintf("concrete.NewGoHandlerWithDown(%d, %s, %s)", m.index, m.up, m.down)
+		default:
+			handler = fmt.Sprintf("concrete.NewGoHandler(%d, %s)", m.index, m.up)
+		}
+		registers = append(registers, fmt.Sprintf("concrete.RegisterHandler(%s.WithName(%q))", handler, m.name))
+	}
+	if len(registers) > 0 {
+		buf.WriteString("import \"powerlaw.ai/powerlib/migrate/concrete\"\n\n")
+	}
+	buf.WriteString("// 迁移索引及声明的文件\nvar _ = map[int]string{\n")
+	for _, m := range migrations {
+		fmt.Fprintf(&buf, "%d: %q,\n", m.index, m.file)
+	}
+	buf.WriteString("}\n")
+	if len(registers) > 0 {
+		buf.WriteString("\nfunc init() {\n")
+		for _, register := range registers {
+			buf.WriteString(register + "\n")
+		}
+		buf.WriteString("}\n")
+	}
+	content, err := format.Source(buf.Bytes())
+	return content, errors.WithStack(err)
+}
@@ -0,0 +1,637 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	_ "github.com/go-sql-driver/mysql"
+	_ "github.com/lib/pq"
+	"github.com/pkg/errors"
+	_ "modernc.org/sqlite"
+
+	"powerlaw.ai/powerlib/migrate"
+	"powerlaw.ai/powerlib/migrate/concrete"
+	"powerlaw.ai/powerlib/migrate/logger"
+	"powerlaw.ai/powerlib/migrate/parser/mysql"
+)
+
+/*
+migrate 命令行工具，用于在 CI 或运维终端中执行 sql 迁移；
+连接串、方言、sql 目录等参数可通过命令行或环境变量指定
+*/
+
+const usage = `Usage: migrate [flags] <command> [args]
+
+Commands:
+  up [N]        apply the next N pending migrations, defaults to all
+  down [N|all]  revert the last N applied migrations, defaults to 1
+  status        list applied and pending migrations
+  version       print the current version
+  force V       set the version to V and clear the dirty flag
+  baseline V    mark migrations up to V as applied without executing them
+  seed          apply all pending seed files in the seed dir, tracked by the seed_migrations table
+  new NAME      create the next NNNN_NAME.up.sql and NNNN_NAME.down.sql in the source dir
+  validate      check indexes and sql syntax of the source dir without connecting to the database
+  schema        print the schema of the database
+  lint [FILE]   check sql files for changes breaking zero-downtime deploys, defaults to the source dir
+  drop          drop all tables and views, requires -allow-unsafe
+  reset         revert all migrations then apply all, requires -allow-unsafe
+  fresh         drop all tables and views then apply all migrations, requires -allow-unsafe
+  test          apply all migrations, revert them one by one checking that no schema is left behind, then apply
+                them again, requires -allow-unsafe
+  squash N [NAME]
+                replace the migrations up to N by NNNN_NAME.sql with the schema they build on the -shadow-dsn
+                scratch database, NAME defaults to baseline
+  diff FILE [NAME]
+                compare the database with the desired schema FILE and create the next NNNN_NAME.up.sql and
+                NNNN_NAME.down.sql with candidate changes, NAME defaults to diff
+
+Exit codes:
+  0  success, or already up to date
+  1  failure
+  2  usage error
+  3  the schema is dirty, fix it and run force V
+  4  timed out acquiring the migration lock
+  5  the database is unavailable after -wait
+
+Flags:
+`
+
+const (
+	newFileFormat      = "%04d_%s.%s.sql"
+	baselineFileFormat = "%04d_%s.sql"
+	baselineHeader     = "-- +migrate Baseline\n-- squashed migrations up to %d, only runs on new databases\n\n"
+)
+
+// 退出码，便于 Kubernetes Job 及 init 容器区分失败原因
+const (
+	exitFailure     = 1
+	exitUsage       = 2
+	exitDirty       = 3
+	exitLockTimeout = 4
+	exitUnavailable = 5
+)
+
+const (
+	waitBackoff    = 500 * time.Millisecond
+	waitMaxBackoff = 5 * time.Second
+)
+
+var (
+	ErrUnknownCommand = errors.New("unknown command")
+	ErrUnknownDialect = errors.New("unknown dialect")
+	ErrMissingDSN     = errors.New("dsn is required")
+	ErrMissingArg     = errors.New("missing argument")
+	ErrLintFailed     = errors.New("lint found errors")
+	ErrLintSeverity   = errors.New("lint severity should be like drop-column=error")
+	ErrMissingShadow  = errors.New("squash requires -shadow-dsn of an empty scratch database")
+)
+
+// dialects 方言名称对应的 database/sql 驱动及方言
+var dialects = map[string]struct {
+	driver  string
+	dialect migrate.Dialect
+}{
+	"mysql":    {"mysql", migrate.MySQL},
+	"postgres": {"postgres", migrate.Postgres},
+	"sqlite":   {"sqlite", migrate.SQLite},
+}
+
+type config struct {
+	dsn       string
+	dialect   string
+	source    string
+	table     string
+	seed      string
+	recursive bool
+	tags      string
+
+	lintSeverity string
+	usedColumns  string
+
+	shadowDSN  string
+	dryRun     bool
+	schemaDump string
+
+	allowDestructive bool
+	allowUnsafe      bool
+
+	lock        bool
+	lockTimeout time.Duration
+	wait        time.Duration
+
+	autoRollback bool
+	heartbeat    time.Duration
+
+	appVersion string
+	operator   string
+
+	window         string
+	windowDuration time.Duration
+	windowSkip     bool
+}
+
+func main() {
+	var cfg config
+	flag.StringVar(&cfg.dsn, "dsn", os.Getenv("MIGRATE_DSN"), "database dsn, env MIGRATE_DSN")
+	flag.StringVar(&cfg.dialect, "dialect", envOr("MIGRATE_DIALECT", "mysql"), "mysql, postgres or sqlite, env MIGRATE_DIALECT")
+	flag.StringVar(&cfg.source, "source", envOr("MIGRATE_SOURCE", "./migration"), "sql file dir, env MIGRATE_SOURCE")
+	flag.StringVar(&cfg.table, "table", envOr("MIGRATE_TABLE", "schema_migrations"), "schema table name, env MIGRATE_TABLE")
+	flag.StringVar(&cfg.seed, "seed", envOr("MIGRATE_SEED", "./seed"), "seed sql file dir, env MIGRATE_SEED")
+	flag.BoolVar(&cfg.recursive, "recursive", os.Getenv("MIGRATE_RECURSIVE") != "", "read sql files in sub dirs of the source dir, env MIGRATE_RECURSIVE")
+	flag.StringVar(&cfg.tags, "tags", os.Getenv("MIGRATE_TAGS"), "comma separated tags of handlers to run, env MIGRATE_TAGS")
+	flag.BoolVar(&cfg.allowDestructive, "allow-destructive", os.Getenv("MIGRATE_ALLOW_DESTRUCTIVE") != "", "run migrations containing DROP TABLE, DROP COLUMN or TRUNCATE without confirmation, env MIGRATE_ALLOW_DESTRUCTIVE")
+	flag.BoolVar(&cfg.allowUnsafe, "allow-unsafe", os.Getenv("MIGRATE_ALLOW_UNSAFE") != "", "allow drop, reset, fresh and test, for development databases only, env MIGRATE_ALLOW_UNSAFE")
+	flag.StringVar(&cfg.lintSeverity, "lint-severity", os.Getenv("MIGRATE_LINT_SEVERITY"), "comma separated rule=off|warning|error of lint, env MIGRATE_LINT_SEVERITY")
+	flag.StringVar(&cfg.usedColumns, "used-columns", os.Getenv("MIGRATE_USED_COLUMNS"), "comma separated table.column still used by deployed code, env MIGRATE_USED_COLUMNS")
+	flag.StringVar(&cfg.shadowDSN, "shadow-dsn", os.Getenv("MIGRATE_SHADOW_DSN"), "disposable shadow database dsn, migrations run on it before the database and up prints the schema changes, env MIGRATE_SHADOW_DSN")
+	flag.BoolVar(&cfg.dryRun, "dry-run", os.Getenv("MIGRATE_DRY_RUN") != "", "print pending migrations instead of executing them, migrations still run on the shadow database, env MIGRATE_DRY_RUN")
+	flag.StringVar(&cfg.schemaDump, "schema-dump", os.Getenv("MIGRATE_SCHEMA_DUMP"), "file to write the schema to after migrating, env MIGRATE_SCHEMA_DUMP")
+	flag.BoolVar(&cfg.lock, "lock", os.Getenv("MIGRATE_LOCK") != "", "hold an advisory lock while migrating, so only one replica migrates, env MIGRATE_LOCK")
+	flag.BoolVar(&cfg.autoRollback, "auto-rollback", os.Getenv("MIGRATE_AUTO_ROLLBACK") != "", "run the down migration of a failed migration instead of marking the schema dirty, env MIGRATE_AUTO_ROLLBACK")
+	durationVar(&cfg.lockTimeout, "lock-timeout", "MIGRATE_LOCK_TIMEOUT", "fail with exit code 4 if the lock is not acquired in the duration, implies -lock")
+	flag.StringVar(&cfg.appVersion, "app-version", os.Getenv("MIGRATE_APP_VERSION"), "application version recorded in the history table, env MIGRATE_APP_VERSION")
+	flag.StringVar(&cfg.operator, "operator", envOr("MIGRATE_OPERATOR", os.Getenv("USER")), "operator recorded in the history table, env MIGRATE_OPERATOR, defaults to $USER")
+	durationVar(&cfg.heartbeat, "heartbeat", "MIGRATE_HEARTBEAT", "record the running migration with a heartbeat at the interval, so other replicas tell a running migration from a crashed one")
+	durationVar(&cfg.wait, "wait", "MIGRATE_WAIT", "wait up to the duration for the database to accept connections, then fail with exit code 5")
+	flag.StringVar(&cfg.window, "window", os.Getenv("MIGRATE_WINDOW"), "cron expression of the maintenance window start, migrations tagged heavy wait for the window, env MIGRATE_WINDOW")
+	durationVar(&cfg.windowDuration, "window-duration", "MIGRATE_WINDOW_DURATION", "duration of the maintenance window")
+	flag.BoolVar(&cfg.windowSkip, "window-skip", os.Getenv("MIGRATE_WINDOW_SKIP") != "", "stop before migrations tagged heavy outside the maintenance window instead of waiting, env MIGRATE_WINDOW_SKIP")
+	flag.Usage = func() {
+		fmt.Fprint(flag.CommandLine.Output(), usage)
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(exitUsage)
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer cancel()
+	err := run(ctx, cfg, flag.Arg(0), flag.Args()[1:])
+	// 包含破坏性语句时，终端中确认后重新执行
+	var destructive *migrate.DestructiveError
+	if errors.As(err, &destructive) && confirm(destructive) {
+		cfg.allowDestructive = true
+		err = run(ctx, cfg, flag.Arg(0), flag.Args()[1:])
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "migrate: %v\n", err)
+		os.Exit(exitCode(err))
+	}
+}
+
+// exitCode 返回错误对应的退出码
+func exitCode(err error) int {
+	var dirty *migrate.DirtyError
+	switch {
+	case errors.As(err, &dirty):
+		return exitDirty
+	case errors.Is(err, migrate.ErrLockTimeout):
+		return exitLockTimeout
+	case errors.Is(err, migrate.ErrDBUnavailable):
+		return exitUnavailable
+	}
+	return exitFailure
+}
+
+// run 执行子命令
+func run(ctx context.Context, cfg config, command string, args []string) error {
+	// new 仅操作 sql 目录，无需连接数据库
+	if command == "new" {
+		if len(args) == 0 {
+			return errors.Wrap(ErrMissingArg, "new requires a name")
+		}
+		return newMigration(cfg.source, args[0])
+	}
+	if command == "validate" {
+		return validate(ctx, cfg)
+	}
+	if command == "lint" {
+		return lint(cfg, args)
+	}
+	// squash 在影子库上执行，不连接目标库
+	if command == "squash" {
+		if len(args) == 0 {
+			return errors.Wrap(ErrMissingArg, "squash requires a version")
+		}
+		return squash(ctx, cfg, args)
+	}
+	// 指定影子库时先在影子库执行
+	var extra []migrate.Option
+	if cfg.shadowDSN != "" {
+		shadow, shadowDB, err := open(shadowConfig(cfg))
+		if err != nil {
+			return err
+		}
+		defer shadowDB.Close()
+		dialect := dialects[cfg.dialect].dialect
+		extra = append(extra, migrate.WithShadow(shadow), migrate.WithShadowDiff(func(current, desired string) string {
+			return concrete.Diff(current, desired, dialect)
+		}))
+	}
+	client, db, err := open(cfg, extra...)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	switch command {
+	case "up":
+		if len(args) == 0 {
+			report, err := client.RunWithResult(ctx)
+			// 影子库执行成功后输出真实库将发生的表结构变更
+			if report != nil && report.ShadowDiff != "" {
+				fmt.Fprintf(os.Stderr, "-- schema changes verified on the shadow database:\n%s", report.ShadowDiff)
+			}
+			return err
+		}
+		steps, err := strconv.Atoi(args[0])
+		if err != nil || steps <= 0 {
+			return errors.Errorf("invalid steps %s", args[0])
+		}
+		return client.Steps(ctx, steps)
+	case "down":
+		steps := 1
+		if len(args) > 0 {
+			if args[0] == "all" {
+				steps = 0
+			} else if steps, err = strconv.Atoi(args[0]); err != nil || steps <= 0 {
+				return errors.Errorf("invalid steps %s", args[0])
+			}
+		}
+		return client.Down(ctx, steps)
+	case "seed":
+		options := append(commonOptions(cfg, dialects[cfg.dialect].dialect),
+			migrate.WithExecutors(concrete.NewSeedExecutor(db, cfg.seed, concrete.WithDialect(dialects[cfg.dialect].dialect))))
+		return migrate.NewSeeder(db, options...).Run(ctx)
+	case "status":
+		return printStatus(ctx, client)
+	case "schema":
+		return client.DumpSchema(ctx, os.Stdout)
+	case "diff":
+		if len(args) == 0 {
+			return errors.Wrap(ErrMissingArg, "diff requires a schema file")
+		}
+		return diff(ctx, cfg, client, args)
+	case "version":
+		version, dirty, err := client.Version(ctx)
+		if err != nil {
+			return err
+		}
+		if dirty {
+			fmt.Printf("%d (dirty)\n", version)
+		} else {
+			fmt.Println(version)
+		}
+		return nil
+	case "drop":
+		return client.Drop(ctx)
+	case "reset":
+		return client.Reset(ctx)
+	case "fresh":
+		return client.Fresh(ctx)
+	case "test":
+		return client.RoundTrip(ctx)
+	case "force", "baseline":
+		if len(args) == 0 {
+			return errors.Wrapf(ErrMissingArg, "%s requires a version", command)
+		}
+		version, err := strconv.Atoi(args[0])
+		if err != nil {
+			return errors.Errorf("invalid version %s", args[0])
+		}
+		if command == "baseline" {
+			return client.Baseline(ctx, version)
+		}
+		return client.Force(ctx, version)
+	default:
+		return errors.Wrap(ErrUnknownCommand, command)
+	}
+}
+
+// open 打开数据库连接并创建迁移客户端，extra 追加到迁移客户端的选项
+func open(cfg config, extra ...migrate.Option) (migrate.Migrate, *sql.DB, error) {
+	if cfg.dsn == "" {
+		return nil, nil, ErrMissingDSN
+	}
+	d, ok := dialects[cfg.dialect]
+	if !ok {
+		return nil, nil, errors.Wrap(ErrUnknownDialect, cfg.dialect)
+	}
+	db, err := sql.Open(d.driver, cfg.dsn)
+	if err != nil {
+		return nil, nil, errors.WithStack(err)
+	}
+	options := append(commonOptions(cfg, d.dialect),
+		migrate.WithTableName(cfg.table),
+		migrate.WithExecutors(concrete.NewSQLExecutorSource(db, source(cfg), concrete.WithDialect(d.dialect))))
+	if cfg.schemaDump != "" {
+		options = append(options, migrate.WithSchemaDumpFile(cfg.schemaDump))
+	}
+	if cfg.lock || cfg.lockTimeout > 0 {
+		options = append(options, migrate.WithAdvisoryLock(), migrate.WithLockTimeout(cfg.lockTimeout))
+	}
+	if cfg.autoRollback {
+		options = append(options, migrate.WithAutoRollback())
+	}
+	if cfg.heartbeat > 0 {
+		options = append(options, migrate.WithHeartbeat(cfg.heartbeat, 0))
+	}
+	if cfg.window != "" {
+		window, err := migrate.ParseWindow(cfg.window, cfg.windowDuration)
+		if err != nil {
+			db.Close()
+			return nil, nil, err
+		}
+		mode := migrate.WindowWait
+		if cfg.windowSkip {
+			mode = migrate.WindowSkip
+		}
+		options = append(options, migrate.WithMaintenanceWindow(window, mode))
+	}
+	options = append(options, extra...)
+	return migrate.New(db, options...), db, nil
+}
+
+// shadowConfig 返回影子库的配置，影子库不加锁、不导出表结构且不受维护窗口限制
+func shadowConfig(cfg config) config {
+	shadowCfg := cfg
+	shadowCfg.dsn, shadowCfg.shadowDSN, shadowCfg.dryRun, shadowCfg.schemaDump = cfg.shadowDSN, "", false, ""
+	shadowCfg.lock, shadowCfg.lockTimeout, shadowCfg.window = false, 0, ""
+	return shadowCfg
+}
+
+// validate 校验 sql 目录的索引及语法，MySQL 以外的方言仅校验索引
+func validate(ctx context.Context, cfg config) error {
+	d, ok := dialects[cfg.dialect]
+	if !ok {
+		return errors.Wrap(ErrUnknownDialect, cfg.dialect)
+	}
+	sqlOptions := []concrete.SQLOption{concrete.WithDialect(d.dialect)}
+	if d.dialect == migrate.MySQL {
+		sqlOptions = append(sqlOptions, concrete.WithParser(mysql.New()))
+	}
+	options := append(commonOptions(cfg, d.dialect),
+		migrate.WithExecutors(concrete.NewSQLExecutorSource(nil, source(cfg), sqlOptions...)))
+	err := migrate.New(nil, options...).Validate(ctx)
+	if err != nil {
+		return err
+	}
+	fmt.Println("ok")
+	return nil
+}
+
+// lint 检查指定文件或 sql 目录，存在 error 级别的问题时返回错误
+func lint(cfg config, files []string) error {
+	options := []concrete.LintOption{concrete.WithUsedColumns(split(cfg.usedColumns)...)}
+	for _, item := range split(cfg.lintSeverity) {
+		rule, severity, ok := strings.Cut(item, "=")
+		switch concrete.Severity(severity) {
+		case concrete.SeverityOff, concrete.SeverityWarning, concrete.SeverityError:
+		default:
+			ok = false
+		}
+		if !ok {
+			return errors.Wrap(ErrLintSeverity, item)
+		}
+		options = append(options, concrete.WithSeverity(concrete.LintRule(rule), concrete.Severity(severity)))
+	}
+	linter := concrete.NewLinter(options...)
+	var issues []concrete.LintIssue
+	if len(files) == 0 {
+		var err error
+		issues, err = linter.Lint(source(cfg))
+		if err != nil {
+			return err
+		}
+	}
+	for _, file := range files {
+		fileIssues, err := linter.Lint(concrete.NewDirSource(filepath.Dir(file)), filepath.Base(file))
+		if err != nil {
+			return err
+		}
+		issues = append(issues, fileIssues...)
+	}
+	failed := false
+	for _, issue := range issues {
+		fmt.Println(issue)
+		failed = failed || issue.Severity == concrete.SeverityError
+	}
+	if failed {
+		return ErrLintFailed
+	}
+	return nil
+}
+
+// commonOptions 迁移及数据初始化共用的选项
+func commonOptions(cfg config, dialect migrate.Dialect) []migrate.Option {
+	options := []migrate.Option{
+		migrate.WithDialect(dialect),
+		migrate.WithLogger(newLogger()),
+		migrate.WithTags(tags(cfg)...),
+		migrate.WithAppVersion(cfg.appVersion),
+		migrate.WithOperator(cfg.operator),
+	}
+	if cfg.allowDestructive {
+		options = append(options, migrate.WithAllowDestructive())
+	}
+	if cfg.allowUnsafe {
+		options = append(options, migrate.WithAllowUnsafe())
+	}
+	if cfg.dryRun {
+		options = append(options, migrate.WithDryRun(os.Stdout))
+	}
+	if cfg.wait > 0 {
+		options = append(options, migrate.WithWaitForDB(waitBackoff, waitMaxBackoff), migrate.WithWaitTimeout(cfg.wait))
+	}
+	return options
+}
+
+// confirm 标准输入为终端时询问是否执行包含破坏性语句的迁移
+func confirm(err *migrate.DestructiveError) bool {
+	if stat, statErr := os.Stdin.Stat(); statErr != nil || stat.Mode()&os.ModeCharDevice == 0 {
+		return false
+	}
+	fmt.Fprintf(os.Stderr, "migration %d %s contains destructive statement:\n  %s\napply it? [y/N] ", err.Index, err.Name, err.Statement)
+	answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes"
+}
+
+// tags 解析逗号分隔的标签
+func tags(cfg config) []string {
+	return split(cfg.tags)
+}
+
+// split 拆分逗号分隔的参数，参数为空时返回空
+func split(value string) []string {
+	if value == "" {
+		return nil
+	}
+	return strings.Split(value, ",")
+}
+
+// newLogger 返回输出到标准错误的日志
+func newLogger() migrate.Logger {
+	return logger.NewSlog(slog.New(slog.NewTextHandler(os.Stderr, nil)))
+}
+
+// source 返回 sql 文件来源
+func source(cfg config) concrete.Source {
+	if cfg.recursive {
+		return concrete.NewDirSource(cfg.source, concrete.WithRecursive())
+	}
+	return concrete.NewDirSource(cfg.source)
+}
+
+// printStatus 以表格形式输出已执行及待执行的处理程序
+func printStatus(ctx context.Context, client migrate.Migrate) error {
+	applied, pending, err := client.Status(ctx)
+	if err != nil {
+		return err
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "INDEX\tNAME\tSTATE")
+	for _, info := range append(applied, pending...) {
+		fmt.Fprintf(w, "%d\t%s\t%s\n", info.Index, info.Name, info.State)
+	}
+	return w.Flush()
+}
+
+// diff 比较数据库与目标表结构文件，生成包含候选变更的 up/down 文件，需人工确认后再执行
+func diff(ctx context.Context, cfg config, client migrate.Migrate, args []string) error {
+	desired, err := os.ReadFile(args[0])
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	var current strings.Builder
+	err = client.DumpSchema(ctx, &current)
+	if err != nil {
+		return err
+	}
+	dialect := dialects[cfg.dialect].dialect
+	up := concrete.Diff(current.String(), string(desired), dialect)
+	if up == "" {
+		fmt.Println("no changes")
+		return nil
+	}
+	name := "diff"
+	if len(args) > 1 {
+		name = args[1]
+	}
+	return newMigration(cfg.source, name, up, concrete.Diff(string(desired), current.String(), dialect))
+}
+
+// squash 在影子库上执行至版本 N 并导出表结构，以表结构作为索引 N 的基线文件替换 N 及之前的 sql 文件
+func squash(ctx context.Context, cfg config, args []string) error {
+	version, err := strconv.Atoi(args[0])
+	if err != nil || version <= 0 {
+		return errors.Errorf("invalid version %s", args[0])
+	}
+	name := "baseline"
+	if len(args) > 1 {
+		name = args[1]
+	}
+	if cfg.shadowDSN == "" {
+		return ErrMissingShadow
+	}
+	// 1.在影子库上执行至版本 N 并导出表结构
+	client, db, err := open(shadowConfig(cfg))
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	err = client.MigrateTo(ctx, version)
+	if err != nil {
+		return err
+	}
+	var schema strings.Builder
+	err = client.DumpSchema(ctx, &schema)
+	if err != nil {
+		return err
+	}
+	// 2.删除 N 及之前的 sql 文件，写入基线文件
+	files, err := concrete.FilesUpTo(os.DirFS(cfg.source), ".", version)
+	if err != nil {
+		return err
+	}
+	for _, file := range files {
+		err = os.Remove(filepath.Join(cfg.source, file))
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		fmt.Println("removed", file)
+	}
+	fileName := filepath.Join(cfg.source, fmt.Sprintf(baselineFileFormat, version, name))
+	err = os.WriteFile(fileName, []byte(fmt.Sprintf(baselineHeader, version)+schema.String()), 0644)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	fmt.Println(fileName)
+	return nil
+}
+
+// newMigration 在 sql 目录中创建下一个索引的 up/down 文件，contents 依次为 up/down 文件的内容
+func newMigration(dir, name string, contents ...string) error {
+	err := os.MkdirAll(dir, 0755)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	maxIndex, err := concrete.MaxIndex(os.DirFS(dir), ".")
+	if err != nil {
+		return err
+	}
+	for i, direction := range []string{"up", "down"} {
+		fileName := filepath.Join(dir, fmt.Sprintf(newFileFormat, maxIndex+1, name, direction))
+		// O_EXCL 避免覆盖已存在的文件
+		f, err := os.OpenFile(fileName, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		if i < len(contents) {
+			_, err = f.WriteString(contents[i])
+			if err != nil {
+				f.Close()
+				return errors.WithStack(err)
+			}
+		}
+		err = f.Close()
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		fmt.Println(fileName)
+	}
+	return nil
+}
+
+// durationVar 定义时长参数，默认值取自环境变量 env
+func durationVar(p *time.Duration, name, env, usage string) {
+	flag.DurationVar(p, name, 0, usage+", env "+env)
+	if value := os.Getenv(env); value != "" {
+		err := flag.Set(name, value)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "migrate: invalid %s: %v\n", env, err)
+			os.Exit(exitUsage)
+		}
+	}
+}
+
+func envOr(key, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
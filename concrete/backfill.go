@@ -0,0 +1,146 @@
+package concrete
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"powerlaw.ai/powerlib/migrate"
+)
+
+/*
+分批回填处理程序，按主键范围分批执行 UPDATE 或 INSERT ... SELECT，每批独立提交，避免大事务长时间锁表及主从延迟；
+批次边界通过 WHERE key >= ? ORDER BY key LIMIT n+1 确定，不依赖 OFFSET，中断后重新执行时从头开始，语句需可重复执行
+*/
+
+const defaultBackfillBatch = 1000
+
+var (
+	ErrBackfillSpec = errors.New("backfill requires table and set or query")
+)
+
+// Backfill 分批回填配置
+type Backfill struct {
+	Table     string          // 回填的表
+	Key       string          // 分批的列，需唯一且有索引，默认为 id
+	Set       string          // UPDATE 的 SET 子句，如 nick = name
+	Where     string          // 附加条件，如 nick IS NULL，同时用于确定批次及回填
+	Query     string          // 自定义的批次语句，参数为批次首尾的 key（均包含），优先于 Set，如 INSERT ... SELECT
+	BatchSize int             // 每批的行数，默认为 1000
+	Sleep     time.Duration   // 批次之间的等待时间，降低对主库及复制的压力
+	Dialect   migrate.Dialect // 决定占位符，postgres 使用 $n，默认为 ?
+	Logger    migrate.Logger  // 输出每批的进度，为空时不输出
+}
+
+// NewBackfillHandler 创建在 db 上分批回填的处理程序，可通过 WithName、WithTimeout 等配置
+func NewBackfillHandler(index int, db migrate.DBTX, spec Backfill) GoHandler {
+	return NewGoHandler(index, func(ctx context.Context) error {
+		return spec.run(ctx, db)
+	})
+}
+
+// run 逐批执行直至没有剩余的行
+func (b Backfill) run(ctx context.Context, db migrate.DBTX) error {
+	if b.Table == "" || (b.Set == "" && b.Query == "") {
+		return errors.WithStack(ErrBackfillSpec)
+	}
+	if b.Key == "" {
+		b.Key = "id"
+	}
+	if b.BatchSize <= 0 {
+		b.BatchSize = defaultBackfillBatch
+	}
+	// 1.查询起始 key
+	var from interface{}
+	err := db.QueryRowContext(ctx, fmt.Sprintf("SELECT MIN(%s) FROM %s%s", b.Key, b.Table, b.where("WHERE"))).Scan(&from)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	var batches, affected int64
+	start := time.Now()
+	for from != nil {
+		// 2.确定批次的末尾 key 及下一批次的起始 key
+		to, next, err := b.bounds(ctx, db, from)
+		if err != nil {
+			return err
+		}
+		// 3.回填当前批次
+		err = migrate.Throttle(ctx)
+		if err != nil {
+			return err
+		}
+		result, err := db.ExecContext(ctx, b.batchQuery(), from, to)
+		if err != nil {
+			return errors.Wrapf(err, "backfill %s batch from %v to %v", b.Table, from, to)
+		}
+		rows, _ := result.RowsAffected()
+		batches++
+		affected += rows
+		if b.Logger != nil {
+			b.Logger.Info("backfill progress", "table", b.Table, "batch", batches, "from", from, "to", to,
+				"rows", rows, "total", affected, "duration", time.Since(start))
+		}
+		from = next
+		if from == nil || b.Sleep <= 0 {
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			return errors.WithStack(ctx.Err())
+		case <-time.After(b.Sleep):
+		}
+	}
+	return nil
+}
+
+// bounds 查询从 from 开始的 BatchSize+1 个 key，返回批次的末尾 key 及下一批次的起始 key，已是最后一批时 next 为空
+func (b Backfill) bounds(ctx context.Context, db migrate.DBTX, from interface{}) (to, next interface{}, err error) {
+	query := fmt.Sprintf("SELECT %s FROM %s WHERE %s >= %s%s ORDER BY %s LIMIT %d",
+		b.Key, b.Table, b.Key, b.placeholder(1), b.where("AND"), b.Key, b.BatchSize+1)
+	rows, err := db.QueryContext(ctx, query, from)
+	if err != nil {
+		return nil, nil, errors.WithStack(err)
+	}
+	defer rows.Close()
+	n := 0
+	for rows.Next() {
+		var key interface{}
+		err = rows.Scan(&key)
+		if err != nil {
+			return nil, nil, errors.WithStack(err)
+		}
+		n++
+		if n > b.BatchSize {
+			next = key
+			break
+		}
+		to = key
+	}
+	return to, next, errors.WithStack(rows.Err())
+}
+
+// batchQuery 返回批次语句，参数为批次首尾的 key
+func (b Backfill) batchQuery() string {
+	if b.Query != "" {
+		return b.Query
+	}
+	return fmt.Sprintf("UPDATE %s SET %s WHERE %s >= %s AND %s <= %s%s",
+		b.Table, b.Set, b.Key, b.placeholder(1), b.Key, b.placeholder(2), b.where("AND"))
+}
+
+// where 返回附加条件，keyword 为 WHERE 或 AND
+func (b Backfill) where(keyword string) string {
+	if b.Where == "" {
+		return ""
+	}
+	return " " + keyword + " (" + b.Where + ")"
+}
+
+func (b Backfill) placeholder(n int) string {
+	if d, ok := b.Dialect.(migrate.NamedDialect); ok && d.Name() == "postgres" {
+		return fmt.Sprintf("$%d", n)
+	}
+	return "?"
+}
@@ -1,9 +1,45 @@
 package concrete
 
+import (
+	"time"
+
+	"powerlaw.ai/powerlib/migrate"
+)
+
 type baseHandler struct {
-	index int
+	index     int
+	name      string
+	timeout   time.Duration     // 执行超时时间，0 表示使用 migrate 的配置
+	tags      []string          // 标签，仅在 migrate.WithTags 包含任一标签时执行
+	throttler migrate.Throttler // 限流器，为空时使用 migrate 的配置
+	dependsOn []int             // 依赖的处理程序索引，nil 表示依赖所有之前的处理程序
+	optional  bool              // 是否为可选处理程序，执行失败时不中断执行
 }
 
 func (b *baseHandler) GetIndex() int {
 	return b.index
 }
+
+func (b *baseHandler) GetName() string {
+	return b.name
+}
+
+func (b *baseHandler) GetTimeout() time.Duration {
+	return b.timeout
+}
+
+func (b *baseHandler) GetTags() []string {
+	return b.tags
+}
+
+func (b *baseHandler) GetThrottler() migrate.Throttler {
+	return b.throttler
+}
+
+func (b *baseHandler) DependsOn() []int {
+	return b.dependsOn
+}
+
+func (b *baseHandler) IsOptional() bool {
+	return b.optional
+}
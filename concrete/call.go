@@ -0,0 +1,133 @@
+package concrete
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"powerlaw.ai/powerlib/migrate"
+)
+
+/*
+调用处理程序，向其他服务发送一次 http 请求作为迁移步骤，用于触发其他服务的数据迁移，
+由 version 保证只调用一次；请求失败或状态码不符合预期时标记 dirty，被调用的接口应可重复调用
+*/
+
+const maxCallErrorBody = 512
+
+// callHandler 发送 http 请求的处理程序
+type callHandler struct {
+	baseHandler
+	method string
+	url    string
+	body   []byte
+	header http.Header
+	client *http.Client
+	status []int // 预期的状态码，为空时接受 2xx
+}
+
+// CallOption 调用处理程序选项
+type CallOption func(c *callHandler)
+
+// NewCallHandler 创建以 method 请求 url 的处理程序，默认状态码为 2xx 时成功
+func NewCallHandler(index int, name, method, url string, options ...CallOption) migrate.Handler {
+	handler := &callHandler{
+		baseHandler: baseHandler{index: index, name: name},
+		method:      method,
+		url:         url,
+		header:      make(http.Header),
+		client:      http.DefaultClient,
+	}
+	for _, option := range options {
+		option(handler)
+	}
+	return handler
+}
+
+// WithCallBody 指定请求体，contentType 为空时不设置 Content-Type
+func WithCallBody(contentType string, body []byte) CallOption {
+	return func(c *callHandler) {
+		c.body = body
+		if contentType != "" {
+			c.header.Set("Content-Type", contentType)
+		}
+	}
+}
+
+// WithCallHeader 添加请求头，如认证信息
+func WithCallHeader(key, value string) CallOption {
+	return func(c *callHandler) {
+		c.header.Add(key, value)
+	}
+}
+
+// WithCallClient 指定 http 客户端
+func WithCallClient(client *http.Client) CallOption {
+	return func(c *callHandler) {
+		c.client = client
+	}
+}
+
+// WithExpectedStatus 指定预期的状态码，其他状态码视为失败
+func WithExpectedStatus(status ...int) CallOption {
+	return func(c *callHandler) {
+		c.status = status
+	}
+}
+
+// WithCallTimeout 指定请求超时时间，优先于 migrate.WithHandlerTimeout
+func WithCallTimeout(timeout time.Duration) CallOption {
+	return func(c *callHandler) {
+		c.timeout = timeout
+	}
+}
+
+// GetQuery 返回请求行，用于 dry-run
+func (c *callHandler) GetQuery() string {
+	return c.method + " " + c.url
+}
+
+// GetChecksum 返回请求方法、地址及请求体的 sha256，已执行的请求变更时拒绝执行
+func (c *callHandler) GetChecksum() string {
+	hash := sha256.New()
+	hash.Write([]byte(c.method + " " + c.url + "\n"))
+	hash.Write(c.body)
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+func (c *callHandler) Exec(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, c.method, c.url, bytes.NewReader(c.body))
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	req.Header = c.header.Clone()
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	defer resp.Body.Close()
+	if c.expected(resp.StatusCode) {
+		return nil
+	}
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, maxCallErrorBody))
+	return errors.Errorf(ErrHTTPStatusFormat+": %s", resp.StatusCode, c.url, body)
+}
+
+// expected 判断状态码是否符合预期
+func (c *callHandler) expected(status int) bool {
+	if len(c.status) == 0 {
+		return status >= 200 && status < 300
+	}
+	for _, s := range c.status {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
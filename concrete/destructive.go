@@ -0,0 +1,92 @@
+package concrete
+
+import (
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+/*
+破坏性语句识别，按关键字识别 DROP TABLE、TRUNCATE 及 ALTER TABLE 中删除列的语句，
+忽略注释及字符串中的内容
+*/
+
+// errStop 找到破坏性语句后停止读取
+var errStop = errors.New("stop")
+
+// keepDrops ALTER TABLE 中不删除列的 DROP 子句，如 DROP INDEX、ALTER COLUMN c DROP DEFAULT
+var keepDrops = map[string]bool{
+	"INDEX": true, "KEY": true, "PRIMARY": true, "FOREIGN": true, "CONSTRAINT": true, "CHECK": true,
+	"UNIQUE": true, "FULLTEXT": true, "SPATIAL": true, "PARTITION": true,
+	"DEFAULT": true, "NOT": true, "IDENTITY": true, "EXPRESSION": true,
+}
+
+// isDestructive 判断单条语句是否为破坏性语句
+func isDestructive(query string) bool {
+	tokens := tokenize(query)
+	switch {
+	case len(tokens) >= 2 && tokens[0] == "DROP" && tokens[1] == "TABLE":
+		return true
+	case len(tokens) >= 1 && tokens[0] == "TRUNCATE":
+		return true
+	case len(tokens) >= 2 && tokens[0] == "ALTER" && tokens[1] == "TABLE":
+		for i := 2; i < len(tokens)-1; i++ {
+			if tokens[i] == "DROP" && !keepDrops[tokens[i+1]] {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// tokenize 去除注释及字符串后按空白拆分为大写的单词，逗号及括号作为单独的单词
+func tokenize(query string) []string {
+	var b strings.Builder
+	for i := 0; i < len(query); {
+		if end := commentEnd(query, i); end > i {
+			b.WriteByte(' ')
+			i = end
+			continue
+		}
+		if c := query[i]; c == '\'' || c == '$' {
+			if end := quoteEnd(query, i); end > i {
+				b.WriteByte(' ')
+				i = end
+				continue
+			}
+		}
+		switch c := query[i]; c {
+		case ',', '(', ')':
+			b.WriteByte(' ')
+			b.WriteByte(c)
+			b.WriteByte(' ')
+		case ';':
+			b.WriteByte(' ')
+		default:
+			b.WriteByte(c)
+		}
+		i++
+	}
+	return strings.Fields(strings.ToUpper(b.String()))
+}
+
+// destructive 流式读取文件，返回第一条破坏性语句，不包含时返回空
+func (f sqlFile) destructive() (string, error) {
+	file, err := f.open()
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	var found string
+	err = streamStatements(file, func(stmt statement) error {
+		if isDestructive(stmt.query) {
+			found = snippet(stmt.query)
+			return errStop
+		}
+		return nil
+	})
+	if err == errStop {
+		err = nil
+	}
+	return found, err
+}
@@ -0,0 +1,81 @@
+package concrete
+
+import (
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"powerlaw.ai/powerlib/migrate"
+)
+
+/*
+方言专属文件，如 0007_add_index.mysql.sql 与 0007_add_index.postgres.sql 可以共存，
+执行器按配置的方言选择文件，同一索引存在通用文件时优先使用方言专属文件
+*/
+
+var (
+	ErrFileDialect = errors.New("file is dialect specific but no dialect is configured")
+)
+
+// builtinDialects 内置方言名称，其他方言名称需通过 WithDialect 配置
+var builtinDialects = []string{"mysql", "postgres", "sqlite"}
+
+// WithDialect 按方言名称选择 0001_name.mysql.sql 等方言专属文件，dialect 需实现 migrate.NamedDialect，
+// 通常与 migrate.WithDialect 使用同一方言
+func WithDialect(dialect migrate.Dialect) SQLOption {
+	return func(s *sqlExecutor) {
+		if named, ok := dialect.(migrate.NamedDialect); ok {
+			s.dialect = named.Name()
+		}
+	}
+}
+
+// trimDialect 去除文件名中的方言后缀，返回去除后的文件名及方言名称
+func trimDialect(base, dialect string) (string, string) {
+	for _, name := range append(builtinDialects, dialect) {
+		if name != "" && strings.HasSuffix(base, "."+name) {
+			return strings.TrimSuffix(base, "."+name), name
+		}
+	}
+	return base, ""
+}
+
+// selectDialect 选择方言为 dialect 的文件，方言专属文件替换同一索引及方向的通用文件，其他方言的文件被忽略
+func selectDialect(files []fileInfo, dialect string) ([]fileInfo, error) {
+	// 1.记录当前方言的专属文件
+	type key struct {
+		index int
+		name  string
+		down  bool
+	}
+	keyOf := func(f fileInfo) key {
+		if f.repeatable {
+			return key{name: f.name, down: f.down}
+		}
+		return key{index: f.index, down: f.down}
+	}
+	specific := make(map[key]bool)
+	for _, f := range files {
+		if f.dialect == "" {
+			continue
+		}
+		if dialect == "" {
+			return nil, errors.Wrap(ErrFileDialect, f.fileName)
+		}
+		if f.dialect == dialect {
+			specific[keyOf(f)] = true
+		}
+	}
+	// 2.过滤其他方言的文件及被替换的通用文件
+	var selected []fileInfo
+	for _, f := range files {
+		switch {
+		case f.dialect != "" && f.dialect != dialect:
+			continue
+		case f.dialect == "" && specific[keyOf(f)]:
+			continue
+		}
+		selected = append(selected, f)
+	}
+	return selected, nil
+}
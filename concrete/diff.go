@@ -0,0 +1,248 @@
+package concrete
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"powerlaw.ai/powerlib/migrate"
+)
+
+/*
+表结构差异，比较 DumpSchema 格式的当前及目标表结构，生成将当前表结构变更为目标表结构的候选 sql；
+按语句文本比较，表按列及表内约束比较，无法自动生成的变更以 -- TODO 注释输出，需人工确认
+*/
+
+// constraintKeywords 表定义中表示约束或索引而非列的开头关键字
+var constraintKeywords = map[string]bool{
+	"PRIMARY": true, "KEY": true, "INDEX": true, "UNIQUE": true, "CONSTRAINT": true,
+	"FOREIGN": true, "CHECK": true, "FULLTEXT": true, "SPATIAL": true, "EXCLUDE": true,
+}
+
+// schemaTable 解析后的建表语句
+type schemaTable struct {
+	statement   string
+	columns     map[string]string // 列名对应的完整定义
+	order       []string          // 列的定义顺序
+	constraints map[string]bool   // 表内约束及索引定义
+}
+
+// parsedSchema 解析后的表结构
+type parsedSchema struct {
+	tables map[string]*schemaTable
+	others map[string]string // 表以外的语句，如索引、视图及触发器，按规范化的文本索引
+}
+
+// Diff 生成将 current 变更为 desired 的候选 sql，两者均为 migrate.DumpSchema 格式的表结构，无差异时返回空；
+// dialect 用于生成修改列定义的语句，仅支持 MySQL 及 Postgres
+func Diff(current, desired string, dialect migrate.Dialect) string {
+	from, to := parseSchema(current), parseSchema(desired)
+	var name string
+	if named, ok := dialect.(migrate.NamedDialect); ok {
+		name = named.Name()
+	}
+	var statements []string
+	// 1.删除的索引、视图及触发器，在删表之前
+	for _, key := range sortedKeys(from.others) {
+		if _, ok := to.others[key]; !ok {
+			statements = append(statements, dropStatement(from.others[key]))
+		}
+	}
+	// 2.新增及修改的表
+	for _, table := range sortedKeys(to.tables) {
+		target := to.tables[table]
+		source, ok := from.tables[table]
+		if !ok {
+			statements = append(statements, target.statement)
+			continue
+		}
+		statements = append(statements, diffTable(table, source, target, name)...)
+	}
+	// 3.删除的表
+	for _, table := range sortedKeys(from.tables) {
+		if _, ok := to.tables[table]; !ok {
+			statements = append(statements, fmt.Sprintf("DROP TABLE %s", table))
+		}
+	}
+	// 4.新增的索引、视图及触发器，在建表之后
+	for _, key := range sortedKeys(to.others) {
+		if _, ok := from.others[key]; !ok {
+			statements = append(statements, to.others[key])
+		}
+	}
+	var b strings.Builder
+	for _, statement := range statements {
+		if strings.HasPrefix(statement, todoPrefix) {
+			b.WriteString(statement + "\n")
+			continue
+		}
+		b.WriteString(statement + ";\n")
+	}
+	return b.String()
+}
+
+const todoPrefix = "-- TODO "
+
+// diffTable 比较同一张表的列及约束
+func diffTable(table string, source, target *schemaTable, dialect string) []string {
+	var statements []string
+	for _, column := range target.order {
+		definition := target.columns[column]
+		old, ok := source.columns[column]
+		switch {
+		case !ok:
+			statements = append(statements, fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s", table, definition))
+		case normalize(old) != normalize(definition):
+			statements = append(statements, modifyColumn(table, column, old, definition, dialect))
+		}
+	}
+	for _, column := range source.order {
+		if _, ok := target.columns[column]; !ok {
+			statements = append(statements, fmt.Sprintf("ALTER TABLE %s DROP COLUMN %s", table, column))
+		}
+	}
+	for _, constraint := range sortedKeys(target.constraints) {
+		if !source.constraints[constraint] {
+			statements = append(statements, fmt.Sprintf("ALTER TABLE %s ADD %s", table, constraint))
+		}
+	}
+	for _, constraint := range sortedKeys(source.constraints) {
+		if !target.constraints[constraint] {
+			statements = append(statements, dropConstraint(table, constraint))
+		}
+	}
+	return statements
+}
+
+// modifyColumn 生成修改列定义的语句，MySQL 使用 MODIFY COLUMN，Postgres 仅能生成类型修改
+func modifyColumn(table, column, old, definition, dialect string) string {
+	switch dialect {
+	case "mysql":
+		return fmt.Sprintf("ALTER TABLE %s MODIFY COLUMN %s", table, definition)
+	case "postgres":
+		fields := strings.Fields(definition)
+		oldFields := strings.Fields(old)
+		if len(fields) >= 2 && len(oldFields) >= 2 && strings.Join(fields[2:], " ") == strings.Join(oldFields[2:], " ") {
+			return fmt.Sprintf("ALTER TABLE %s ALTER COLUMN %s TYPE %s", table, column, fields[1])
+		}
+	}
+	return fmt.Sprintf("%scolumn %s.%s changed from `%s` to `%s`", todoPrefix, table, column, old, definition)
+}
+
+// dropConstraint 生成删除表内约束的语句，无法确定名称时输出 TODO
+func dropConstraint(table, constraint string) string {
+	fields := strings.Fields(constraint)
+	upper := strings.Fields(strings.ToUpper(constraint))
+	switch {
+	case len(fields) >= 2 && upper[0] == "CONSTRAINT":
+		return fmt.Sprintf("ALTER TABLE %s DROP CONSTRAINT %s", table, fields[1])
+	case len(fields) >= 2 && (upper[0] == "KEY" || upper[0] == "INDEX") && fields[1] != "(":
+		return fmt.Sprintf("ALTER TABLE %s DROP INDEX %s", table, fields[1])
+	case len(fields) >= 3 && upper[0] != "PRIMARY" && (upper[1] == "KEY" || upper[1] == "INDEX") && !strings.HasPrefix(fields[2], "("):
+		return fmt.Sprintf("ALTER TABLE %s DROP INDEX %s", table, fields[2])
+	case len(upper) >= 2 && upper[0] == "PRIMARY":
+		return fmt.Sprintf("ALTER TABLE %s DROP PRIMARY KEY", table)
+	}
+	return fmt.Sprintf("%sremove `%s` from table %s", todoPrefix, constraint, table)
+}
+
+// dropStatement 生成删除索引、视图及触发器的语句，无法识别时输出 TODO
+func dropStatement(statement string) string {
+	tokens := strings.Fields(statement)
+	upper := strings.Fields(strings.ToUpper(statement))
+	for i := 1; i < len(upper)-1 && i < 4; i++ {
+		switch upper[i] {
+		case "INDEX", "VIEW", "TRIGGER":
+			// CREATE INDEX IF NOT EXISTS name 时名称在 IF NOT EXISTS 之后，删除语句保留 IF EXISTS
+			if upper[i+1] == "IF" && i+4 < len(tokens) {
+				return fmt.Sprintf("DROP %s IF EXISTS %s", upper[i], tokens[i+4])
+			}
+			return fmt.Sprintf("DROP %s %s", upper[i], tokens[i+1])
+		}
+	}
+	return todoPrefix + "remove " + snippet(statement)
+}
+
+// parseSchema 解析表结构中的建表语句，其他语句按文本保存
+func parseSchema(schema string) *parsedSchema {
+	parsed := &parsedSchema{tables: make(map[string]*schemaTable), others: make(map[string]string)}
+	streamStatements(strings.NewReader(schema), func(stmt statement) error {
+		if table, name, ok := parseTable(stmt.query); ok {
+			parsed.tables[name] = table
+		} else {
+			parsed.others[normalize(stmt.query)] = strings.TrimSpace(stmt.query)
+		}
+		return nil
+	})
+	return parsed
+}
+
+// parseTable 解析 CREATE TABLE 语句的列及表内约束
+func parseTable(query string) (*schemaTable, string, bool) {
+	fields := strings.Fields(query)
+	if len(fields) < 3 || !strings.EqualFold(fields[0], "CREATE") || !strings.EqualFold(fields[1], "TABLE") {
+		return nil, "", false
+	}
+	open, end := strings.IndexByte(query, '('), strings.LastIndexByte(query, ')')
+	if open < 0 || end < open {
+		return nil, "", false
+	}
+	name := strings.TrimSpace(query[len(fields[0])+1 : open])
+	name = strings.TrimSpace(name[len(fields[1]):])
+	if upper := strings.ToUpper(name); strings.HasPrefix(upper, "IF NOT EXISTS ") {
+		name = strings.TrimSpace(name[len("IF NOT EXISTS "):])
+	}
+	table := &schemaTable{statement: strings.TrimSpace(query), columns: make(map[string]string), constraints: make(map[string]bool)}
+	for _, item := range splitItems(query[open+1 : end]) {
+		itemFields := strings.Fields(item)
+		if len(itemFields) == 0 {
+			continue
+		}
+		if constraintKeywords[strings.ToUpper(itemFields[0])] {
+			table.constraints[normalize(item)] = true
+			continue
+		}
+		column := strings.Trim(itemFields[0], "`\"")
+		table.columns[column] = normalize(item)
+		table.order = append(table.order, column)
+	}
+	return table, strings.Trim(name, "`\""), true
+}
+
+// splitItems 按括号及字符串外的逗号拆分表定义
+func splitItems(body string) []string {
+	var items []string
+	depth, start := 0, 0
+	for i := 0; i < len(body); i++ {
+		if end := quoteEnd(body, i); end > i {
+			i = end - 1
+			continue
+		}
+		switch body[i] {
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case ',':
+			if depth == 0 {
+				items = append(items, strings.TrimSpace(body[start:i]))
+				start = i + 1
+			}
+		}
+	}
+	return append(items, strings.TrimSpace(body[start:]))
+}
+
+// normalize 合并空白，用于比较
+func normalize(query string) string {
+	return strings.Join(strings.Fields(query), " ")
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,21 @@
+package concrete
+
+import "testing"
+
+func TestDropStatement(t *testing.T) {
+	cases := []struct {
+		statement string
+		expected  string
+	}{
+		{"CREATE INDEX idx_t ON t (id)", "DROP INDEX idx_t"},
+		{"CREATE UNIQUE INDEX idx_t ON t (id)", "DROP INDEX idx_t"},
+		{"CREATE INDEX IF NOT EXISTS idx_t ON t (id)", "DROP INDEX IF EXISTS idx_t"},
+		{"CREATE VIEW IF NOT EXISTS v AS SELECT id FROM t", "DROP VIEW IF EXISTS v"},
+		{"CREATE TRIGGER tr AFTER INSERT ON t BEGIN SELECT 1; END", "DROP TRIGGER tr"},
+	}
+	for _, c := range cases {
+		if actual := dropStatement(c.statement); actual != c.expected {
+			t.Errorf("dropStatement(%q) = %q, expected %q", c.statement, actual, c.expected)
+		}
+	}
+}
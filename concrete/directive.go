@@ -0,0 +1,123 @@
+package concrete
+
+import (
+	"bufio"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"powerlaw.ai/powerlib/migrate"
+)
+
+/*
+sql 文件指令，以 "-- +migrate" 开头的注释行，例如：
+-- +migrate Timeout 10m
+-- +migrate NoTransaction
+-- +migrate Throttle 50
+-- +migrate DependsOn 3 5
+-- +migrate Baseline
+-- +migrate Optional
+*/
+
+var (
+	ErrDirective = errors.New("directive is illegal")
+)
+
+const (
+	directivePrefix = "-- +migrate "
+
+	directiveTimeout       = "Timeout"
+	directiveNoTransaction = "NoTransaction"
+	directiveThrottle      = "Throttle"
+	directiveDependsOn     = "DependsOn"
+	directiveBaseline      = "Baseline"
+	directiveOptional      = "Optional"
+)
+
+// directives sql 文件中声明的指令
+type directives struct {
+	timeout   time.Duration // 执行超时时间
+	noTx      bool          // 是否在事务外执行
+	throttle  float64       // 每秒最多执行的语句数，0 表示使用 migrate 的配置
+	dependsOn []int         // 依赖的处理程序索引，nil 表示依赖所有之前的处理程序
+	baseline  bool          // 是否为压缩而成的基线
+	optional  bool          // 是否为可选处理程序
+}
+
+// parseDirectives 逐行读取 sql 文件并解析其中的指令
+func parseDirectives(r io.Reader) (directives, error) {
+	var d directives
+	reader := bufio.NewReader(r)
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil && err != io.EOF {
+			return d, errors.WithStack(err)
+		}
+		if err == io.EOF && line == "" {
+			return d, nil
+		}
+		line = strings.TrimSpace(line)
+		if !strings.HasPrefix(line, directivePrefix) {
+			continue
+		}
+		fields := strings.Fields(strings.TrimPrefix(line, directivePrefix))
+		if len(fields) == 0 {
+			return d, errors.Wrap(ErrDirective, line)
+		}
+		switch fields[0] {
+		case directiveTimeout:
+			if len(fields) != 2 {
+				return d, errors.Wrap(ErrDirective, line)
+			}
+			timeout, err := time.ParseDuration(fields[1])
+			if err != nil {
+				return d, errors.Wrap(ErrDirective, line)
+			}
+			d.timeout = timeout
+		case directiveNoTransaction:
+			if len(fields) != 1 {
+				return d, errors.Wrap(ErrDirective, line)
+			}
+			d.noTx = true
+		case directiveThrottle:
+			if len(fields) != 2 {
+				return d, errors.Wrap(ErrDirective, line)
+			}
+			throttle, err := strconv.ParseFloat(fields[1], 64)
+			if err != nil || throttle <= 0 {
+				return d, errors.Wrap(ErrDirective, line)
+			}
+			d.throttle = throttle
+		case directiveBaseline:
+			if len(fields) != 1 {
+				return d, errors.Wrap(ErrDirective, line)
+			}
+			d.baseline = true
+		case directiveOptional:
+			if len(fields) != 1 {
+				return d, errors.Wrap(ErrDirective, line)
+			}
+			d.optional = true
+		case directiveDependsOn:
+			d.dependsOn = []int{}
+			for _, field := range fields[1:] {
+				index, err := strconv.Atoi(field)
+				if err != nil {
+					return d, errors.Wrap(ErrDirective, line)
+				}
+				d.dependsOn = append(d.dependsOn, index)
+			}
+		}
+	}
+}
+
+// throttler 返回 Throttle 指令对应的限流器，未声明时为空
+func (d directives) throttler() migrate.Throttler {
+	if d.throttle <= 0 {
+		return nil
+	}
+	return migrate.NewRateThrottler(d.throttle)
+}
@@ -0,0 +1,71 @@
+package concrete
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	// maxStatementSnippet 错误信息中语句的最大长度，避免大语句导致错误信息不可读
+	maxStatementSnippet = 200
+)
+
+// StatementError sql 语句执行失败，包含文件名、语句序号及起始行号
+type StatementError struct {
+	File      string // 文件名
+	Statement int    // 语句在文件中的序号，从 1 开始
+	Line      int    // 语句在文件中的起始行号，从 1 开始
+	Query     string // 单行的语句内容，超出长度时截断
+	Err       error
+}
+
+// NewStatementError 返回第 statement 条语句执行失败的错误，语句合并为单行并截断过长的部分
+func NewStatementError(file string, statement, line int, query string, err error) *StatementError {
+	return &StatementError{File: file, Statement: statement, Line: line, Query: snippet(query), Err: err}
+}
+
+func (e *StatementError) Error() string {
+	return e.File + ": " + e.ErrorWithoutSource()
+}
+
+// GetSource 返回语句所在的文件名
+func (e *StatementError) GetSource() string {
+	return e.File
+}
+
+// ErrorWithoutSource 返回不含文件名的错误信息，由 migrate.HandlerError 包装时使用
+func (e *StatementError) ErrorWithoutSource() string {
+	return fmt.Sprintf("statement %d at line %d: %v, sql is : %s", e.Statement, e.Line, e.Err, e.Query)
+}
+
+func (e *StatementError) Unwrap() error {
+	return e.Err
+}
+
+// snippet 将语句合并为单行并截断过长的部分
+func snippet(query string) string {
+	query = strings.Join(strings.Fields(query), " ")
+	if len(query) <= maxStatementSnippet {
+		return query
+	}
+	return query[:maxStatementSnippet] + "..."
+}
+
+// ShellError 外部命令执行失败，包含退出码及输出的末尾部分
+type ShellError struct {
+	Command  string // 执行的命令
+	ExitCode int    // 退出码，命令未能启动或被终止时为 -1
+	Output   string // stdout 及 stderr 合并后的末尾部分
+	Err      error
+}
+
+func (e *ShellError) Error() string {
+	if e.Output == "" {
+		return fmt.Sprintf("command %q exited with %d: %v", e.Command, e.ExitCode, e.Err)
+	}
+	return fmt.Sprintf("command %q exited with %d: %v, output is : %s", e.Command, e.ExitCode, e.Err, e.Output)
+}
+
+func (e *ShellError) Unwrap() error {
+	return e.Err
+}
@@ -0,0 +1,26 @@
+package concrete
+
+import (
+	"testing"
+
+	"github.com/pkg/errors"
+
+	"powerlaw.ai/powerlib/migrate"
+)
+
+func TestHandlerErrorSource(t *testing.T) {
+	cause := errors.WithStack(NewStatementError("0042_x.sql", 2, 2, "CREATE TABLE a (id INTEGER)", errors.New("table a already exists")))
+	cases := []struct {
+		source   string
+		expected string
+	}{
+		{"0042_x.sql", "migration 0042_x.sql failed: statement 2 at line 2: table a already exists, sql is : CREATE TABLE a (id INTEGER)"},
+		{"", "migration 42_x failed: 0042_x.sql: statement 2 at line 2: table a already exists, sql is : CREATE TABLE a (id INTEGER)"},
+	}
+	for _, c := range cases {
+		err := &migrate.HandlerError{Index: 42, Name: "x", Source: c.source, Direction: migrate.DirectionUp, Err: cause}
+		if actual := err.Error(); actual != c.expected {
+			t.Errorf("HandlerError with source %q = %q, expected %q", c.source, actual, c.expected)
+		}
+	}
+}
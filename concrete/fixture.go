@@ -0,0 +1,239 @@
+package concrete
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"powerlaw.ai/powerlib/migrate"
+)
+
+/*
+数据文件处理程序，将 csv 或 json 文件批量插入指定的表，用于导入基础数据；
+csv 首行为列名，json 为对象数组，字段名默认即列名，可通过 WithColumnMapping 映射
+*/
+
+const (
+	csvExt  = ".csv"
+	jsonExt = ".json"
+
+	defaultBatchSize = 500
+
+	insertFixtureFormat = "INSERT INTO %s (%s) VALUES %s"
+)
+
+var (
+	ErrFixtureEmpty = errors.New("fixture file has no columns")
+)
+
+// Placeholder 返回第 n 个参数的占位符，n 从 1 开始
+type Placeholder func(n int) string
+
+var (
+	// QuestionPlaceholder MySQL、SQLite 的 ? 占位符
+	QuestionPlaceholder Placeholder = func(int) string { return "?" }
+	// DollarPlaceholder Postgres 的 $n 占位符
+	DollarPlaceholder Placeholder = func(n int) string { return fmt.Sprintf("$%d", n) }
+)
+
+// fixtureHandler 将数据文件插入 table
+type fixtureHandler struct {
+	baseHandler
+	db    migrate.DBTX
+	fsys  fs.FS
+	file  string
+	table string
+
+	mapping     map[string]string // 文件字段名对应的列名
+	batchSize   int
+	placeholder Placeholder
+}
+
+// FixtureOption 数据文件处理程序选项
+type FixtureOption func(f *fixtureHandler)
+
+// NewFixtureHandler 读取 fsys 中的 csv 或 json 文件 file，在事务中批量插入 table，文件名即处理程序名称
+func NewFixtureHandler(index int, db migrate.DBTX, fsys fs.FS, file, table string, options ...FixtureOption) migrate.Handler {
+	handler := &fixtureHandler{
+		baseHandler: baseHandler{index: index, name: strings.TrimSuffix(path.Base(file), path.Ext(file))},
+		db:          db,
+		fsys:        fsys,
+		file:        file,
+		table:       table,
+		batchSize:   defaultBatchSize,
+		placeholder: QuestionPlaceholder,
+	}
+	for _, option := range options {
+		option(handler)
+	}
+	return handler
+}
+
+// WithColumnMapping 将文件字段名映射为列名，未映射的字段使用原名
+func WithColumnMapping(mapping map[string]string) FixtureOption {
+	return func(f *fixtureHandler) {
+		f.mapping = mapping
+	}
+}
+
+// WithBatchSize 指定每条 insert 语句插入的行数，默认为 500
+func WithBatchSize(size int) FixtureOption {
+	return func(f *fixtureHandler) {
+		if size > 0 {
+			f.batchSize = size
+		}
+	}
+}
+
+// WithPlaceholder 指定参数占位符，默认为 QuestionPlaceholder，Postgres 需使用 DollarPlaceholder
+func WithPlaceholder(placeholder Placeholder) FixtureOption {
+	return func(f *fixtureHandler) {
+		f.placeholder = placeholder
+	}
+}
+
+func (f *fixtureHandler) GetChecksum() string {
+	content, err := fs.ReadFile(f.fsys, f.file)
+	if err != nil {
+		return ""
+	}
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
+
+func (f *fixtureHandler) Exec(ctx context.Context) error {
+	return execInTx(ctx, f.db, f.ExecTx)
+}
+
+func (f *fixtureHandler) ExecTx(ctx context.Context, tx *sql.Tx) error {
+	// 1.读取数据文件
+	content, err := fs.ReadFile(f.fsys, f.file)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	var columns []string
+	var rows [][]any
+	switch path.Ext(f.file) {
+	case csvExt:
+		columns, rows, err = readCSV(content)
+	case jsonExt:
+		columns, rows, err = readJSON(content)
+	default:
+		return errors.Wrap(ErrFileType, f.file)
+	}
+	if err != nil {
+		return errors.WithMessage(err, f.file)
+	}
+	if len(columns) == 0 {
+		return errors.Wrap(ErrFixtureEmpty, f.file)
+	}
+	// 2.映射列名
+	for i, column := range columns {
+		if mapped, ok := f.mapping[column]; ok {
+			columns[i] = mapped
+		}
+	}
+	// 3.分批插入
+	for start := 0; start < len(rows); start += f.batchSize {
+		batch := rows[start:min(start+f.batchSize, len(rows))]
+		query, args := f.insertQuery(columns, batch)
+		_, err = tx.ExecContext(ctx, query, args...)
+		if err != nil {
+			return errors.WithMessagef(err, "insert rows %d-%d of %s", start+1, start+len(batch), f.file)
+		}
+	}
+	return nil
+}
+
+// insertQuery 生成批量插入语句及参数
+func (f *fixtureHandler) insertQuery(columns []string, rows [][]any) (string, []any) {
+	values := make([]string, 0, len(rows))
+	args := make([]any, 0, len(rows)*len(columns))
+	for _, row := range rows {
+		placeholders := make([]string, len(row))
+		for i, value := range row {
+			args = append(args, value)
+			placeholders[i] = f.placeholder(len(args))
+		}
+		values = append(values, "("+strings.Join(placeholders, ", ")+")")
+	}
+	return fmt.Sprintf(insertFixtureFormat, f.table, strings.Join(columns, ", "), strings.Join(values, ", ")), args
+}
+
+// readCSV 读取 csv 文件，首行为列名
+func readCSV(content []byte) ([]string, [][]any, error) {
+	records, err := csv.NewReader(bytes.NewReader(content)).ReadAll()
+	if err != nil {
+		return nil, nil, errors.WithStack(err)
+	}
+	if len(records) == 0 {
+		return nil, nil, nil
+	}
+	rows := make([][]any, 0, len(records)-1)
+	for _, record := range records[1:] {
+		row := make([]any, len(record))
+		for i, value := range record {
+			row[i] = value
+		}
+		rows = append(rows, row)
+	}
+	return records[0], rows, nil
+}
+
+// readJSON 读取 json 对象数组，列为所有对象字段的并集，缺少的字段插入 NULL
+func readJSON(content []byte) ([]string, [][]any, error) {
+	decoder := json.NewDecoder(bytes.NewReader(content))
+	decoder.UseNumber()
+	var objects []map[string]any
+	err := decoder.Decode(&objects)
+	if err != nil {
+		return nil, nil, errors.WithStack(err)
+	}
+	keys := make(map[string]bool)
+	for _, object := range objects {
+		for key := range object {
+			keys[key] = true
+		}
+	}
+	columns := make([]string, 0, len(keys))
+	for key := range keys {
+		columns = append(columns, key)
+	}
+	sort.Strings(columns)
+	rows := make([][]any, 0, len(objects))
+	for _, object := range objects {
+		row := make([]any, len(columns))
+		for i, column := range columns {
+			row[i] = jsonValue(object[column])
+		}
+		rows = append(rows, row)
+	}
+	return columns, rows, nil
+}
+
+// jsonValue 将 json 值转换为驱动支持的类型，对象及数组以 json 字符串插入
+func jsonValue(value any) any {
+	switch v := value.(type) {
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return i
+		}
+		f, _ := v.Float64()
+		return f
+	case map[string]any, []any:
+		b, _ := json.Marshal(v)
+		return string(b)
+	}
+	return value
+}
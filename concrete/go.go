@@ -2,9 +2,18 @@ package concrete
 
 import (
 	"context"
+	"database/sql"
+	"time"
+
+	"github.com/pkg/errors"
+
 	"powerlaw.ai/powerlib/migrate"
 )
 
+var (
+	ErrTxRequired = errors.New("go tx handler must be executed in a transaction")
+)
+
 // goExecutor 用于存储 go 处理单元
 type goExecutor struct {
 	handlers []GoHandler
@@ -19,7 +28,16 @@ func NewGoExecutor(handlers ...GoHandler) migrate.Executor {
 func (g *goExecutor) ListHandlers() ([]migrate.Handler, error) {
 	var handlers []migrate.Handler
 	for idx := range g.handlers {
-		handlers = append(handlers, &g.handlers[idx])
+		handler := &g.handlers[idx]
+		switch {
+		case handler.txExecutor != nil:
+			handlers = append(handlers, goTxHandler{GoHandler: handler})
+			continue
+		case handler.downExecutor != nil:
+			handlers = append(handlers, goDownHandler{GoHandler: handler})
+			continue
+		}
+		handlers = append(handlers, handler)
 	}
 	return handlers, nil
 }
@@ -27,18 +45,109 @@ func (g *goExecutor) ListHandlers() ([]migrate.Handler, error) {
 // GoHandler 存储具体 go 处理程序
 type GoHandler struct {
 	baseHandler
-	executor GoFunc
+	executor     GoFunc
+	txExecutor   GoTxFunc
+	downExecutor GoFunc // 回滚函数，为空时不支持回滚
+	checksum     string // 校验和，为空时不校验
 }
 
 type GoFunc func(ctx context.Context) error
 
+// GoTxFunc 在 migrate 开启的事务中执行的 go 处理程序，返回错误时事务回滚
+type GoTxFunc func(ctx context.Context, tx *sql.Tx) error
+
 func (g *GoHandler) Exec(ctx context.Context) error {
+	if g.executor == nil {
+		return ErrTxRequired
+	}
 	return g.executor(ctx)
 }
 
 func NewGoHandler(index int, f GoFunc) GoHandler {
 	return GoHandler{
-		baseHandler: baseHandler{index},
+		baseHandler: baseHandler{index: index},
 		executor:    f,
 	}
 }
+
+// NewGoHandlerWithDown 创建可回滚的 go 处理程序，down 用于撤销 up 所做的变更
+func NewGoHandlerWithDown(index int, up, down GoFunc) GoHandler {
+	return GoHandler{
+		baseHandler:  baseHandler{index: index},
+		executor:     up,
+		downExecutor: down,
+	}
+}
+
+// NewGoTxHandler 创建在事务中执行的 go 处理程序，事务由 migrate 开启、提交或回滚，并在同一事务中更新 version
+func NewGoTxHandler(index int, f GoTxFunc) GoHandler {
+	return GoHandler{
+		baseHandler: baseHandler{index: index},
+		txExecutor:  f,
+	}
+}
+
+// WithName 指定处理程序的名称，用于日志、历史表及状态输出
+func (g GoHandler) WithName(name string) GoHandler {
+	g.name = name
+	return g
+}
+
+// WithTimeout 指定处理程序的执行超时时间
+func (g GoHandler) WithTimeout(timeout time.Duration) GoHandler {
+	g.timeout = timeout
+	return g
+}
+
+// WithThrottle 限制处理程序每秒最多执行 maxStatementsPerSecond 次，优先于 migrate.WithThrottle，
+// 处理程序需在每条语句或每批数据前调用 migrate.Throttle
+func (g GoHandler) WithThrottle(maxStatementsPerSecond float64) GoHandler {
+	g.throttler = migrate.NewRateThrottler(maxStatementsPerSecond)
+	return g
+}
+
+// WithChecksum 指定处理程序的校验和，已执行的处理程序校验和变更时拒绝执行，如函数逻辑的版本号
+func (g GoHandler) WithChecksum(checksum string) GoHandler {
+	g.checksum = checksum
+	return g
+}
+
+func (g *GoHandler) GetChecksum() string {
+	return g.checksum
+}
+
+// WithDependsOn 指定依赖的处理程序索引，不传参数时不依赖任何处理程序，未调用时依赖所有之前的处理程序
+func (g GoHandler) WithDependsOn(indexes ...int) GoHandler {
+	g.dependsOn = append([]int{}, indexes...)
+	return g
+}
+
+// WithTags 指定处理程序的标签，仅在 migrate.WithTags 包含任一标签时执行
+func (g GoHandler) WithTags(tags ...string) GoHandler {
+	g.tags = tags
+	return g
+}
+
+// Optional 标记为可选处理程序，执行失败时记录失败历史，不标记 dirty 且继续执行后续处理程序
+func (g GoHandler) Optional() GoHandler {
+	g.optional = true
+	return g
+}
+
+// goTxHandler 在事务中执行的 GoHandler
+type goTxHandler struct {
+	*GoHandler
+}
+
+func (g goTxHandler) ExecTx(ctx context.Context, tx *sql.Tx) error {
+	return g.txExecutor(ctx, tx)
+}
+
+// goDownHandler 可回滚的 GoHandler
+type goDownHandler struct {
+	*GoHandler
+}
+
+func (g goDownHandler) DownExec(ctx context.Context) error {
+	return g.downExecutor(ctx)
+}
@@ -0,0 +1,74 @@
+package concrete
+
+import (
+	"bufio"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+/*
+goose 兼容模式，解析单个文件中的 goose 注解：
+-- +goose Up / -- +goose Down 区分执行及回滚语句，
+-- +goose StatementBegin / -- +goose StatementEnd 及 -- +goose NO TRANSACTION 转换为对应的 -- +migrate 指令
+*/
+
+var (
+	ErrGooseAnnotation = errors.New("goose file has no -- +goose Up annotation")
+)
+
+const (
+	gooseAnnotationPrefix = "-- +goose "
+
+	gooseUp   = "Up"
+	gooseDown = "Down"
+
+	gooseNoTransaction = "NO TRANSACTION"
+)
+
+// parseGoose 按 goose 注解拆分执行及回滚语句，不包含 -- +goose Down 注解时回滚语句为空；
+// 不属于当前方向的行以空行保留，使报错行号与原文件一致
+func parseGoose(content string) (string, string, error) {
+	var up, down strings.Builder
+	var current *strings.Builder
+	hasUp, hasDown := false, false
+	scanner := bufio.NewScanner(strings.NewReader(content))
+	scanner.Buffer(nil, len(content)+1)
+	for scanner.Scan() {
+		line := scanner.Text()
+		target := current
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, gooseAnnotationPrefix) {
+			annotation := strings.TrimSpace(strings.TrimPrefix(trimmed, gooseAnnotationPrefix))
+			switch annotation {
+			case gooseUp:
+				current, hasUp = &up, true
+				line = ""
+			case gooseDown:
+				current, hasDown = &down, true
+				line = ""
+			case directiveStatementBegin, directiveStatementEnd:
+				line = directivePrefix + annotation
+			case gooseNoTransaction:
+				// 通常位于 -- +goose Up 之前，写入执行语句以便解析指令
+				line, target = directivePrefix+directiveNoTransaction, &up
+			}
+		}
+		for _, builder := range []*strings.Builder{&up, &down} {
+			if builder == target {
+				builder.WriteString(line)
+			}
+			builder.WriteString("\n")
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", "", errors.WithStack(err)
+	}
+	if !hasUp {
+		return "", "", ErrGooseAnnotation
+	}
+	if !hasDown {
+		return up.String(), "", nil
+	}
+	return up.String(), down.String(), nil
+}
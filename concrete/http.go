@@ -0,0 +1,176 @@
+package concrete
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+/*
+http 来源，从中心化的迁移仓库拉取清单及 sql 文件；
+清单为 json 格式，列出文件名及其 sha256 校验和，文件内容与校验和不一致时拒绝执行；
+请求使用 ETag/If-None-Match 缓存，内容未变更时复用上次的结果
+*/
+
+const (
+	defaultManifestName = "manifest.json"
+
+	ErrHTTPStatusFormat = "unexpected status %d for %s"
+)
+
+var (
+	ErrFileNotInManifest = errors.New("file is not in manifest")
+	ErrFileChecksum      = errors.New("file checksum mismatch")
+)
+
+// Manifest http 来源的清单
+type Manifest struct {
+	Files []ManifestFile `json:"files"`
+}
+
+// ManifestFile 清单中的单个文件，SHA256 为空时不校验
+type ManifestFile struct {
+	Name   string `json:"name"`
+	SHA256 string `json:"sha256"`
+}
+
+// httpSource 从 baseURL 拉取清单及文件
+type httpSource struct {
+	sync.Mutex
+
+	baseURL  string
+	manifest string
+	client   *http.Client
+	header   http.Header
+
+	checksums map[string]string // 文件名对应清单中的校验和
+	cache     map[string]httpCache
+}
+
+// httpCache 已拉取的内容及其 ETag
+type httpCache struct {
+	etag string
+	body []byte
+}
+
+// HTTPOption http 来源选项
+type HTTPOption func(h *httpSource)
+
+// NewHTTPSource 从 baseURL 拉取清单 manifest.json 及其中列出的 sql 文件
+func NewHTTPSource(baseURL string, options ...HTTPOption) Source {
+	source := &httpSource{
+		baseURL:   baseURL,
+		manifest:  defaultManifestName,
+		client:    http.DefaultClient,
+		header:    make(http.Header),
+		checksums: make(map[string]string),
+		cache:     make(map[string]httpCache),
+	}
+	for _, option := range options {
+		option(source)
+	}
+	return source
+}
+
+// WithHTTPClient 指定 http 客户端，用于配置超时、代理及 TLS
+func WithHTTPClient(client *http.Client) HTTPOption {
+	return func(h *httpSource) {
+		h.client = client
+	}
+}
+
+// WithHTTPHeader 为每个请求添加请求头，如鉴权信息
+func WithHTTPHeader(key, value string) HTTPOption {
+	return func(h *httpSource) {
+		h.header.Add(key, value)
+	}
+}
+
+// WithManifestName 指定清单文件名，默认为 manifest.json
+func WithManifestName(name string) HTTPOption {
+	return func(h *httpSource) {
+		h.manifest = name
+	}
+}
+
+func (h *httpSource) List() ([]File, error) {
+	h.Lock()
+	defer h.Unlock()
+	body, err := h.fetch(h.manifest)
+	if err != nil {
+		return nil, err
+	}
+	var manifest Manifest
+	err = json.Unmarshal(body, &manifest)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	h.checksums = make(map[string]string, len(manifest.Files))
+	files := make([]File, 0, len(manifest.Files))
+	for _, f := range manifest.Files {
+		h.checksums[f.Name] = f.SHA256
+		files = append(files, File{Name: f.Name})
+	}
+	return files, nil
+}
+
+func (h *httpSource) Open(name string) (io.ReadCloser, error) {
+	h.Lock()
+	defer h.Unlock()
+	checksum, ok := h.checksums[name]
+	if !ok {
+		return nil, errors.Wrap(ErrFileNotInManifest, name)
+	}
+	body, err := h.fetch(name)
+	if err != nil {
+		return nil, err
+	}
+	if checksum != "" {
+		sum := sha256.Sum256(body)
+		if hex.EncodeToString(sum[:]) != checksum {
+			return nil, errors.Wrap(ErrFileChecksum, name)
+		}
+	}
+	return io.NopCloser(bytes.NewReader(body)), nil
+}
+
+// fetch 拉取 baseURL 下的 name，服务端返回 304 时使用缓存
+func (h *httpSource) fetch(name string) ([]byte, error) {
+	link, err := url.JoinPath(h.baseURL, name)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	req, err := http.NewRequest(http.MethodGet, link, nil)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	req.Header = h.header.Clone()
+	cached, ok := h.cache[link]
+	if ok && cached.etag != "" {
+		req.Header.Set("If-None-Match", cached.etag)
+	}
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	defer resp.Body.Close()
+	switch {
+	case resp.StatusCode == http.StatusNotModified && ok:
+		return cached.body, nil
+	case resp.StatusCode != http.StatusOK:
+		return nil, errors.Errorf(ErrHTTPStatusFormat, resp.StatusCode, link)
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	h.cache[link] = httpCache{etag: resp.Header.Get("ETag"), body: body}
+	return body, nil
+}
@@ -0,0 +1,298 @@
+package concrete
+
+import (
+	"fmt"
+	"io"
+	"path"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+/*
+零停机发布检查，按 expand/contract 规则检查迁移文件中新旧版本代码无法同时兼容的变更，
+如删除仍在使用的列、新增无默认值的 NOT NULL 列、重命名表或列；
+只做关键字级别的分析，不连接数据库，各规则的严重程度可按环境配置
+*/
+
+// LintRule 检查规则
+type LintRule string
+
+const (
+	RuleDropColumn            LintRule = "drop-column"              // 删除列
+	RuleDropUsedColumn        LintRule = "drop-used-column"         // 删除 WithUsedColumns 声明仍在使用的列
+	RuleDropTable             LintRule = "drop-table"               // 删除表
+	RuleNotNullWithoutDefault LintRule = "not-null-without-default" // 新增或修改为无默认值的 NOT NULL 列
+	RuleRenameTable           LintRule = "rename-table"             // 重命名表
+	RuleRenameColumn          LintRule = "rename-column"            // 重命名列
+	RuleChangeColumnType      LintRule = "change-column-type"       // 修改列类型
+)
+
+// Severity 规则的严重程度
+type Severity string
+
+const (
+	SeverityOff     Severity = "off"
+	SeverityWarning Severity = "warning"
+	SeverityError   Severity = "error"
+)
+
+// defaultSeverities 各规则默认的严重程度
+var defaultSeverities = map[LintRule]Severity{
+	RuleDropColumn:            SeverityWarning,
+	RuleDropUsedColumn:        SeverityError,
+	RuleDropTable:             SeverityWarning,
+	RuleNotNullWithoutDefault: SeverityError,
+	RuleRenameTable:           SeverityError,
+	RuleRenameColumn:          SeverityError,
+	RuleChangeColumnType:      SeverityWarning,
+}
+
+// LintIssue 单条检查结果
+type LintIssue struct {
+	File      string
+	Line      int // 语句在文件中的起始行号，从 1 开始
+	Rule      LintRule
+	Severity  Severity
+	Statement string // 单行的语句内容，超出长度时截断
+}
+
+func (i LintIssue) String() string {
+	return fmt.Sprintf("%s:%d: %s %s: %s", i.File, i.Line, i.Severity, i.Rule, i.Statement)
+}
+
+// Linter 迁移文件检查器
+type Linter struct {
+	severities  map[LintRule]Severity
+	usedColumns map[string]bool // 仍在使用的列，格式为 table.column，小写
+}
+
+// LintOption 检查器选项
+type LintOption func(l *Linter)
+
+func NewLinter(options ...LintOption) *Linter {
+	linter := &Linter{
+		severities:  make(map[LintRule]Severity),
+		usedColumns: make(map[string]bool),
+	}
+	for rule, severity := range defaultSeverities {
+		linter.severities[rule] = severity
+	}
+	for _, option := range options {
+		option(linter)
+	}
+	return linter
+}
+
+// WithSeverity 指定规则的严重程度，SeverityOff 关闭规则，如生产环境将 drop-column 设置为 error
+func WithSeverity(rule LintRule, severity Severity) LintOption {
+	return func(l *Linter) {
+		l.severities[rule] = severity
+	}
+}
+
+// WithUsedColumns 声明当前线上代码仍在使用的列，格式为 table.column，删除这些列时按 drop-used-column 报告
+func WithUsedColumns(columns ...string) LintOption {
+	return func(l *Linter) {
+		for _, column := range columns {
+			l.usedColumns[strings.ToLower(column)] = true
+		}
+	}
+}
+
+// Lint 检查来源中名称为 names 的文件，names 为空时检查全部的执行文件，不检查 .down.sql 回滚文件
+func (l *Linter) Lint(source Source, names ...string) ([]LintIssue, error) {
+	if len(names) == 0 {
+		files, err := source.List()
+		if err != nil {
+			return nil, err
+		}
+		for _, file := range files {
+			names = append(names, file.Name)
+		}
+	}
+	var issues []LintIssue
+	for _, name := range names {
+		trimmed := strings.TrimSuffix(name, gzipExt)
+		if path.Ext(trimmed) != sqlExt || strings.HasSuffix(strings.TrimSuffix(trimmed, sqlExt), downSuffix) {
+			continue
+		}
+		reader, err := openFile(source, name)
+		if err != nil {
+			return nil, err
+		}
+		fileIssues, err := l.LintFile(name, reader)
+		reader.Close()
+		if err != nil {
+			return nil, err
+		}
+		issues = append(issues, fileIssues...)
+	}
+	return issues, nil
+}
+
+// LintFile 流式检查单个文件
+func (l *Linter) LintFile(name string, r io.Reader) ([]LintIssue, error) {
+	var issues []LintIssue
+	err := streamStatements(r, func(stmt statement) error {
+		for _, rule := range l.check(stmt.query) {
+			severity := l.severities[rule]
+			if severity == SeverityOff || severity == "" {
+				continue
+			}
+			issues = append(issues, LintIssue{File: name, Line: stmt.line, Rule: rule, Severity: severity, Statement: snippet(stmt.query)})
+		}
+		return nil
+	})
+	return issues, errors.WithMessage(err, name)
+}
+
+// check 返回单条语句违反的规则
+func (l *Linter) check(query string) []LintRule {
+	tokens := tokenize(query)
+	switch {
+	case len(tokens) >= 2 && tokens[0] == "DROP" && tokens[1] == "TABLE":
+		return []LintRule{RuleDropTable}
+	case len(tokens) >= 2 && tokens[0] == "RENAME" && tokens[1] == "TABLE":
+		return []LintRule{RuleRenameTable}
+	case len(tokens) >= 3 && tokens[0] == "ALTER" && tokens[1] == "TABLE":
+		return l.checkAlter(tokens[2:])
+	}
+	return nil
+}
+
+// checkAlter 按顶层逗号拆分 ALTER TABLE 的子句并逐个检查，tokens 以表名开始
+func (l *Linter) checkAlter(tokens []string) []LintRule {
+	// 1.跳过 IF EXISTS、ONLY 等修饰得到表名
+	for len(tokens) > 0 && (tokens[0] == "IF" || tokens[0] == "EXISTS" || tokens[0] == "ONLY") {
+		tokens = tokens[1:]
+	}
+	if len(tokens) == 0 {
+		return nil
+	}
+	table := unquote(tokens[0])
+	if i := strings.LastIndexByte(table, '.'); i >= 0 {
+		table = table[i+1:]
+	}
+	// 2.逐个子句检查
+	var rules []LintRule
+	for _, clause := range splitClauses(tokens[1:]) {
+		if rule, ok := l.checkClause(table, clause); ok {
+			rules = append(rules, rule)
+		}
+	}
+	return rules
+}
+
+// checkClause 检查 ALTER TABLE 的单个子句
+func (l *Linter) checkClause(table string, clause []string) (LintRule, bool) {
+	if len(clause) < 2 {
+		return "", false
+	}
+	switch clause[0] {
+	case "DROP":
+		if keepDrops[clause[1]] {
+			return "", false
+		}
+		column := skipKeywords(clause[1:], "COLUMN", "IF", "EXISTS")
+		if column != "" && l.usedColumns[table+"."+column] {
+			return RuleDropUsedColumn, true
+		}
+		return RuleDropColumn, true
+	case "RENAME":
+		if clause[1] == "TO" || clause[1] == "AS" {
+			return RuleRenameTable, true
+		}
+		if clause[1] == "COLUMN" || len(clause) >= 3 && clause[2] == "TO" {
+			return RuleRenameColumn, true
+		}
+	case "CHANGE":
+		// MySQL CHANGE old new type，名称不同时为重命名
+		names := clause[1:]
+		if names[0] == "COLUMN" {
+			names = names[1:]
+		}
+		if len(names) >= 2 && unquote(names[0]) != unquote(names[1]) {
+			return RuleRenameColumn, true
+		}
+		if notNullWithoutDefault(clause) {
+			return RuleNotNullWithoutDefault, true
+		}
+		return RuleChangeColumnType, true
+	case "MODIFY":
+		if notNullWithoutDefault(clause) {
+			return RuleNotNullWithoutDefault, true
+		}
+		return RuleChangeColumnType, true
+	case "ADD":
+		if keepDrops[clause[1]] {
+			return "", false
+		}
+		if notNullWithoutDefault(clause) {
+			return RuleNotNullWithoutDefault, true
+		}
+	case "ALTER":
+		// Postgres ALTER COLUMN c SET NOT NULL 或 ALTER COLUMN c TYPE t
+		if contains(clause, "SET") && contains(clause, "NOT") && contains(clause, "NULL") {
+			return RuleNotNullWithoutDefault, true
+		}
+		if contains(clause, "TYPE") {
+			return RuleChangeColumnType, true
+		}
+	}
+	return "", false
+}
+
+// splitClauses 按括号外的逗号拆分子句
+func splitClauses(tokens []string) [][]string {
+	var clauses [][]string
+	depth, start := 0, 0
+	for i, token := range tokens {
+		switch token {
+		case "(":
+			depth++
+		case ")":
+			depth--
+		case ",":
+			if depth == 0 {
+				clauses = append(clauses, tokens[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(clauses, tokens[start:])
+}
+
+// notNullWithoutDefault 判断列定义是否为无默认值的 NOT NULL
+func notNullWithoutDefault(clause []string) bool {
+	for i := 0; i < len(clause)-1; i++ {
+		if clause[i] == "NOT" && clause[i+1] == "NULL" {
+			return !contains(clause, "DEFAULT")
+		}
+	}
+	return false
+}
+
+// skipKeywords 跳过开头的关键字，返回之后的第一个小写的名称
+func skipKeywords(tokens []string, keywords ...string) string {
+	for _, token := range tokens {
+		if !contains(keywords, token) {
+			return unquote(token)
+		}
+	}
+	return ""
+}
+
+// unquote 去除标识符的引号并转为小写
+func unquote(token string) string {
+	return strings.ToLower(strings.Trim(token, "`\""))
+}
+
+func contains(tokens []string, target string) bool {
+	for _, token := range tokens {
+		if token == target {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,112 @@
+package concrete
+
+import (
+	"strconv"
+
+	"github.com/pkg/errors"
+
+	"powerlaw.ai/powerlib/migrate"
+)
+
+/*
+在线 DDL 处理程序，按声明的配置构建 gh-ost 或 pt-online-schema-change 命令并执行，
+使大表的 ALTER 与普通迁移在同一版本序列中管理；工具需预先安装，执行方式及输出处理同 shell 处理程序
+*/
+
+// OnlineDDLTool 在线 DDL 工具
+type OnlineDDLTool string
+
+const (
+	GhOst OnlineDDLTool = "gh-ost"
+	PtOSC OnlineDDLTool = "pt-online-schema-change"
+)
+
+var (
+	ErrOnlineDDLTool = errors.New("online ddl tool is not supported")
+	ErrOnlineDDLSpec = errors.New("online ddl requires database, table and alter")
+)
+
+// OnlineDDL 在线 DDL 配置
+type OnlineDDL struct {
+	Tool      OnlineDDLTool // 使用的工具，默认为 gh-ost
+	Path      string        // 工具的可执行文件路径，默认为工具名
+	Host      string
+	Port      int
+	User      string
+	Password  string // 不包含在 dry-run 输出及校验和中
+	Database  string
+	Table     string
+	Alter     string   // ALTER TABLE 之后的部分，如 ADD COLUMN c INT
+	DownAlter string   // 回滚的 ALTER，为空时不支持回滚
+	ChunkSize int      // 每批复制的行数，0 时使用工具的默认值
+	MaxLoad   string   // 负载阈值，如 Threads_running=25，超出时暂停复制
+	Args      []string // 追加的其他参数，如 --allow-on-master、--no-check-alter
+}
+
+// NewOnlineDDLHandler 创建执行在线 DDL 的处理程序，options 同 NewShellHandler，如 WithShellName、WithShellOutput
+func NewOnlineDDLHandler(index int, spec OnlineDDL, options ...ShellOption) migrate.Handler {
+	handler := &shellHandler{
+		baseHandler: baseHandler{index: index, name: spec.Table},
+		secret:      spec.Password,
+	}
+	handler.args, handler.err = spec.command(spec.Alter)
+	if spec.DownAlter != "" && handler.err == nil {
+		handler.downArgs, handler.err = spec.command(spec.DownAlter)
+	}
+	for _, option := range options {
+		option(handler)
+	}
+	if handler.downArgs != nil {
+		return &shellDownHandler{shellHandler: handler}
+	}
+	return handler
+}
+
+// command 构建执行 alter 的命令及参数
+func (o OnlineDDL) command(alter string) ([]string, error) {
+	if o.Database == "" || o.Table == "" || alter == "" {
+		return nil, errors.WithStack(ErrOnlineDDLSpec)
+	}
+	tool := o.Tool
+	if tool == "" {
+		tool = GhOst
+	}
+	path := o.Path
+	if path == "" {
+		path = string(tool)
+	}
+	args := []string{path}
+	if o.Host != "" {
+		args = append(args, "--host="+o.Host)
+	}
+	if o.Port != 0 {
+		args = append(args, "--port="+strconv.Itoa(o.Port))
+	}
+	if o.User != "" {
+		args = append(args, "--user="+o.User)
+	}
+	if o.Password != "" {
+		args = append(args, "--password="+o.Password)
+	}
+	switch tool {
+	case GhOst:
+		args = append(args, "--database="+o.Database, "--table="+o.Table, "--alter="+alter)
+	case PtOSC:
+		args = append(args, "--alter="+alter)
+	default:
+		return nil, errors.Wrap(ErrOnlineDDLTool, string(tool))
+	}
+	if o.ChunkSize > 0 {
+		args = append(args, "--chunk-size="+strconv.Itoa(o.ChunkSize))
+	}
+	if o.MaxLoad != "" {
+		args = append(args, "--max-load="+o.MaxLoad)
+	}
+	args = append(args, o.Args...)
+	args = append(args, "--execute")
+	if tool == PtOSC {
+		// pt-osc 以 DSN 指定库表
+		args = append(args, "D="+o.Database+",t="+o.Table)
+	}
+	return args, nil
+}
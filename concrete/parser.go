@@ -0,0 +1,40 @@
+package concrete
+
+import (
+	"github.com/pkg/errors"
+)
+
+/*
+sql 语法校验，Validate 时按配置的解析器逐条解析执行及回滚语句，不连接数据库；
+解析器与数据库相关，如 parser/mysql 包，其他数据库可自行实现 SQLParser
+*/
+
+// SQLParser sql 语法解析器
+type SQLParser interface {
+	// Parse 解析单条 sql 语句，语法错误时返回错误
+	Parse(query string) error
+}
+
+// WithParser 指定 Validate 时使用的 sql 语法解析器，未指定时不校验语法
+func WithParser(parser SQLParser) SQLOption {
+	return func(s *sqlExecutor) {
+		s.parser = parser
+	}
+}
+
+// validate 流式读取文件并逐条解析，返回第一条语法错误的语句
+func (f sqlFile) validate(parser SQLParser) error {
+	file, err := f.open()
+	if err != nil {
+		return errors.WithMessage(err, f.name)
+	}
+	defer file.Close()
+	i := 0
+	return streamStatements(file, func(stmt statement) error {
+		i++
+		if err := parser.Parse(stmt.query); err != nil {
+			return errors.WithStack(NewStatementError(f.name, i, stmt.line, stmt.query, err))
+		}
+		return nil
+	})
+}
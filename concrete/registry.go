@@ -0,0 +1,70 @@
+package concrete
+
+import (
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"sync"
+
+	"powerlaw.ai/powerlib/migrate"
+)
+
+/*
+全局注册，每个迁移一个 go 文件，在文件的 init 中调用 Register 注册处理程序，由 NewRegistryExecutor 统一收集；
+处理程序名称取自调用文件名，如 0005_add_users.go 的名称为 add_users，索引重复时由 migrate 加载时报错
+*/
+
+// goFilePattern 迁移 go 文件名，如 0005_add_users.go
+var goFilePattern = regexp.MustCompile(`^\d+_(.+)\.go$`)
+
+// registry 已注册的 go 处理程序
+var registry struct {
+	mutex    sync.Mutex
+	handlers []GoHandler
+}
+
+// Register 注册 go 处理程序，down 为空时不支持回滚，应在迁移文件的 init 中调用
+func Register(index int, up, down GoFunc) {
+	handler := NewGoHandler(index, up)
+	if down != nil {
+		handler = NewGoHandlerWithDown(index, up, down)
+	}
+	register(handler)
+}
+
+// RegisterTx 注册在事务中执行的 go 处理程序，应在迁移文件的 init 中调用
+func RegisterTx(index int, f GoTxFunc) {
+	register(NewGoTxHandler(index, f))
+}
+
+// RegisterHandler 注册已配置的 go 处理程序，如指定了标签或超时时间，未指定名称时取自调用文件名
+func RegisterHandler(handler GoHandler) {
+	register(handler)
+}
+
+// register 以调用 Register 的文件名补全名称后加入注册表
+func register(handler GoHandler) {
+	if handler.name == "" {
+		// 调用栈为 register、Register* 及迁移文件的 init
+		if _, file, _, ok := runtime.Caller(2); ok {
+			if match := goFilePattern.FindStringSubmatch(filepath.Base(file)); match != nil {
+				handler.name = match[1]
+			}
+		}
+	}
+	registry.mutex.Lock()
+	defer registry.mutex.Unlock()
+	registry.handlers = append(registry.handlers, handler)
+}
+
+// Registered 返回已注册的 go 处理程序
+func Registered() []GoHandler {
+	registry.mutex.Lock()
+	defer registry.mutex.Unlock()
+	return append([]GoHandler(nil), registry.handlers...)
+}
+
+// NewRegistryExecutor 创建执行全部已注册处理程序的执行器，需在迁移包的 init 执行之后调用，即导入迁移包之后
+func NewRegistryExecutor() migrate.Executor {
+	return NewGoExecutor(Registered()...)
+}
@@ -0,0 +1,133 @@
+package concrete
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"path"
+	"reflect"
+	"runtime"
+
+	"github.com/pkg/errors"
+
+	"powerlaw.ai/powerlib/migrate"
+)
+
+/*
+自动编号，按添加顺序从锚点开始依次分配索引，无需在代码中维护索引字面量；
+名称的 sha256 作为校验和，在已执行的处理程序之前插入新处理程序导致索引错位时，迁移因校验和不一致拒绝执行
+*/
+
+var (
+	ErrSequenceName     = errors.New("sequence handler name is empty or duplicate")
+	ErrSequenceAnchor   = errors.New("sequence anchor is not greater than the previous index")
+	ErrSequenceMismatch = errors.New("sequence does not match the expected indexes")
+)
+
+// Sequence 自动编号的 go 处理程序序列
+type Sequence struct {
+	next     int
+	handlers []GoHandler
+	err      error // 添加时的错误，ListHandlers 及 Verify 时返回
+}
+
+// NewSequence 创建首个索引为 start 的序列，start 通常为已有迁移的最大索引加 1
+func NewSequence(start int) *Sequence {
+	return &Sequence{next: start}
+}
+
+// NewGoExecutorAuto 创建从 start 开始依次编号的 go 执行器，名称取自函数名，应使用具名函数而非匿名函数
+func NewGoExecutorAuto(start int, handlers ...GoFunc) migrate.Executor {
+	s := NewSequence(start)
+	for _, f := range handlers {
+		s.Add(funcName(f), f)
+	}
+	return s
+}
+
+// Anchor 指定下一个处理程序的索引，需大于之前的索引，用于跳过其他执行器占用的索引
+func (s *Sequence) Anchor(index int) *Sequence {
+	if len(s.handlers) > 0 && index <= s.handlers[len(s.handlers)-1].index && s.err == nil {
+		s.err = errors.Wrapf(ErrSequenceAnchor, "anchor %d", index)
+	}
+	s.next = index
+	return s
+}
+
+// Add 添加处理程序，名称需唯一且添加后不可修改
+func (s *Sequence) Add(name string, up GoFunc) *Sequence {
+	return s.AddHandler(NewGoHandler(0, up).WithName(name))
+}
+
+// AddWithDown 添加可回滚的处理程序
+func (s *Sequence) AddWithDown(name string, up, down GoFunc) *Sequence {
+	return s.AddHandler(NewGoHandlerWithDown(0, up, down).WithName(name))
+}
+
+// AddTx 添加在事务中执行的处理程序
+func (s *Sequence) AddTx(name string, f GoTxFunc) *Sequence {
+	return s.AddHandler(NewGoTxHandler(0, f).WithName(name))
+}
+
+// AddHandler 添加已配置名称的处理程序，其索引被替换为序列分配的索引
+func (s *Sequence) AddHandler(handler GoHandler) *Sequence {
+	if s.err == nil && (handler.name == "" || s.indexOf(handler.name) >= 0) {
+		s.err = errors.Wrapf(ErrSequenceName, "%q at %d", handler.name, s.next)
+	}
+	handler.index = s.next
+	if handler.checksum == "" {
+		sum := sha256.Sum256([]byte(handler.name))
+		handler.checksum = hex.EncodeToString(sum[:])
+	}
+	s.handlers = append(s.handlers, handler)
+	s.next++
+	return s
+}
+
+// Indexes 返回分配的索引及名称，可在测试中固定后配合 Verify 使用
+func (s *Sequence) Indexes() map[int]string {
+	indexes := make(map[int]string, len(s.handlers))
+	for _, handler := range s.handlers {
+		indexes[handler.index] = handler.name
+	}
+	return indexes
+}
+
+// Verify 校验序列无误，且 expected 中的索引均分配给了相同名称的处理程序，用于在测试中发现插入或删除导致的索引错位
+func (s *Sequence) Verify(expected map[int]string) error {
+	if s.err != nil {
+		return s.err
+	}
+	indexes := s.Indexes()
+	for index, name := range expected {
+		if indexes[index] != name {
+			return errors.Wrapf(ErrSequenceMismatch, "index %d is %q, expected %q", index, indexes[index], name)
+		}
+	}
+	return nil
+}
+
+func (s *Sequence) ListHandlers() ([]migrate.Handler, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+	return NewGoExecutor(s.handlers...).ListHandlers()
+}
+
+// indexOf 返回名称对应的位置，不存在时返回 -1
+func (s *Sequence) indexOf(name string) int {
+	for i, handler := range s.handlers {
+		if handler.name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// funcName 返回不含导入路径的函数名称，如 migrations.addUsers
+func funcName(f GoFunc) string {
+	fn := runtime.FuncForPC(reflect.ValueOf(f).Pointer())
+	if fn == nil {
+		return ""
+	}
+	return path.Base(fn.Name())
+}
@@ -0,0 +1,200 @@
+package concrete
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"powerlaw.ai/powerlib/migrate"
+)
+
+/*
+shell 处理程序，以 sh -c 执行外部命令作为迁移步骤，如 pt-online-schema-change、mysqldump；
+stdout 及 stderr 合并捕获，退出码非 0 时返回 ShellError 并标记 dirty
+*/
+
+const (
+	// maxShellOutput 错误信息中保留的输出末尾字节数
+	maxShellOutput = 4096
+	// shellWaitDelay ctx 结束后等待子进程关闭输出的时间
+	shellWaitDelay = 10 * time.Second
+)
+
+// shellHandler 执行外部命令的处理程序
+type shellHandler struct {
+	baseHandler
+	script   string
+	down     string   // 回滚命令，为空时不支持回滚
+	args     []string // 不经 sh 直接执行的命令及参数，优先于 script
+	downArgs []string // 不经 sh 直接执行的回滚命令及参数
+	secret   string   // 输出命令时需隐藏的密码
+	err      error    // 构建命令时的错误，执行时返回
+	dir      string
+	env      []string
+	output   io.Writer
+}
+
+// ShellOption shell 处理程序选项
+type ShellOption func(s *shellHandler)
+
+// NewShellHandler 创建以 sh -c 执行 script 的处理程序，继承当前进程的环境变量
+func NewShellHandler(index int, script string, options ...ShellOption) migrate.Handler {
+	handler := &shellHandler{
+		baseHandler: baseHandler{index: index},
+		script:      script,
+	}
+	for _, option := range options {
+		option(handler)
+	}
+	if handler.down != "" || handler.downArgs != nil {
+		return &shellDownHandler{shellHandler: handler}
+	}
+	return handler
+}
+
+// WithShellName 指定处理程序的名称，用于日志、历史表及状态输出
+func WithShellName(name string) ShellOption {
+	return func(s *shellHandler) {
+		s.name = name
+	}
+}
+
+// WithShellDown 指定回滚命令
+func WithShellDown(script string) ShellOption {
+	return func(s *shellHandler) {
+		s.down = script
+	}
+}
+
+// WithShellDir 指定命令的工作目录，默认为当前目录
+func WithShellDir(dir string) ShellOption {
+	return func(s *shellHandler) {
+		s.dir = dir
+	}
+}
+
+// WithShellEnv 追加环境变量，格式为 KEY=value，如数据库密码
+func WithShellEnv(env ...string) ShellOption {
+	return func(s *shellHandler) {
+		s.env = append(s.env, env...)
+	}
+}
+
+// WithShellOutput 将命令输出同时写入 w，用于实时查看长时间运行命令的进度
+func WithShellOutput(w io.Writer) ShellOption {
+	return func(s *shellHandler) {
+		s.output = w
+	}
+}
+
+// WithShellTimeout 指定命令超时时间，超时后终止命令，优先于 migrate.WithHandlerTimeout
+func WithShellTimeout(timeout time.Duration) ShellOption {
+	return func(s *shellHandler) {
+		s.timeout = timeout
+	}
+}
+
+// WithShellTags 指定处理程序的标签，仅在 migrate.WithTags 包含任一标签时执行
+func WithShellTags(tags ...string) ShellOption {
+	return func(s *shellHandler) {
+		s.tags = tags
+	}
+}
+
+func (s *shellHandler) Exec(ctx context.Context) error {
+	return s.run(ctx, s.script, s.args)
+}
+
+// GetQuery 返回命令，用于 dry-run，密码以 *** 代替
+func (s *shellHandler) GetQuery() string {
+	return s.format(s.script, s.args)
+}
+
+// GetChecksum 返回命令的 sha256，已执行的命令变更时拒绝执行，不包含密码
+func (s *shellHandler) GetChecksum() string {
+	sum := sha256.Sum256([]byte(s.GetQuery()))
+	return hex.EncodeToString(sum[:])
+}
+
+// format 返回可读的命令，args 中的参数按 shell 规则加引号
+func (s *shellHandler) format(script string, args []string) string {
+	if args == nil {
+		return script
+	}
+	quoted := make([]string, 0, len(args))
+	for _, arg := range args {
+		if s.secret != "" {
+			arg = strings.ReplaceAll(arg, s.secret, "***")
+		}
+		quoted = append(quoted, shellQuote(arg))
+	}
+	return strings.Join(quoted, " ")
+}
+
+// shellQuote 为包含特殊字符的参数加单引号
+func shellQuote(arg string) string {
+	if arg != "" && strings.Trim(arg, "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789-_=.,/:@*") == "" {
+		return arg
+	}
+	return "'" + strings.ReplaceAll(arg, "'", `'"'"'`) + "'"
+}
+
+// run 执行命令并捕获输出，退出码非 0 时返回 ShellError
+func (s *shellHandler) run(ctx context.Context, script string, args []string) error {
+	if s.err != nil {
+		return s.err
+	}
+	var output bytes.Buffer
+	cmd := exec.CommandContext(ctx, "sh", "-c", script)
+	if args != nil {
+		cmd = exec.CommandContext(ctx, args[0], args[1:]...)
+	}
+	cmd.Dir = s.dir
+	cmd.Env = append(os.Environ(), s.env...)
+	cmd.WaitDelay = shellWaitDelay
+	cmd.Stdout, cmd.Stderr = &output, &output
+	if s.output != nil {
+		w := io.MultiWriter(&output, s.output)
+		cmd.Stdout, cmd.Stderr = w, w
+	}
+	err := cmd.Run()
+	if err == nil {
+		return nil
+	}
+	exitCode := -1
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		exitCode = exitErr.ExitCode()
+	}
+	return errors.WithStack(&ShellError{Command: s.format(script, args), ExitCode: exitCode, Output: tail(output.String()), Err: err})
+}
+
+// tail 返回输出的末尾部分，命令失败的原因通常在最后
+func tail(output string) string {
+	output = strings.TrimSpace(output)
+	if len(output) <= maxShellOutput {
+		return output
+	}
+	return "..." + output[len(output)-maxShellOutput:]
+}
+
+// shellDownHandler 可回滚的 shell 处理程序
+type shellDownHandler struct {
+	*shellHandler
+}
+
+func (s *shellDownHandler) DownExec(ctx context.Context) error {
+	return s.run(ctx, s.down, s.downArgs)
+}
+
+func (s *shellDownHandler) GetDownQuery() string {
+	return s.format(s.down, s.downArgs)
+}
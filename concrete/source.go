@@ -0,0 +1,113 @@
+package concrete
+
+import (
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+/*
+Source sql 文件来源，可由本地目录、embed.FS、zip 压缩包或远程存储实现
+*/
+
+type Source interface {
+	// List 返回来源中的所有文件
+	List() ([]File, error)
+	// Open 打开 List 返回的文件
+	Open(name string) (io.ReadCloser, error)
+}
+
+// File 来源中的单个文件
+type File struct {
+	Name string
+}
+
+// fsSource 从 fs.FS 的 root 目录读取文件
+type fsSource struct {
+	fsys fs.FS
+	root string
+
+	skipDirs  bool // 是否忽略子目录，默认遇到子目录时报错
+	recursive bool // 是否递归读取子目录，文件名为相对 root 的路径
+}
+
+// FSOption 文件系统来源选项
+type FSOption func(f *fsSource)
+
+// NewFSSource 从 fsys 的 root 目录读取文件，zip.Reader、embed.FS 等均实现了 fs.FS
+func NewFSSource(fsys fs.FS, root string, options ...FSOption) Source {
+	source := &fsSource{fsys: fsys, root: path.Clean("./" + root)}
+	for _, option := range options {
+		option(source)
+	}
+	return source
+}
+
+// NewDirSource 从本地目录读取文件
+func NewDirSource(dir string, options ...FSOption) Source {
+	if dir == "" {
+		dir = defaultSourceDir
+	}
+	return NewFSSource(os.DirFS(dir), ".", options...)
+}
+
+// WithSkipDirs 忽略子目录
+func WithSkipDirs() FSOption {
+	return func(f *fsSource) {
+		f.skipDirs = true
+	}
+}
+
+// WithRecursive 递归读取子目录，可按年份或业务域组织迁移文件，索引在所有目录中需唯一
+func WithRecursive() FSOption {
+	return func(f *fsSource) {
+		f.recursive = true
+	}
+}
+
+func (f *fsSource) List() ([]File, error) {
+	if f.recursive {
+		return f.walk()
+	}
+	entries, err := fs.ReadDir(f.fsys, f.root)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	var files []File
+	for _, entry := range entries {
+		if entry.IsDir() {
+			if f.skipDirs {
+				continue
+			}
+			return nil, ErrFileType
+		}
+		files = append(files, File{Name: entry.Name()})
+	}
+	return files, nil
+}
+
+// walk 递归读取 root 目录下的所有文件
+func (f *fsSource) walk() ([]File, error) {
+	var files []File
+	err := fs.WalkDir(f.fsys, f.root, func(name string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return err
+		}
+		rel := name
+		if f.root != "." {
+			rel = strings.TrimPrefix(name, f.root+"/")
+		}
+		files = append(files, File{Name: rel})
+		return nil
+	})
+	return files, errors.WithStack(err)
+}
+
+func (f *fsSource) Open(name string) (io.ReadCloser, error) {
+	file, err := f.fsys.Open(path.Join(f.root, name))
+	return file, errors.WithStack(err)
+}
@@ -0,0 +1,276 @@
+package concrete
+
+import (
+	"bufio"
+	"io"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+/*
+sql 语句拆分，按分号拆分多条语句，忽略字符串、引用标识符、注释及 Postgres $$ 字符串中的分号；
+支持 MySQL DELIMITER 切换分隔符，以及 -- +migrate StatementBegin/StatementEnd 包裹的整段语句，
+用于触发器、函数及存储过程
+*/
+
+const (
+	defaultDelimiter = ";"
+	delimiterCommand = "DELIMITER"
+
+	directiveStatementBegin = "StatementBegin"
+	directiveStatementEnd   = "StatementEnd"
+
+	streamChunkSize = 64 * 1024
+)
+
+// statement 单条 sql 语句
+type statement struct {
+	query string
+	line  int // 语句在文件中的起始行号，从 1 开始
+}
+
+// splitter 可分段输入的 sql 语句拆分器，保存分隔符、语句块等跨段的状态
+type splitter struct {
+	line      int    // 下一段起始处的行号
+	delimiter string // 当前分隔符
+	inBlock   bool   // 是否处于 StatementBegin/StatementEnd 之间
+	midLine   bool   // 下一段起始处是否位于行中
+}
+
+func newSplitter() *splitter {
+	return &splitter{line: 1, delimiter: defaultDelimiter}
+}
+
+// streamStatements 从 r 分段读取并拆分 sql 语句后逐条回调，仅包含空白或注释的语句会被忽略，
+// 内存占用取决于单条语句的大小而非文件大小
+func streamStatements(r io.Reader, f func(stmt statement) error) error {
+	reader := bufio.NewReaderSize(r, streamChunkSize)
+	s := newSplitter()
+	var pending string
+	for {
+		chunk, err := readChunk(reader)
+		if err != nil && err != io.EOF {
+			return errors.WithStack(err)
+		}
+		final := err == io.EOF
+		pending += chunk
+		statements, consumed := s.split(pending, final)
+		for _, stmt := range statements {
+			if err := f(stmt); err != nil {
+				return err
+			}
+		}
+		if final {
+			return nil
+		}
+		pending = pending[consumed:]
+	}
+}
+
+// StreamStatements 从 r 逐条拆分 sql 语句后回调 f，line 为语句的起始行号，可用于拆分 CQL 等分号分隔的语句
+func StreamStatements(r io.Reader, f func(query string, line int) error) error {
+	return streamStatements(r, func(stmt statement) error {
+		return f(stmt.query, stmt.line)
+	})
+}
+
+// readChunk 读取至少 streamChunkSize 字节并补齐至行尾，读取到文件末尾时返回 io.EOF
+func readChunk(r *bufio.Reader) (string, error) {
+	buf := make([]byte, streamChunkSize)
+	n, err := io.ReadFull(r, buf)
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return string(buf[:n]), io.EOF
+	}
+	if err != nil {
+		return "", err
+	}
+	rest, err := r.ReadString('\n')
+	return string(buf[:n]) + rest, err
+}
+
+// split 拆分 query 中完整的语句，返回语句及已处理的长度，未处理的部分需与下一段拼接后再次输入；
+// final 为 true 表示输入结束，剩余内容作为最后一条语句；
+// 非最后一段需以换行结尾，保证 DELIMITER 命令及注释完整
+func (s *splitter) split(query string, final bool) ([]statement, int) {
+	var statements []statement
+	var current strings.Builder
+	line, startLine := s.line, 0
+	delimiter, inBlock := s.delimiter, s.inBlock
+	consumed := 0
+	// flush 结束当前语句，并保存已处理位置的状态
+	flush := func(end int) {
+		if startLine > 0 {
+			statements = append(statements, statement{
+				query: strings.TrimSpace(current.String()),
+				line:  startLine,
+			})
+		}
+		current.Reset()
+		startLine = 0
+		consumed = end
+		s.line, s.delimiter, s.inBlock = line, delimiter, inBlock
+		s.midLine = end > 0 && query[end-1] != '\n'
+	}
+
+	for i := 0; i < len(query); {
+		c := query[i]
+		// 注释
+		if end := commentEnd(query, i); end > i {
+			line += strings.Count(query[i:end], "\n")
+			switch directiveOf(query[i:end]) {
+			case directiveStatementBegin:
+				flush(end)
+				inBlock = true
+				s.inBlock = true
+			case directiveStatementEnd:
+				flush(end)
+				inBlock = false
+				s.inBlock = false
+			default:
+				current.WriteString(query[i:end])
+			}
+			i = end
+			continue
+		}
+		// DELIMITER 命令，仅在行首生效且不发送到数据库
+		if !inBlock && s.atLineStart(query, i) && hasPrefixFold(query[i:], delimiterCommand+" ") {
+			end := strings.IndexByte(query[i:], '\n')
+			if end < 0 {
+				end = len(query) - i
+			}
+			if fields := strings.Fields(query[i : i+end]); len(fields) == 2 {
+				flush(i)
+				delimiter = fields[1]
+				i += end
+				flush(i)
+				continue
+			}
+		}
+		if !inBlock && strings.HasPrefix(query[i:], delimiter) {
+			i += len(delimiter)
+			flush(i)
+			continue
+		}
+		if startLine == 0 && !isSpace(c) {
+			startLine = line
+		}
+		// 字符串及引用标识符
+		if end := quoteEnd(query, i); end > i {
+			current.WriteString(query[i:end])
+			line += strings.Count(query[i:end], "\n")
+			i = end
+			continue
+		}
+		if c == '\n' {
+			line++
+		}
+		current.WriteByte(c)
+		i++
+	}
+	if final {
+		flush(len(query))
+	}
+	return statements, consumed
+}
+
+// directiveOf 返回注释中的 -- +migrate 指令名称，不是指令时返回空
+func directiveOf(comment string) string {
+	comment = strings.TrimSpace(comment)
+	if !strings.HasPrefix(comment, directivePrefix) {
+		return ""
+	}
+	fields := strings.Fields(strings.TrimPrefix(comment, directivePrefix))
+	if len(fields) == 0 {
+		return ""
+	}
+	return fields[0]
+}
+
+// atLineStart 判断 i 之前的同行内容是否均为空白，包括上一段未换行的内容
+func (s *splitter) atLineStart(query string, i int) bool {
+	for j := i - 1; j >= 0; j-- {
+		if query[j] == '\n' {
+			return true
+		}
+		if !isSpace(query[j]) {
+			return false
+		}
+	}
+	return !s.midLine
+}
+
+func hasPrefixFold(s, prefix string) bool {
+	return len(s) >= len(prefix) && strings.EqualFold(s[:len(prefix)], prefix)
+}
+
+// commentEnd 返回从 i 开始的注释结束位置，i 处不是注释时返回 i
+func commentEnd(query string, i int) int {
+	switch {
+	case strings.HasPrefix(query[i:], "--"):
+		end := strings.IndexByte(query[i:], '\n')
+		if end < 0 {
+			return len(query)
+		}
+		return i + end
+	case strings.HasPrefix(query[i:], "/*"):
+		end := strings.Index(query[i+2:], "*/")
+		if end < 0 {
+			return len(query)
+		}
+		return i + 2 + end + 2
+	}
+	return i
+}
+
+// quoteEnd 返回从 i 开始的字符串或引用标识符结束位置，i 处不是引号时返回 i
+func quoteEnd(query string, i int) int {
+	switch c := query[i]; c {
+	case '\'', '"', '`':
+		for j := i + 1; j < len(query); j++ {
+			switch query[j] {
+			case '\\':
+				// MySQL 反斜杠转义
+				if c != '`' {
+					j++
+				}
+			case c:
+				// 连续两个引号表示转义
+				if j+1 < len(query) && query[j+1] == c {
+					j++
+					continue
+				}
+				return j + 1
+			}
+		}
+		return len(query)
+	case '$':
+		// Postgres $tag$ 字符串
+		tagEnd := strings.IndexByte(query[i+1:], '$')
+		if tagEnd < 0 || !isDollarTag(query[i+1:i+1+tagEnd]) {
+			return i
+		}
+		tag := query[i : i+1+tagEnd+1]
+		end := strings.Index(query[i+len(tag):], tag)
+		if end < 0 {
+			return len(query)
+		}
+		return i + len(tag) + end + len(tag)
+	}
+	return i
+}
+
+// isDollarTag 判断是否为合法的 $tag$ 标签，标签可为空
+func isDollarTag(tag string) bool {
+	for idx, r := range tag {
+		if r == '_' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || idx > 0 && r >= '0' && r <= '9' {
+			continue
+		}
+		return false
+	}
+	return true
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r'
+}
@@ -1,12 +1,15 @@
 package concrete
 
 import (
-	"bytes"
+	"compress/gzip"
 	"context"
+	"crypto/sha256"
 	"database/sql"
+	"encoding/hex"
 	"io"
-	"os"
+	"io/fs"
 	"path"
+	"regexp"
 	"strconv"
 	"strings"
 	"sync"
@@ -19,35 +22,95 @@ import (
 var (
 	ErrFileType = errors.New("file type is not supported")
 	ErrFileName = errors.New("file name is illegal")
-)
 
-const (
-	sqlErrorFmt = "error sql is : %s"
+	ErrFileDuplicate = errors.New("file index is duplicate")
+	ErrDownWithoutUp = errors.New("down file has no matching up file")
 )
 
 const (
 	defaultSourceDir = "./migration"
+	defaultSeedDir   = "./seed"
+
+	sqlExt  = ".sql"
+	gzipExt = ".gz"
+
+	repeatablePrefix = "R__"
+	tagSeparator     = "+"
 
-	sqlExt = ".sql"
+	upSuffix   = ".up"
+	downSuffix = ".down"
+
+	patternIndex = "index"
+	patternName  = "name"
 )
 
-// sqlExecutor 存储具体 db 连接，sql 处理单元，读取文件的目录
+// FlywayPattern Flyway 风格的文件名，如 V12__add_users.sql
+var FlywayPattern = regexp.MustCompile(`^V(?P<index>\d+)__(?P<name>.+)$`)
+
+// sqlExecutor 存储具体 db 连接，sql 处理单元及 sql 文件来源
 type sqlExecutor struct {
 	sync.Mutex
 
-	sourceDir string
-	db        *sql.DB
+	source Source
+	db     migrate.DBTX
+
+	goose   bool           // 是否按 goose 注解解析文件
+	pattern *regexp.Regexp // 解析文件名的正则，为空时按 _ 之前的数字解析索引
+
+	template     bool           // 是否以 text/template 渲染文件
+	templateData map[string]any // 模板数据
+
+	dialect string // 方言名称，用于选择方言专属文件
+
+	parser SQLParser // Validate 时使用的 sql 语法解析器
 
 	handlers []migrate.Handler
 }
 
-func NewSQLExecutor(db *sql.DB, sourceDir string) migrate.Executor {
+// SQLOption sql 运行器选项
+type SQLOption func(s *sqlExecutor)
+
+func NewSQLExecutor(db migrate.DBTX, sourceDir string, options ...SQLOption) migrate.Executor {
+	return NewSQLExecutorSource(db, NewDirSource(sourceDir), options...)
+}
+
+// NewSeedExecutor 读取数据初始化 sql 文件，sourceDir 默认为 ./seed，需配合 migrate.NewSeeder 使用独立的概要表
+func NewSeedExecutor(db migrate.DBTX, sourceDir string, options ...SQLOption) migrate.Executor {
 	if sourceDir == "" {
-		sourceDir = defaultSourceDir
+		sourceDir = defaultSeedDir
 	}
-	return &sqlExecutor{
-		db:        db,
-		sourceDir: sourceDir,
+	return NewSQLExecutor(db, sourceDir, options...)
+}
+
+// NewSQLExecutorFS 从 fsys 的 root 目录读取 sql 文件，可配合 //go:embed 将迁移文件编译进二进制
+func NewSQLExecutorFS(db migrate.DBTX, fsys fs.FS, root string, options ...SQLOption) migrate.Executor {
+	return NewSQLExecutorSource(db, NewFSSource(fsys, root), options...)
+}
+
+// NewSQLExecutorSource 从 source 读取 sql 文件
+func NewSQLExecutorSource(db migrate.DBTX, source Source, options ...SQLOption) migrate.Executor {
+	executor := &sqlExecutor{
+		source: source,
+		db:     db,
+	}
+	for _, option := range options {
+		option(executor)
+	}
+	return executor
+}
+
+// WithGooseMode 兼容 goose 格式，单个文件以 -- +goose Up/Down 注解区分执行及回滚语句
+func WithGooseMode() SQLOption {
+	return func(s *sqlExecutor) {
+		s.goose = true
+	}
+}
+
+// WithFilePattern 指定解析文件名的正则，匹配去除 .up/.down 及扩展名后的文件名，
+// 需包含命名分组 index，可包含命名分组 name，如 FlywayPattern
+func WithFilePattern(pattern *regexp.Regexp) SQLOption {
+	return func(s *sqlExecutor) {
+		s.pattern = pattern
 	}
 }
 
@@ -64,101 +127,569 @@ func (s *sqlExecutor) ListHandlers() ([]migrate.Handler, error) {
 // initHandlers 初始化 sql 处理程序
 func (s *sqlExecutor) initHandlers() error {
 	// 1.读取文件夹中的所有 .sql 文件
-	files, err := getFilesByDir(s.sourceDir)
+	files, err := getFiles(s.source, s.pattern, s.dialect)
 	if err != nil {
-		return nil
+		return err
 	}
-	// 2.每个文件生成一个 sqlHandler
+	files, err = selectDialect(files, s.dialect)
+	if err != nil {
+		return err
+	}
+	// 2.按索引将 up/down 文件配对
+	ups := make(map[int]fileInfo)
+	downs := make(map[int]fileInfo)
+	for _, f := range files {
+		if f.repeatable {
+			if f.down {
+				return errors.Wrap(ErrDownWithoutUp, f.fileName)
+			}
+			continue
+		}
+		group := ups
+		if f.down {
+			group = downs
+		}
+		if _, ok := group[f.index]; ok {
+			return errors.Wrap(ErrFileDuplicate, f.fileName)
+		}
+		group[f.index] = f
+	}
+	for index, f := range downs {
+		if _, ok := ups[index]; !ok {
+			return errors.Wrap(ErrDownWithoutUp, f.fileName)
+		}
+	}
+	// 3.每组文件生成一个 sqlHandler
 	var handlers []migrate.Handler
 	for _, f := range files {
-		// 读取文件信息
-		file, err := os.Open(path.Join(s.sourceDir, f.fileName))
-		if err != nil {
-			return errors.WithStack(err)
+		if f.down {
+			continue
 		}
-		buf := new(bytes.Buffer)
-		_, err = io.Copy(buf, file)
-
-		content := make([]byte, buf.Len())
-		_, err = buf.Read(content)
-		if err != nil {
-			return errors.WithStack(err)
+		if f.repeatable {
+			file := s.newFile(f.fileName)
+			directives, err := file.scan()
+			if err != nil {
+				return errors.WithMessage(err, f.fileName)
+			}
+			handlers = append(handlers, &sqlHandler{
+				baseHandler: baseHandler{name: f.name, timeout: directives.timeout, tags: f.tags, throttler: directives.throttler()},
+				up:          file,
+				db:          s.db,
+				parser:      s.parser,
+				noTx:        directives.noTx,
+				repeatable:  true,
+			})
+			continue
 		}
-		err = file.Close()
+		// goose 模式下回滚语句与执行语句在同一文件中，需预先读取整个文件
+		up := s.newFile(f.fileName)
+		down := s.newFile(f.fileName)
+		downFile, hasDown := downs[f.index]
+		if s.goose {
+			content, err := readFile(s.source, f.fileName)
+			if err != nil {
+				return err
+			}
+			upQuery, downQuery, err := parseGoose(content)
+			if err != nil {
+				return errors.WithMessage(err, f.fileName)
+			}
+			if downQuery != "" && hasDown {
+				return errors.Wrap(ErrFileDuplicate, downFile.fileName)
+			}
+			up = up.withContent(upQuery)
+			if downQuery != "" {
+				down = down.withContent(downQuery)
+			}
+		}
+		if hasDown {
+			down.name = downFile.fileName
+		}
+		upDirectives, err := up.scan()
 		if err != nil {
-			return errors.WithStack(err)
+			return errors.WithMessage(err, up.name)
+		}
+		var downDirectives directives
+		if down.loaded || hasDown {
+			downDirectives, err = down.scan()
+			if err != nil {
+				return errors.WithMessage(err, down.name)
+			}
 		}
 		// 制作 sql 处理程序
-		handlers = append(handlers, &sqlHandler{
-			baseHandler: baseHandler{f.index},
-			query:       string(content),
-			db:          s.db,
+		handler := sqlHandler{
+			baseHandler: baseHandler{index: f.index, name: f.name, timeout: upDirectives.timeout, tags: f.tags, throttler: upDirectives.throttler(),
+				dependsOn: upDirectives.dependsOn, optional: upDirectives.optional},
+			up:       up,
+			db:       s.db,
+			parser:   s.parser,
+			noTx:     upDirectives.noTx || downDirectives.noTx,
+			baseline: upDirectives.baseline,
+		}
+		if !down.loaded && !hasDown {
+			handlers = append(handlers, &handler)
+			continue
+		}
+		handlers = append(handlers, &sqlDownHandler{
+			sqlHandler: handler,
+			down:       down,
 		})
 	}
 	s.handlers = handlers
 	return nil
 }
 
+// openFile 打开文件，.gz 文件读取时解压
+func openFile(source Source, name string) (io.ReadCloser, error) {
+	file, err := source.Open(name)
+	if err != nil || !strings.HasSuffix(name, gzipExt) {
+		return file, err
+	}
+	reader, err := gzip.NewReader(file)
+	if err != nil {
+		file.Close()
+		return nil, errors.Wrap(err, name)
+	}
+	return &gzipFile{Reader: reader, file: file}, nil
+}
+
+// gzipFile 关闭时同时关闭解压器及源文件
+type gzipFile struct {
+	*gzip.Reader
+	file io.ReadCloser
+}
+
+func (g *gzipFile) Close() error {
+	g.Reader.Close()
+	return g.file.Close()
+}
+
+// newFile 创建延迟读取的 sql 文件
+func (s *sqlExecutor) newFile(name string) sqlFile {
+	return sqlFile{source: s.source, name: name, template: s.template, templateData: s.templateData}
+}
+
+// readFile 读取文件全部内容
+func readFile(source Source, name string) (string, error) {
+	file, err := openFile(source, name)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	content, err := io.ReadAll(file)
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+	return string(content), nil
+}
+
+// MaxIndex 返回 fsys 的 root 目录及其子目录下 sql 文件的最大索引，没有 sql 文件时返回 0
+func MaxIndex(fsys fs.FS, root string) (int, error) {
+	files, err := getFiles(NewFSSource(fsys, root, WithRecursive()), nil, "")
+	if err != nil {
+		return 0, err
+	}
+	max := 0
+	for _, f := range files {
+		if f.index > max {
+			max = f.index
+		}
+	}
+	return max, nil
+}
+
+// FilesUpTo 返回 fsys 的 root 目录及其子目录下索引不大于 index 的 sql 文件，不包含可重复执行文件，
+// 文件名为相对 root 的路径，用于压缩历史迁移
+func FilesUpTo(fsys fs.FS, root string, index int) ([]string, error) {
+	files, err := getFiles(NewFSSource(fsys, root, WithRecursive()), nil, "")
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, f := range files {
+		if !f.repeatable && f.index <= index {
+			names = append(names, f.fileName)
+		}
+	}
+	return names, nil
+}
+
 type fileInfo struct {
-	index    int
-	fileName string
-	ext      string
+	index      int
+	name       string // 去除索引前缀、.up/.down 及扩展名后的文件名，如 0001_create_user.up.sql 为 create_user
+	fileName   string
+	ext        string
+	down       bool     // 是否为 .down.sql 回滚文件
+	repeatable bool     // 是否为 R__ 开头的可重复执行文件
+	dialect    string   // 方言专属文件的方言名称，通用文件为空
+	tags       []string // 文件名中 + 之后的标签
 }
 
-// getFilesByDir 获取目录下所有的 .sql 文件
-func getFilesByDir(dir string) ([]fileInfo, error) {
-	dirs, err := os.ReadDir(dir)
+// getFiles 获取来源中所有的 .sql 及 .sql.gz 文件，pattern 为空时按 _ 之前的数字解析索引，
+// dialect 为内置方言外需识别的方言名称
+func getFiles(source Source, pattern *regexp.Regexp, dialect string) ([]fileInfo, error) {
+	files, err := source.List()
 	if err != nil {
 		return nil, err
 	}
 
 	var fileInfos []fileInfo
-	for _, dir := range dirs {
-		if dir.IsDir() {
-			return nil, ErrFileType
-		}
-		fileName := dir.Name()
-		ext := path.Ext(fileName)
+	for _, file := range files {
+		fileName := file.Name
+		// .sql.gz 文件按去除 .gz 后的文件名解析
+		trimmed := strings.TrimSuffix(fileName, gzipExt)
+		ext := path.Ext(trimmed)
 		// 忽略所有非 .sql 文件
 		if ext != sqlExt {
 			continue
 		}
-		nameSplit := strings.Split(fileName, "_")
-
-		num, err := strconv.ParseInt(nameSplit[0], 10, 64)
+		// 子目录中的文件按文件名解析索引
+		base := strings.TrimSuffix(path.Base(trimmed), ext)
+		// 方言后缀可位于 .up/.down 之前或之后，如 0001_name.mysql.up.sql 或 0001_name.up.mysql.sql
+		base, fileDialect := trimDialect(base, dialect)
+		down := strings.HasSuffix(base, downSuffix)
+		base = strings.TrimSuffix(strings.TrimSuffix(base, downSuffix), upSuffix)
+		if fileDialect == "" {
+			base, fileDialect = trimDialect(base, dialect)
+		}
+		// + 之后为标签，如 0005_seed+dev+test.sql
+		base, tagStr, hasTags := strings.Cut(base, tagSeparator)
+		var tags []string
+		if hasTags {
+			tags = strings.Split(tagStr, tagSeparator)
+		}
+		if strings.HasPrefix(base, repeatablePrefix) {
+			fileInfos = append(fileInfos, fileInfo{
+				name:       strings.TrimPrefix(base, repeatablePrefix),
+				fileName:   fileName,
+				ext:        ext,
+				down:       down,
+				repeatable: true,
+				dialect:    fileDialect,
+				tags:       tags,
+			})
+			continue
+		}
+		index, name, err := parseFileName(base, pattern)
 		if err != nil {
-			return nil, ErrFileName
+			return nil, errors.Wrap(err, fileName)
 		}
 		fileInfos = append(fileInfos, fileInfo{
-			index:    int(num),
+			index:    index,
+			name:     name,
 			fileName: fileName,
 			ext:      ext,
+			down:     down,
+			dialect:  fileDialect,
+			tags:     tags,
 		})
 	}
 	return fileInfos, nil
 }
 
-// sqlHandler 包含具体 sql 语句
+// parseFileName 从去除扩展名的文件名中解析索引及名称
+func parseFileName(base string, pattern *regexp.Regexp) (int, string, error) {
+	if pattern == nil {
+		indexStr, name, _ := strings.Cut(base, "_")
+		num, err := strconv.ParseInt(indexStr, 10, 64)
+		if err != nil {
+			return 0, "", ErrFileName
+		}
+		return int(num), name, nil
+	}
+	match := pattern.FindStringSubmatch(base)
+	if match == nil {
+		return 0, "", ErrFileName
+	}
+	var index int
+	var name string
+	for i, group := range pattern.SubexpNames() {
+		switch group {
+		case patternIndex:
+			num, err := strconv.ParseInt(match[i], 10, 64)
+			if err != nil {
+				return 0, "", ErrFileName
+			}
+			index = int(num)
+		case patternName:
+			name = match[i]
+		}
+	}
+	return index, name, nil
+}
+
+// sqlFile sql 文件，执行、计算校验和及输出语句时才从来源读取，不在内存中保存文件内容；
+// goose 模式下保存解析后的语句
+type sqlFile struct {
+	source  Source
+	name    string
+	size    int    // 文件字节数，初始化时解析指令得到
+	content string // 预先读取的语句
+	loaded  bool   // 是否已预先读取
+
+	template     bool           // 是否渲染模板，渲染需读取整个文件
+	templateData map[string]any // 模板数据
+}
+
+// withContent 返回保存预先读取语句的文件
+func (f sqlFile) withContent(content string) sqlFile {
+	f.content, f.loaded = content, true
+	return f
+}
+
+// open 打开渲染后的文件
+func (f sqlFile) open() (io.ReadCloser, error) {
+	if !f.template {
+		return f.openRaw()
+	}
+	content, err := f.readRaw()
+	if err != nil {
+		return nil, err
+	}
+	content, err = renderTemplate(f.name, content, f.templateData)
+	if err != nil {
+		return nil, err
+	}
+	return io.NopCloser(strings.NewReader(content)), nil
+}
+
+// openRaw 打开未渲染的文件，预先读取的语句直接从内存读取
+func (f sqlFile) openRaw() (io.ReadCloser, error) {
+	if f.loaded {
+		return io.NopCloser(strings.NewReader(f.content)), nil
+	}
+	return openFile(f.source, f.name)
+}
+
+// readRaw 读取未渲染的文件全部内容
+func (f sqlFile) readRaw() (string, error) {
+	if f.loaded {
+		return f.content, nil
+	}
+	return readFile(f.source, f.name)
+}
+
+// scan 流式读取文件，解析指令并记录文件字节数
+func (f *sqlFile) scan() (directives, error) {
+	file, err := f.open()
+	if err != nil {
+		return directives{}, err
+	}
+	defer file.Close()
+	counter := &countingReader{reader: file}
+	d, err := parseDirectives(counter)
+	f.size = counter.n
+	return d, err
+}
+
+// read 读取渲染后的文件全部内容，仅用于 dry-run 输出
+func (f sqlFile) read() (string, error) {
+	file, err := f.open()
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	content, err := io.ReadAll(file)
+	return string(content), errors.WithStack(err)
+}
+
+// checksum 流式计算文件内容的 sha256，与对全部内容计算的结果一致，.gz 文件按解压后的内容计算，
+// 模板按渲染前的内容计算，不同环境的模板数据不影响校验和
+func (f sqlFile) checksum() (string, error) {
+	file, err := f.openRaw()
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", errors.WithStack(err)
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// exec 流式读取并逐条执行文件中的 sql 语句
+func (f sqlFile) exec(ctx context.Context, db execer) error {
+	file, err := f.open()
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	return execQuery(ctx, db, f.name, file)
+}
+
+// countingReader 记录已读取字节数的 io.Reader
+type countingReader struct {
+	reader io.Reader
+	n      int
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.reader.Read(p)
+	c.n += n
+	return n, err
+}
+
+// sqlHandler 包含具体 sql 文件
 type sqlHandler struct {
 	baseHandler
-	query string
-	db    *sql.DB
+	up     sqlFile
+	db     migrate.DBTX
+	parser SQLParser // 为空时不校验语法
+
+	noTx       bool // 是否在事务外执行，执行及回滚任一文件声明 NoTransaction 时生效
+	repeatable bool // 是否可重复执行
+	baseline   bool // 是否为压缩而成的基线
 }
 
 func (s *sqlHandler) GetIndex() int {
 	return s.index
 }
 
+// GetQuery 读取执行语句，读取失败时返回错误信息
+func (s *sqlHandler) GetQuery() string {
+	query, err := s.up.read()
+	if err != nil {
+		return err.Error()
+	}
+	return query
+}
+
+// GetQuerySize 返回 sql 文件字节数，避免为统计长度读取整个文件
+func (s *sqlHandler) GetQuerySize(direction string) int {
+	return s.up.size
+}
+
+// GetSource 返回 sql 文件名
+func (s *sqlHandler) GetSource(direction string) string {
+	return s.up.name
+}
+
+// GetChecksum 流式计算文件校验和，读取失败时返回空，不做校验，执行时将报告读取错误
+func (s *sqlHandler) GetChecksum() string {
+	checksum, _ := s.up.checksum()
+	return checksum
+}
+
+// GetDestructive 流式检查执行语句，返回第一条破坏性语句
+func (s *sqlHandler) GetDestructive() (string, error) {
+	return s.up.destructive()
+}
+
+// Validate 解析执行语句的语法
+func (s *sqlHandler) Validate() error {
+	if s.parser == nil {
+		return nil
+	}
+	return s.up.validate(s.parser)
+}
+
+func (s *sqlHandler) Repeatable() bool {
+	return s.repeatable
+}
+
+func (s *sqlHandler) IsBaseline() bool {
+	return s.baseline
+}
+
+func (s *sqlHandler) NoTx() bool {
+	return s.noTx
+}
+
 func (s *sqlHandler) Exec(ctx context.Context) error {
-	tx, err := s.db.Begin()
+	if s.noTx {
+		return s.up.exec(ctx, s.db)
+	}
+	return execInTx(ctx, s.db, s.ExecTx)
+}
+
+func (s *sqlHandler) ExecTx(ctx context.Context, tx *sql.Tx) error {
+	return s.up.exec(ctx, tx)
+}
+
+// sqlDownHandler 包含回滚 sql 文件的 sqlHandler
+type sqlDownHandler struct {
+	sqlHandler
+	down sqlFile
+}
+
+// GetDownQuery 读取回滚语句，读取失败时返回错误信息
+func (s *sqlDownHandler) GetDownQuery() string {
+	query, err := s.down.read()
+	if err != nil {
+		return err.Error()
+	}
+	return query
+}
+
+func (s *sqlDownHandler) GetQuerySize(direction string) int {
+	if direction == migrate.DirectionDown {
+		return s.down.size
+	}
+	return s.up.size
+}
+
+func (s *sqlDownHandler) GetSource(direction string) string {
+	if direction == migrate.DirectionDown {
+		return s.down.name
+	}
+	return s.up.name
+}
+
+// Validate 解析执行及回滚语句的语法
+func (s *sqlDownHandler) Validate() error {
+	if s.parser == nil {
+		return nil
+	}
+	if err := s.up.validate(s.parser); err != nil {
+		return err
+	}
+	return s.down.validate(s.parser)
+}
+
+func (s *sqlDownHandler) DownExec(ctx context.Context) error {
+	if s.noTx {
+		return s.down.exec(ctx, s.db)
+	}
+	return execInTx(ctx, s.db, s.DownExecTx)
+}
+
+func (s *sqlDownHandler) DownExecTx(ctx context.Context, tx *sql.Tx) error {
+	return s.down.exec(ctx, tx)
+}
+
+// execInTx 开启事务执行 f，f 返回错误时回滚
+func execInTx(ctx context.Context, db migrate.DBTX, f func(ctx context.Context, tx *sql.Tx) error) error {
+	tx, err := db.BeginTx(ctx, nil)
 	if err != nil {
 		return errors.WithStack(err)
 	}
-	_, err = tx.Exec(s.query)
+	err = f(ctx, tx)
 	if err != nil {
 		tx.Rollback()
-		return errors.WithMessagef(err, sqlErrorFmt, s.query)
+		return err
 	}
-	tx.Commit()
-	return nil
+	return errors.WithStack(tx.Commit())
+}
+
+// execer 可执行 sql 语句的 migrate.DBTX 或 *sql.Tx
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+// execQuery 从 r 流式读取并逐条执行文件 file 中的 sql 语句，兼容不支持一次执行多条语句的驱动
+func execQuery(ctx context.Context, db execer, file string, r io.Reader) error {
+	i := 0
+	return streamStatements(r, func(stmt statement) error {
+		i++
+		err := migrate.Throttle(ctx)
+		if err != nil {
+			return err
+		}
+		_, err = db.ExecContext(ctx, stmt.query)
+		if err != nil {
+			return errors.WithStack(NewStatementError(file, i, stmt.line, stmt.query, err))
+		}
+		return nil
+	})
 }
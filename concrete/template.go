@@ -0,0 +1,31 @@
+package concrete
+
+import (
+	"strings"
+	"text/template"
+
+	"github.com/Masterminds/sprig/v3"
+	"github.com/pkg/errors"
+)
+
+// WithTemplateData 以 text/template 渲染 sql 文件，data 为模板数据，可使用 sprig 函数，
+// 如 {{ .Schema }} 或 {{ env "REGION" }}，用于不同环境的库名及 schema 名
+func WithTemplateData(data map[string]any) SQLOption {
+	return func(s *sqlExecutor) {
+		s.template = true
+		s.templateData = data
+	}
+}
+
+// renderTemplate 渲染文件 name 的内容，缺少模板变量时报错
+func renderTemplate(name, content string, data map[string]any) (string, error) {
+	tmpl, err := template.New(name).Option("missingkey=error").Funcs(sprig.TxtFuncMap()).Parse(content)
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+	var buf strings.Builder
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", errors.WithStack(err)
+	}
+	return buf.String(), nil
+}
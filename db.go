@@ -0,0 +1,70 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/pkg/errors"
+)
+
+/*
+DBTX 数据库连接抽象，*sql.DB、固定连接的 *sql.Conn 及包装了链路追踪、指标的连接均可使用；
+测试中可通过 FromTx 在外部事务中执行迁移，结束后由调用方回滚，不影响其他测试
+*/
+
+var ErrNestedTx = errors.New("cannot begin a transaction in the transaction passed by FromTx")
+
+// DBTX 迁移使用的数据库连接
+type DBTX interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+	BeginTx(ctx context.Context, opts *sql.TxOptions) (*sql.Tx, error)
+}
+
+// Conner 可获取独占连接的 DBTX，如 *sql.DB；咨询锁及外键检查等会话级别的语句需在同一连接中执行，
+// 未实现时视为已固定连接，如 *sql.Conn
+type Conner interface {
+	Conn(ctx context.Context) (*sql.Conn, error)
+}
+
+// Pinger 可检查连接是否可用的 DBTX，未实现时 WithWaitForDB 不等待
+type Pinger interface {
+	PingContext(ctx context.Context) error
+}
+
+// txDB 调用方传入的事务
+type txDB struct {
+	*sql.Tx
+}
+
+// FromTx 将事务包装为 DBTX，处理程序、version 及历史记录均在 tx 中执行，由调用方提交或回滚；
+// 失败时不标记 dirty，不支持需要自行开启事务的处理程序，与 WithParallelism 同时使用时返回 ErrParallelSession
+func FromTx(tx *sql.Tx) DBTX {
+	return txDB{Tx: tx}
+}
+
+func (txDB) BeginTx(ctx context.Context, opts *sql.TxOptions) (*sql.Tx, error) {
+	return nil, ErrNestedTx
+}
+
+// pinConn 返回固定的连接，db 未实现 Conner 时直接返回 db，release 用于归还连接
+func pinConn(ctx context.Context, db DBTX) (session DBTX, release func(), err error) {
+	conner, ok := db.(Conner)
+	if !ok {
+		return db, func() {}, nil
+	}
+	conn, err := conner.Conn(ctx)
+	if err != nil {
+		return nil, nil, errors.WithStack(err)
+	}
+	return conn, func() { conn.Close() }, nil
+}
+
+// normalizeDB 将值为 nil 的 *sql.DB 视为未指定 db
+func normalizeDB(db DBTX) DBTX {
+	if d, ok := db.(*sql.DB); ok && d == nil {
+		return nil
+	}
+	return db
+}
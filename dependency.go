@@ -0,0 +1,35 @@
+package migrate
+
+import (
+	"github.com/pkg/errors"
+)
+
+/*
+处理程序依赖，处理程序通过 DependentHandler 声明依赖的处理程序，加载时校验依赖存在且索引更小，
+即按索引的执行顺序即为拓扑序；按依赖分层后，同层的处理程序互不依赖，可并发执行
+*/
+
+// dependencyLevels 按依赖对已排序的处理程序拓扑分层，返回每个处理程序所在的层，
+// 处理程序的层为其依赖的最大层加 1，未声明依赖的处理程序依赖所有之前的处理程序
+func dependencyLevels(handlers []Handler) ([]int, error) {
+	positions := make(map[int]int, len(handlers))
+	levels := make([]int, len(handlers))
+	// barrier 之前全部处理程序的最大层
+	barrier := -1
+	for i, handler := range handlers {
+		dependencies, ok := dependenciesOf(handler)
+		if !ok {
+			levels[i] = barrier + 1
+		}
+		for _, dependency := range dependencies {
+			pos, exist := positions[dependency]
+			if !exist {
+				return nil, errors.WithStack(&DependencyError{Index: handler.GetIndex(), Dependency: dependency})
+			}
+			levels[i] = max(levels[i], levels[pos]+1)
+		}
+		positions[handler.GetIndex()] = i
+		barrier = max(barrier, levels[i])
+	}
+	return levels, nil
+}
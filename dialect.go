@@ -0,0 +1,219 @@
+package migrate
+
+import (
+	"context"
+	"fmt"
+)
+
+/*
+Dialect 概要表相关语句，不同数据库的建表语法及占位符不同；
+第三方驱动实现该接口即可接入，无需修改核心代码
+*/
+
+type Dialect interface {
+	// CreateTable 创建概要表，需包含 version 及 dirty 字段
+	CreateTable(table string) string
+	// Select 查询 version 及 dirty 字段
+	Select(table string) string
+	// Update 更新 version，参数为 version
+	Update(table string) string
+	// UpdateDirty 更新 version 及 dirty，参数为 version、dirty
+	UpdateDirty(table string) string
+	// Insert 插入默认概要记录
+	Insert(table string) string
+}
+
+// NamedDialect 具有名称的方言，sql 执行器按名称选择 0001_name.mysql.sql 等方言专属文件
+type NamedDialect interface {
+	Dialect
+	Name() string
+}
+
+var (
+	MySQL Dialect = &formatDialect{
+		name:        "mysql",
+		quote:       "`",
+		createTable: "CREATE TABLE IF NOT EXISTS %s (`version` int NOT NULL DEFAULT 0, `dirty` tinyint(1) NOT NULL DEFAULT 1)",
+		selectQuery: "SELECT `version`, `dirty` FROM %s",
+		update:      "UPDATE %s SET `version` = ?",
+		updateDirty: "UPDATE %s SET `version` = ?, `dirty` = ?",
+		insert:      "INSERT INTO %s (`version`, `dirty`) VALUES (0, 0)",
+		lock:        "SELECT GET_LOCK(CONCAT(DATABASE(), '.', ?), -1)",
+		unlock:      "SELECT RELEASE_LOCK(CONCAT(DATABASE(), '.', ?))",
+		dumpSchema:  dumpMySQL,
+
+		listObjects: "SELECT table_name, table_type FROM information_schema.tables WHERE table_schema = DATABASE()",
+		dropTable:   "DROP TABLE IF EXISTS `%s`",
+		dropView:    "DROP VIEW IF EXISTS `%s`",
+		disableFK:   "SET FOREIGN_KEY_CHECKS = 0",
+		enableFK:    "SET FOREIGN_KEY_CHECKS = 1",
+
+		createHistory:  "CREATE TABLE IF NOT EXISTS %s (`id` bigint NOT NULL AUTO_INCREMENT, `version` int NOT NULL, `name` varchar(255) NOT NULL DEFAULT '', `checksum` varchar(64) NOT NULL DEFAULT '', `direction` varchar(8) NOT NULL DEFAULT 'up', `applied_at` datetime(6) NOT NULL, `duration_ms` bigint NOT NULL DEFAULT 0, `success` tinyint(1) NOT NULL DEFAULT 0, `error_message` text, `hostname` varchar(255) NOT NULL DEFAULT '', `app_version` varchar(255) NOT NULL DEFAULT '', `operator` varchar(255) NOT NULL DEFAULT '', PRIMARY KEY (`id`))",
+		insertHistory:  "INSERT INTO %s (`version`, `name`, `checksum`, `direction`, `applied_at`, `duration_ms`, `success`, `error_message`) VALUES (?, ?, ?, ?, ?, ?, ?, ?)",
+		selectHistory:  "SELECT `version`, `name`, `checksum`, `direction`, `applied_at`, `duration_ms`, `success`, `error_message` FROM %s ORDER BY `id`",
+		updateChecksum: "UPDATE %s SET `checksum` = ? WHERE `version` = ? AND `direction` = 'up'",
+
+		addAuditColumns: []string{
+			"ALTER TABLE %s ADD COLUMN `hostname` varchar(255) NOT NULL DEFAULT '', ADD COLUMN `app_version` varchar(255) NOT NULL DEFAULT '', ADD COLUMN `operator` varchar(255) NOT NULL DEFAULT ''",
+		},
+		insertAuditHistory: "INSERT INTO %s (`version`, `name`, `checksum`, `direction`, `applied_at`, `duration_ms`, `success`, `error_message`, `hostname`, `app_version`, `operator`) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
+
+		createRunning:   "CREATE TABLE IF NOT EXISTS %s (`version` int NOT NULL, `name` varchar(255) NOT NULL DEFAULT '', `direction` varchar(8) NOT NULL DEFAULT 'up', `hostname` varchar(255) NOT NULL DEFAULT '', `started_at` datetime(6) NOT NULL, `heartbeat_at` datetime(6) NOT NULL, PRIMARY KEY (`version`))",
+		insertRunning:   "INSERT INTO %s (`version`, `name`, `direction`, `hostname`, `started_at`, `heartbeat_at`) VALUES (?, ?, ?, ?, ?, ?)",
+		updateHeartbeat: "UPDATE %s SET `heartbeat_at` = ? WHERE `version` = ?",
+		deleteRunning:   "DELETE FROM %s WHERE `version` = ?",
+		selectRunning:   "SELECT `version`, `name`, `direction`, `hostname`, `started_at`, `heartbeat_at` FROM %s ORDER BY `version`",
+
+		createTenant:  "CREATE TABLE IF NOT EXISTS %s (`tenant` varchar(255) NOT NULL, `version` int NOT NULL DEFAULT 0, `dirty` tinyint(1) NOT NULL DEFAULT 0, `error_message` text, `updated_at` datetime(6) NOT NULL, PRIMARY KEY (`tenant`))",
+		deleteTenant:  "DELETE FROM %s WHERE `tenant` = ?",
+		insertTenant:  "INSERT INTO %s (`tenant`, `version`, `dirty`, `error_message`, `updated_at`) VALUES (?, ?, ?, ?, ?)",
+		selectTenants: "SELECT `tenant`, `version`, `dirty`, COALESCE(`error_message`, ''), `updated_at` FROM %s ORDER BY `tenant`",
+	}
+
+	Postgres Dialect = &formatDialect{
+		name:        "postgres",
+		quote:       `"`,
+		createTable: "CREATE TABLE IF NOT EXISTS %s (version integer NOT NULL DEFAULT 0, dirty boolean NOT NULL DEFAULT true)",
+		selectQuery: "SELECT version, dirty FROM %s",
+		update:      "UPDATE %s SET version = $1",
+		updateDirty: "UPDATE %s SET version = $1, dirty = $2",
+		insert:      "INSERT INTO %s (version, dirty) VALUES (0, false)",
+		lock:        "SELECT 1 FROM pg_advisory_lock($1)",
+		unlock:      "SELECT pg_advisory_unlock($1)",
+		numericLock: true,
+		dumpSchema:  dumpPostgres,
+
+		listObjects: "SELECT table_name, table_type FROM information_schema.tables WHERE table_schema = current_schema()",
+		dropTable:   `DROP TABLE IF EXISTS "%s" CASCADE`,
+		dropView:    `DROP VIEW IF EXISTS "%s" CASCADE`,
+
+		createHistory:  "CREATE TABLE IF NOT EXISTS %s (id bigserial PRIMARY KEY, version integer NOT NULL, name varchar(255) NOT NULL DEFAULT '', checksum varchar(64) NOT NULL DEFAULT '', direction varchar(8) NOT NULL DEFAULT 'up', applied_at timestamp NOT NULL, duration_ms bigint NOT NULL DEFAULT 0, success boolean NOT NULL DEFAULT false, error_message text NOT NULL DEFAULT '', hostname varchar(255) NOT NULL DEFAULT '', app_version varchar(255) NOT NULL DEFAULT '', operator varchar(255) NOT NULL DEFAULT '')",
+		insertHistory:  "INSERT INTO %s (version, name, checksum, direction, applied_at, duration_ms, success, error_message) VALUES ($1, $2, $3, $4, $5, $6, $7, $8)",
+		selectHistory:  "SELECT version, name, checksum, direction, applied_at, duration_ms, success, error_message FROM %s ORDER BY id",
+		updateChecksum: "UPDATE %s SET checksum = $1 WHERE version = $2 AND direction = 'up'",
+
+		addAuditColumns: []string{
+			"ALTER TABLE %s ADD COLUMN IF NOT EXISTS hostname varchar(255) NOT NULL DEFAULT '', ADD COLUMN IF NOT EXISTS app_version varchar(255) NOT NULL DEFAULT '', ADD COLUMN IF NOT EXISTS operator varchar(255) NOT NULL DEFAULT ''",
+		},
+		insertAuditHistory: "INSERT INTO %s (version, name, checksum, direction, applied_at, duration_ms, success, error_message, hostname, app_version, operator) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)",
+
+		createRunning:   "CREATE TABLE IF NOT EXISTS %s (version integer PRIMARY KEY, name varchar(255) NOT NULL DEFAULT '', direction varchar(8) NOT NULL DEFAULT 'up', hostname varchar(255) NOT NULL DEFAULT '', started_at timestamp NOT NULL, heartbeat_at timestamp NOT NULL)",
+		insertRunning:   "INSERT INTO %s (version, name, direction, hostname, started_at, heartbeat_at) VALUES ($1, $2, $3, $4, $5, $6)",
+		updateHeartbeat: "UPDATE %s SET heartbeat_at = $1 WHERE version = $2",
+		deleteRunning:   "DELETE FROM %s WHERE version = $1",
+		selectRunning:   "SELECT version, name, direction, hostname, started_at, heartbeat_at FROM %s ORDER BY version",
+
+		createTenant:  "CREATE TABLE IF NOT EXISTS %s (tenant varchar(255) PRIMARY KEY, version integer NOT NULL DEFAULT 0, dirty boolean NOT NULL DEFAULT false, error_message text NOT NULL DEFAULT '', updated_at timestamp NOT NULL)",
+		deleteTenant:  "DELETE FROM %s WHERE tenant = $1",
+		insertTenant:  "INSERT INTO %s (tenant, version, dirty, error_message, updated_at) VALUES ($1, $2, $3, $4, $5)",
+		selectTenants: "SELECT tenant, version, dirty, error_message, updated_at FROM %s ORDER BY tenant",
+	}
+
+	SQLite Dialect = &formatDialect{
+		name:        "sqlite",
+		quote:       `"`,
+		createTable: "CREATE TABLE IF NOT EXISTS %s (version INTEGER NOT NULL DEFAULT 0, dirty BOOLEAN NOT NULL DEFAULT 1)",
+		selectQuery: "SELECT version, dirty FROM %s",
+		update:      "UPDATE %s SET version = ?",
+		updateDirty: "UPDATE %s SET version = ?, dirty = ?",
+		insert:      "INSERT INTO %s (version, dirty) VALUES (0, 0)",
+		dumpSchema:  dumpSQLite,
+
+		listObjects: "SELECT name, upper(type) FROM sqlite_master WHERE type IN ('table', 'view') AND name NOT LIKE 'sqlite_%'",
+		dropTable:   `DROP TABLE IF EXISTS "%s"`,
+		dropView:    `DROP VIEW IF EXISTS "%s"`,
+		disableFK:   "PRAGMA foreign_keys = OFF",
+		enableFK:    "PRAGMA foreign_keys = ON",
+
+		createHistory:  "CREATE TABLE IF NOT EXISTS %s (id INTEGER PRIMARY KEY AUTOINCREMENT, version INTEGER NOT NULL, name TEXT NOT NULL DEFAULT '', checksum TEXT NOT NULL DEFAULT '', direction TEXT NOT NULL DEFAULT 'up', applied_at DATETIME NOT NULL, duration_ms INTEGER NOT NULL DEFAULT 0, success BOOLEAN NOT NULL DEFAULT 0, error_message TEXT NOT NULL DEFAULT '', hostname TEXT NOT NULL DEFAULT '', app_version TEXT NOT NULL DEFAULT '', operator TEXT NOT NULL DEFAULT '')",
+		insertHistory:  "INSERT INTO %s (version, name, checksum, direction, applied_at, duration_ms, success, error_message) VALUES (?, ?, ?, ?, ?, ?, ?, ?)",
+		selectHistory:  "SELECT version, name, checksum, direction, applied_at, duration_ms, success, error_message FROM %s ORDER BY id",
+		updateChecksum: "UPDATE %s SET checksum = ? WHERE version = ? AND direction = 'up'",
+
+		// SQLite 每条语句只能添加一个字段
+		addAuditColumns: []string{
+			"ALTER TABLE %s ADD COLUMN hostname TEXT NOT NULL DEFAULT ''",
+			"ALTER TABLE %s ADD COLUMN app_version TEXT NOT NULL DEFAULT ''",
+			"ALTER TABLE %s ADD COLUMN operator TEXT NOT NULL DEFAULT ''",
+		},
+		insertAuditHistory: "INSERT INTO %s (version, name, checksum, direction, applied_at, duration_ms, success, error_message, hostname, app_version, operator) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
+
+		createRunning:   "CREATE TABLE IF NOT EXISTS %s (version INTEGER PRIMARY KEY, name TEXT NOT NULL DEFAULT '', direction TEXT NOT NULL DEFAULT 'up', hostname TEXT NOT NULL DEFAULT '', started_at DATETIME NOT NULL, heartbeat_at DATETIME NOT NULL)",
+		insertRunning:   "INSERT INTO %s (version, name, direction, hostname, started_at, heartbeat_at) VALUES (?, ?, ?, ?, ?, ?)",
+		updateHeartbeat: "UPDATE %s SET heartbeat_at = ? WHERE version = ?",
+		deleteRunning:   "DELETE FROM %s WHERE version = ?",
+		selectRunning:   "SELECT version, name, direction, hostname, started_at, heartbeat_at FROM %s ORDER BY version",
+
+		createTenant:  "CREATE TABLE IF NOT EXISTS %s (tenant TEXT PRIMARY KEY, version INTEGER NOT NULL DEFAULT 0, dirty BOOLEAN NOT NULL DEFAULT 0, error_message TEXT NOT NULL DEFAULT '', updated_at DATETIME NOT NULL)",
+		deleteTenant:  "DELETE FROM %s WHERE tenant = ?",
+		insertTenant:  "INSERT INTO %s (tenant, version, dirty, error_message, updated_at) VALUES (?, ?, ?, ?, ?)",
+		selectTenants: "SELECT tenant, version, dirty, error_message, updated_at FROM %s ORDER BY tenant",
+	}
+)
+
+// formatDialect 以表名格式化语句模板的方言实现
+type formatDialect struct {
+	name  string
+	quote string // 标识符引用符号，为空时不引用
+
+	createTable string // 创建概要表语句，不包含表选项
+	selectQuery string
+	update      string
+	updateDirty string
+	insert      string
+
+	lock        string // 获取咨询锁语句，为空时不支持
+	unlock      string // 释放咨询锁语句
+	numericLock bool   // 锁名称是否为数值
+
+	dumpSchema func(ctx context.Context, db DBTX, exclude map[string]bool) ([]string, error) // 导出建表语句，为空时不支持
+
+	listObjects string // 查询当前库全部表及视图的名称及类型语句，为空时不支持删除
+	dropTable   string // 删除表语句
+	dropView    string // 删除视图语句
+	disableFK   string // 删除前关闭外键检查语句，为空时不需要
+	enableFK    string // 删除后恢复外键检查语句
+
+	createHistory  string // 创建历史表语句，为空时不支持
+	insertHistory  string // 插入历史记录语句
+	selectHistory  string // 按写入顺序查询历史记录语句
+	updateChecksum string // 更新校验和语句
+
+	addAuditColumns    []string // 为历史表补充审计字段语句
+	insertAuditHistory string   // 插入含执行者的历史记录语句，为空时不记录执行者
+
+	createRunning   string // 创建执行状态表语句，为空时不支持
+	insertRunning   string // 插入执行状态语句
+	updateHeartbeat string // 更新心跳时间语句
+	deleteRunning   string // 删除执行状态语句
+	selectRunning   string // 查询执行状态语句
+
+	createTenant  string // 创建租户状态表语句，为空时不支持
+	deleteTenant  string // 删除租户状态语句
+	insertTenant  string // 插入租户状态语句
+	selectTenants string // 查询租户状态语句
+}
+
+func (f *formatDialect) Name() string {
+	return f.name
+}
+
+func (f *formatDialect) CreateTable(table string) string {
+	return f.CreateTableWith(table, TableOptions{})
+}
+
+func (f *formatDialect) Select(table string) string {
+	return fmt.Sprintf(f.selectQuery, table)
+}
+
+func (f *formatDialect) Update(table string) string {
+	return fmt.Sprintf(f.update, table)
+}
+
+func (f *formatDialect) UpdateDirty(table string) string {
+	return fmt.Sprintf(f.updateDirty, table)
+}
+
+func (f *formatDialect) Insert(table string) string {
+	return fmt.Sprintf(f.insert, table)
+}
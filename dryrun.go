@@ -0,0 +1,69 @@
+package migrate
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/pkg/errors"
+)
+
+/*
+dry-run 模式，仅输出待执行的处理程序及 sql 语句，不执行处理程序也不更新概要表
+*/
+
+const (
+	dryRunUpFormat     = "-- up %d\n"
+	dryRunDownFormat   = "-- down %d\n"
+	dryRunRepeatFormat = "-- repeat %s\n"
+	dryRunNoQuery      = "-- not a sql handler, skipped\n"
+)
+
+// printUp 输出待执行的处理程序
+func (m *migrate) printUp(handlers []Handler) error {
+	for _, handler := range handlers {
+		query := dryRunNoQuery
+		if h, ok := handler.(QueryHandler); ok {
+			query = h.GetQuery()
+		}
+		err := printPlan(m.dryRun, fmt.Sprintf(dryRunUpFormat, handler.GetIndex()), query)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// printDown 输出待回滚的处理程序，handlers 需按回滚顺序排列
+func (m *migrate) printDown(handlers []Handler) error {
+	for _, handler := range handlers {
+		query := dryRunNoQuery
+		if h, ok := handler.(DownQueryHandler); ok {
+			query = h.GetDownQuery()
+		}
+		err := printPlan(m.dryRun, fmt.Sprintf(dryRunDownFormat, handler.GetIndex()), query)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// printRepeat 输出待执行的可重复执行处理程序
+func (m *migrate) printRepeat(handlers []Handler) error {
+	for _, handler := range handlers {
+		query := dryRunNoQuery
+		if h, ok := handler.(QueryHandler); ok {
+			query = h.GetQuery()
+		}
+		err := printPlan(m.dryRun, fmt.Sprintf(dryRunRepeatFormat, nameOf(handler)), query)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func printPlan(w io.Writer, title, query string) error {
+	_, err := io.WriteString(w, title+query+"\n")
+	return errors.WithStack(err)
+}
@@ -0,0 +1,329 @@
+package elasticsearch
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"powerlaw.ai/powerlib/migrate"
+)
+
+/*
+Elasticsearch/OpenSearch 执行器，处理程序由一组 REST 请求组成，用于创建或修改索引模板、映射、别名及执行 reindex；
+通过 REST 接口访问集群，同时兼容 Elasticsearch 及 OpenSearch，版本记录于专用索引，见 NewStore
+*/
+
+// filePattern 请求文件名，如 0001_logs_template.json 及 0001_logs_template.down.json
+var filePattern = regexp.MustCompile(`^(\d+)_(.+?)(\.down)?\.json$`)
+
+// ResponseError 集群返回非 2xx 状态码
+type ResponseError struct {
+	Method string
+	Path   string
+	Status int
+	Body   string
+}
+
+func (e *ResponseError) Error() string {
+	return fmt.Sprintf("%s %s: status %d: %s", e.Method, e.Path, e.Status, e.Body)
+}
+
+// Client 集群 REST 客户端
+type Client struct {
+	url    string
+	client *http.Client
+	header http.Header
+}
+
+type Option func(c *Client)
+
+// New 创建访问 url 的客户端，如 http://localhost:9200
+func New(url string, options ...Option) *Client {
+	c := &Client{
+		url:    strings.TrimSuffix(url, "/"),
+		client: http.DefaultClient,
+		header: make(http.Header),
+	}
+	for _, option := range options {
+		option(c)
+	}
+	return c
+}
+
+// WithHTTPClient 配置 http 客户端
+func WithHTTPClient(client *http.Client) Option {
+	return func(c *Client) {
+		c.client = client
+	}
+}
+
+// WithBasicAuth 使用用户名及密码认证
+func WithBasicAuth(username, password string) Option {
+	return func(c *Client) {
+		req := http.Request{Header: make(http.Header)}
+		req.SetBasicAuth(username, password)
+		c.header.Set("Authorization", req.Header.Get("Authorization"))
+	}
+}
+
+// WithAPIKey 使用 API Key 认证，key 为 base64 编码的 id:api_key
+func WithAPIKey(key string) Option {
+	return func(c *Client) {
+		c.header.Set("Authorization", "ApiKey "+key)
+	}
+}
+
+// Do 发送请求并返回响应内容，非 2xx 状态码时返回 ResponseError
+func (c *Client) Do(ctx context.Context, method, path string, body []byte) ([]byte, error) {
+	var reader io.Reader
+	if len(body) > 0 {
+		reader = bytes.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.url+path, reader)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	for key, values := range c.header {
+		req.Header[key] = values
+	}
+	if reader != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	defer resp.Body.Close()
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return respBody, errors.WithStack(&ResponseError{Method: method, Path: path, Status: resp.StatusCode, Body: string(respBody)})
+	}
+	return respBody, nil
+}
+
+// Request 单个 REST 请求
+type Request struct {
+	Method string          `json:"method"`
+	Path   string          `json:"path"`
+	Body   json.RawMessage `json:"body,omitempty"`
+}
+
+func (r Request) String() string {
+	if len(r.Body) == 0 {
+		return r.Method + " " + r.Path
+	}
+	return r.Method + " " + r.Path + "\n" + string(r.Body)
+}
+
+// CreateIndex 创建索引，body 为 settings 及 mappings
+func CreateIndex(name, body string) Request {
+	return Request{Method: http.MethodPut, Path: "/" + name, Body: json.RawMessage(body)}
+}
+
+// DeleteIndex 删除索引
+func DeleteIndex(name string) Request {
+	return Request{Method: http.MethodDelete, Path: "/" + name}
+}
+
+// PutIndexTemplate 创建或覆盖可组合索引模板
+func PutIndexTemplate(name, body string) Request {
+	return Request{Method: http.MethodPut, Path: "/_index_template/" + name, Body: json.RawMessage(body)}
+}
+
+// PutMapping 为已有索引添加字段映射
+func PutMapping(index, body string) Request {
+	return Request{Method: http.MethodPut, Path: "/" + index + "/_mapping", Body: json.RawMessage(body)}
+}
+
+// PutSettings 修改已有索引的动态配置
+func PutSettings(index, body string) Request {
+	return Request{Method: http.MethodPut, Path: "/" + index + "/_settings", Body: json.RawMessage(body)}
+}
+
+// UpdateAliases 原子执行一组别名操作，body 为 {"actions": [...]}
+func UpdateAliases(body string) Request {
+	return Request{Method: http.MethodPost, Path: "/_aliases", Body: json.RawMessage(body)}
+}
+
+// Reindex 同步执行 reindex，耗时较长时需配置处理程序超时时间
+func Reindex(body string) Request {
+	return Request{Method: http.MethodPost, Path: "/_reindex?wait_for_completion=true", Body: json.RawMessage(body)}
+}
+
+// Handler Elasticsearch 处理程序，顺序发送一组请求
+type Handler struct {
+	index int
+	name  string
+	up    []Request
+	down  []Request // 回滚请求，为空时不支持回滚
+}
+
+// NewHandler 创建顺序发送 requests 的处理程序
+func NewHandler(index int, name string, requests ...Request) Handler {
+	return Handler{index: index, name: name, up: requests}
+}
+
+// WithDown 设置回滚请求
+func (h Handler) WithDown(requests ...Request) Handler {
+	h.down = requests
+	return h
+}
+
+// executor Elasticsearch 执行器
+type executor struct {
+	client   *Client
+	handlers []Handler
+	fsys     fs.FS
+}
+
+// NewExecutor 创建执行 handlers 的执行器
+func NewExecutor(client *Client, handlers ...Handler) migrate.Executor {
+	return &executor{client: client, handlers: handlers}
+}
+
+// NewFileExecutor 创建读取 fsys 根目录请求文件的执行器，文件名如 0001_name.json，回滚文件为 0001_name.down.json，
+// 文件内容为 Request 数组
+func NewFileExecutor(client *Client, fsys fs.FS) migrate.Executor {
+	return &executor{client: client, fsys: fsys}
+}
+
+func (e *executor) ListHandlers() ([]migrate.Handler, error) {
+	handlers := e.handlers
+	if e.fsys != nil {
+		var err error
+		handlers, err = readFiles(e.fsys)
+		if err != nil {
+			return nil, err
+		}
+	}
+	var result []migrate.Handler
+	for _, h := range handlers {
+		base := &handler{Handler: h, client: e.client}
+		if len(h.down) > 0 {
+			result = append(result, &downHandler{handler: base})
+			continue
+		}
+		result = append(result, base)
+	}
+	return result, nil
+}
+
+// readFiles 读取请求文件，同一索引的 up 及 down 文件合并为一个处理程序
+func readFiles(fsys fs.FS) ([]Handler, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	byIndex := make(map[int]*Handler)
+	var indexes []int
+	for _, entry := range entries {
+		match := filePattern.FindStringSubmatch(entry.Name())
+		if entry.IsDir() || match == nil {
+			continue
+		}
+		index, err := strconv.Atoi(match[1])
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		content, err := fs.ReadFile(fsys, entry.Name())
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		var requests []Request
+		err = json.Unmarshal(content, &requests)
+		if err != nil {
+			return nil, errors.Wrap(err, entry.Name())
+		}
+		h, ok := byIndex[index]
+		if !ok {
+			h = &Handler{index: index, name: match[2]}
+			byIndex[index] = h
+			indexes = append(indexes, index)
+		}
+		if match[3] != "" {
+			h.down = requests
+		} else {
+			h.up = requests
+		}
+	}
+	handlers := make([]Handler, 0, len(indexes))
+	for _, index := range indexes {
+		handlers = append(handlers, *byIndex[index])
+	}
+	return handlers, nil
+}
+
+// handler 绑定客户端的处理程序
+type handler struct {
+	Handler
+	client *Client
+}
+
+func (h *handler) GetIndex() int {
+	return h.index
+}
+
+func (h *handler) GetName() string {
+	return h.name
+}
+
+func (h *handler) Exec(ctx context.Context) error {
+	return h.client.send(ctx, h.up)
+}
+
+// GetQuery 返回全部请求，用于 dry-run
+func (h *handler) GetQuery() string {
+	return formatRequests(h.up)
+}
+
+// GetChecksum 返回请求的 sha256，已执行的请求变更时拒绝执行
+func (h *handler) GetChecksum() string {
+	content, _ := json.Marshal(h.up)
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
+
+type downHandler struct {
+	*handler
+}
+
+func (h *downHandler) DownExec(ctx context.Context) error {
+	return h.client.send(ctx, h.down)
+}
+
+func (h *downHandler) GetDownQuery() string {
+	return formatRequests(h.down)
+}
+
+// send 顺序发送请求，失败时返回请求序号
+func (c *Client) send(ctx context.Context, requests []Request) error {
+	for i, request := range requests {
+		_, err := c.Do(ctx, request.Method, request.Path, request.Body)
+		if err != nil {
+			return errors.WithMessagef(err, "request %d", i+1)
+		}
+	}
+	return nil
+}
+
+func formatRequests(requests []Request) string {
+	lines := make([]string, 0, len(requests))
+	for _, request := range requests {
+		lines = append(lines, request.String())
+	}
+	return strings.Join(lines, "\n")
+}
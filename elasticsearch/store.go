@@ -0,0 +1,126 @@
+package elasticsearch
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"powerlaw.ai/powerlib/migrate"
+)
+
+/*
+版本存储，version 及 dirty 记录于专用索引的 version 文档，迁移锁为同一索引的 lock 文档，
+以 _create 写入保证只有一个实例获取锁；进程异常退出时锁不会自动释放，需手动删除 lock 文档
+*/
+
+const (
+	defaultIndex     = "migrations"
+	versionID        = "version"
+	lockID           = "lock"
+	lockPollInterval = time.Second
+)
+
+// versionDoc 版本文档
+type versionDoc struct {
+	Version int  `json:"version"`
+	Dirty   bool `json:"dirty"`
+}
+
+// store 基于专用索引的版本存储
+type store struct {
+	client *Client
+	index  string
+}
+
+// NewStore 创建记录于 index 的版本存储，index 为空时使用 migrations，配合 migrate.WithSchemaStore 使用
+func NewStore(client *Client, index string) migrate.SchemaStore {
+	if index == "" {
+		index = defaultIndex
+	}
+	return &store{client: client, index: index}
+}
+
+func (s *store) Init(ctx context.Context) error {
+	_, err := s.client.Do(ctx, http.MethodHead, "/"+s.index, nil)
+	if !isStatus(err, http.StatusNotFound) {
+		return err
+	}
+	_, err = s.client.Do(ctx, http.MethodPut, "/"+s.index, []byte(`{"settings":{"number_of_shards":1}}`))
+	// 多个实例同时创建时忽略已存在的错误
+	if isStatus(err, http.StatusBadRequest) {
+		_, err = s.client.Do(ctx, http.MethodHead, "/"+s.index, nil)
+	}
+	return err
+}
+
+func (s *store) GetVersion(ctx context.Context) (int, bool, error) {
+	body, err := s.client.Do(ctx, http.MethodGet, "/"+s.index+"/_doc/"+versionID, nil)
+	if isStatus(err, http.StatusNotFound) {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, err
+	}
+	var doc struct {
+		Source versionDoc `json:"_source"`
+	}
+	err = json.Unmarshal(body, &doc)
+	if err != nil {
+		return 0, false, errors.WithStack(err)
+	}
+	return doc.Source.Version, doc.Source.Dirty, nil
+}
+
+func (s *store) SetVersion(ctx context.Context, version int) error {
+	body, err := json.Marshal(map[string]interface{}{
+		"doc":           map[string]int{"version": version},
+		"doc_as_upsert": true,
+	})
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	_, err = s.client.Do(ctx, http.MethodPost, "/"+s.index+"/_update/"+versionID+"?refresh=true", body)
+	return err
+}
+
+func (s *store) SetDirty(ctx context.Context, version int, dirty bool) error {
+	body, err := json.Marshal(versionDoc{Version: version, Dirty: dirty})
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	_, err = s.client.Do(ctx, http.MethodPut, "/"+s.index+"/_doc/"+versionID+"?refresh=true", body)
+	return err
+}
+
+// Lock 写入 lock 文档，文档已存在时轮询直至获取或 ctx 结束
+func (s *store) Lock(ctx context.Context) error {
+	body, err := json.Marshal(map[string]string{"locked_at": time.Now().UTC().Format(time.RFC3339)})
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	for {
+		_, err = s.client.Do(ctx, http.MethodPut, "/"+s.index+"/_create/"+lockID+"?refresh=true", body)
+		if !isStatus(err, http.StatusConflict) {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return errors.WithStack(ctx.Err())
+		case <-time.After(lockPollInterval):
+		}
+	}
+}
+
+func (s *store) Unlock(ctx context.Context) error {
+	_, err := s.client.Do(ctx, http.MethodDelete, "/"+s.index+"/_doc/"+lockID+"?refresh=true", nil)
+	return err
+}
+
+// isStatus 判断是否为指定状态码的 ResponseError
+func isStatus(err error, status int) bool {
+	var respErr *ResponseError
+	return errors.As(err, &respErr) && respErr.Status == status
+}
@@ -0,0 +1,242 @@
+package migrate
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+/*
+可通过 errors.As 判断的错误类型，便于调用方根据错误执行修复等自动化处理
+*/
+
+// DuplicateIndexError 处理程序索引重复
+type DuplicateIndexError struct {
+	Index int
+}
+
+func (e *DuplicateIndexError) Error() string {
+	return fmt.Sprintf("duplicate index is %d", e.Index)
+}
+
+// GapError 处理程序索引不连续，Index 为缺口前的索引
+type GapError struct {
+	Index int
+}
+
+func (e *GapError) Error() string {
+	return fmt.Sprintf("index gap is larger than 1, current index is %d", e.Index)
+}
+
+// HandlerError 处理程序执行或回滚失败，包含处理程序的索引、名称及来源文件
+type HandlerError struct {
+	Index      int
+	Name       string
+	Source     string // 来源文件，处理程序未实现 SourceHandler 时为空
+	Direction  string
+	Err        error
+	RolledBack bool // 是否已通过 WithAutoRollback 自动回滚
+}
+
+func (e *HandlerError) Error() string {
+	label := e.Source
+	if label == "" {
+		label = strconv.Itoa(e.Index)
+		if e.Name != "" {
+			label += "_" + e.Name
+		}
+	}
+	if e.Direction == DirectionDown {
+		return fmt.Sprintf("migration %s down failed: %s", label, e.cause())
+	}
+	if e.RolledBack {
+		return fmt.Sprintf("migration %s failed and was rolled back: %s", label, e.cause())
+	}
+	return fmt.Sprintf("migration %s failed: %s", label, e.cause())
+}
+
+// cause 返回原始错误的信息，原始错误的来源文件与处理程序相同时不重复输出文件名
+func (e *HandlerError) cause() string {
+	message := e.Err.Error()
+	var sourceErr SourceError
+	if e.Source != "" && errors.As(e.Err, &sourceErr) && sourceErr.GetSource() == e.Source {
+		return strings.Replace(message, sourceErr.Error(), sourceErr.ErrorWithoutSource(), 1)
+	}
+	return message
+}
+
+func (e *HandlerError) Unwrap() error {
+	return e.Err
+}
+
+// SourceError 包含来源文件的错误，由 HandlerError 包装时不重复输出来源文件
+type SourceError interface {
+	error
+	// GetSource 返回来源文件
+	GetSource() string
+	// ErrorWithoutSource 返回不含来源文件的错误信息
+	ErrorWithoutSource() string
+}
+
+// DependencyError 处理程序的依赖不存在或索引不小于处理程序的索引
+type DependencyError struct {
+	Index      int
+	Dependency int
+}
+
+func (e *DependencyError) Error() string {
+	return fmt.Sprintf("handler %d depends on %d which is missing or not before it", e.Index, e.Dependency)
+}
+
+// DirtyError 概要表处于 dirty 状态，需修复后调用 Force 清除
+type DirtyError struct {
+	Version int
+	Reason  string // 历史表中记录的失败原因，未记录时为空
+}
+
+func (e *DirtyError) Error() string {
+	if e.Reason == "" {
+		return fmt.Sprintf("find dirty index %d", e.Version)
+	}
+	return fmt.Sprintf("find dirty index %d, error is : %s", e.Version, e.Reason)
+}
+
+// RunningError 其他实例正在执行处理程序，心跳未超时
+type RunningError struct {
+	Version     int
+	Name        string
+	Direction   string
+	Hostname    string
+	StartedAt   time.Time
+	HeartbeatAt time.Time
+}
+
+func (e *RunningError) Error() string {
+	return fmt.Sprintf("migration %d %s is in progress on %s since %s, last heartbeat at %s",
+		e.Version, e.Direction, e.Hostname, e.StartedAt.Format(time.RFC3339), e.HeartbeatAt.Format(time.RFC3339))
+}
+
+// SchemaRowsError 概要表存在多条记录，通常由手动修改导致，需删除多余记录或通过 WithSchemaRepair 自动修复
+type SchemaRowsError struct {
+	Table string
+	Rows  int
+}
+
+func (e *SchemaRowsError) Error() string {
+	return fmt.Sprintf("schema table %s has %d rows, expected exactly 1", e.Table, e.Rows)
+}
+
+// ChecksumMismatchError 已执行的处理程序内容发生变更，确认变更后可调用 Repair 更新校验和
+type ChecksumMismatchError struct {
+	Index    int
+	Recorded string
+	Current  string
+}
+
+func (e *ChecksumMismatchError) Error() string {
+	return fmt.Sprintf("checksum of applied handler %d mismatch, recorded %s, current %s", e.Index, e.Recorded, e.Current)
+}
+
+// RoundTripError 往返测试中处理程序执行或回滚失败，或回滚后的表结构与执行前不一致
+type RoundTripError struct {
+	Index     int
+	Direction string
+	Residue   []string // 不一致的行，多出的行以 + 开头，缺少的行以 - 开头
+	Err       error
+}
+
+func (e *RoundTripError) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("round trip: %s of handler %d failed: %v", e.Direction, e.Index, e.Err)
+	}
+	return fmt.Sprintf("round trip: schema after %s of handler %d differs:\n%s", e.Direction, e.Index, strings.Join(e.Residue, "\n"))
+}
+
+func (e *RoundTripError) Unwrap() error {
+	return e.Err
+}
+
+// DestructiveError 待执行的处理程序包含破坏性语句，确认后可通过 WithAllowDestructive 执行
+type DestructiveError struct {
+	Index     int
+	Name      string
+	Statement string
+}
+
+func (e *DestructiveError) Error() string {
+	return fmt.Sprintf("handler %d %s contains destructive statement %s", e.Index, e.Name, e.Statement)
+}
+
+// ValidationError Validate 发现的全部处理程序内容错误
+type ValidationError struct {
+	Errors []error
+}
+
+func (e *ValidationError) Error() string {
+	messages := make([]string, 0, len(e.Errors))
+	for _, err := range e.Errors {
+		messages = append(messages, err.Error())
+	}
+	return fmt.Sprintf("%d validation errors:\n%s", len(e.Errors), strings.Join(messages, "\n"))
+}
+
+func (e *ValidationError) Unwrap() []error {
+	return e.Errors
+}
+
+// ShadowError 影子库执行失败，真实库未执行
+type ShadowError struct {
+	Err error
+}
+
+func (e *ShadowError) Error() string {
+	return fmt.Sprintf("shadow database failed: %v", e.Err)
+}
+
+func (e *ShadowError) Unwrap() error {
+	return e.Err
+}
+
+// NotReadyError 概要表 version 低于处理程序的最大索引，迁移尚未完成
+type NotReadyError struct {
+	Version int // 概要表 version
+	Latest  int // 标签匹配的处理程序的最大索引
+}
+
+func (e *NotReadyError) Error() string {
+	return fmt.Sprintf("schema version %d is behind latest version %d", e.Version, e.Latest)
+}
+
+// TargetError 多库迁移中单个目标库的错误
+type TargetError struct {
+	Target string
+	Err    error
+}
+
+func (e *TargetError) Error() string {
+	return fmt.Sprintf("%s: %v", e.Target, e.Err)
+}
+
+func (e *TargetError) Unwrap() error {
+	return e.Err
+}
+
+// MultiError 多库迁移中全部失败的目标库错误，元素为 TargetError
+type MultiError struct {
+	Errors []error
+}
+
+func (e *MultiError) Error() string {
+	messages := make([]string, 0, len(e.Errors))
+	for _, err := range e.Errors {
+		messages = append(messages, err.Error())
+	}
+	return fmt.Sprintf("%d databases failed:\n%s", len(e.Errors), strings.Join(messages, "\n"))
+}
+
+func (e *MultiError) Unwrap() []error {
+	return e.Errors
+}
@@ -0,0 +1,191 @@
+package migrate
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/pkg/errors"
+)
+
+/*
+开发环境重建，Drop 删除当前库全部表及视图，Reset 回滚全部后重新执行，Fresh 删除后重新执行，用于快速重置本地开发库；
+操作不可恢复，需通过 WithAllowUnsafe 显式开启，切勿在生产环境开启
+*/
+
+var (
+	ErrUnsafe           = errors.New("drop, reset, fresh and round trip require WithAllowUnsafe")
+	ErrDropNotSupported = errors.New("dialect does not support dropping all tables")
+)
+
+const dryRunDrop = "-- drop all tables and views\n"
+
+// DropDialect 支持删除全部表及视图的方言
+type DropDialect interface {
+	// DropAll 删除当前库的全部表及视图，包括概要表及历史表
+	DropAll(ctx context.Context, db DBTX) error
+}
+
+func (f *formatDialect) DropAll(ctx context.Context, db DBTX) error {
+	if f.listObjects == "" {
+		return ErrDropNotSupported
+	}
+	// 外键检查为连接级别的配置，需在同一连接中执行
+	conn, release, err := pinConn(ctx, db)
+	if err != nil {
+		return err
+	}
+	defer release()
+	if f.disableFK != "" {
+		_, err = conn.ExecContext(ctx, f.disableFK)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		defer conn.ExecContext(context.WithoutCancel(ctx), f.enableFK)
+	}
+	objects, err := queryObjects(ctx, conn, f.listObjects)
+	if err != nil {
+		return err
+	}
+	for _, object := range objects {
+		query := fmt.Sprintf(f.dropTable, object[0])
+		if object[1] == "VIEW" {
+			query = fmt.Sprintf(f.dropView, object[0])
+		}
+		_, err = conn.ExecContext(ctx, query)
+		if err != nil {
+			return errors.Wrap(err, query)
+		}
+	}
+	return nil
+}
+
+// queryObjects 查询表及视图的名称及类型，视图在前，避免删除表后视图失效
+func queryObjects(ctx context.Context, conn DBTX, query string) ([][2]string, error) {
+	rows, err := conn.QueryContext(ctx, query)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	defer rows.Close()
+	var views, tables [][2]string
+	for rows.Next() {
+		var object [2]string
+		if err := rows.Scan(&object[0], &object[1]); err != nil {
+			return nil, errors.WithStack(err)
+		}
+		if object[1] == "VIEW" {
+			views = append(views, object)
+		} else {
+			tables = append(tables, object)
+		}
+	}
+	return append(views, tables...), errors.WithStack(rows.Err())
+}
+
+// WithAllowUnsafe 允许执行 Drop、Reset 及 Fresh，仅用于开发及测试环境
+func WithAllowUnsafe() Option {
+	return func(m *migrate) {
+		m.allowUnsafe = true
+	}
+}
+
+func (m *migrate) Drop(ctx context.Context) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if !m.allowUnsafe {
+		return ErrUnsafe
+	}
+	return m.operate(ctx, "migrate.Drop", m.drop)
+}
+
+func (m *migrate) Reset(ctx context.Context) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if !m.allowUnsafe {
+		return ErrUnsafe
+	}
+	return m.operate(ctx, "migrate.Reset", func(ctx context.Context) error {
+		// 1.回滚全部已执行的处理程序
+		handlers, schema, err := m.prepare(ctx)
+		if err != nil {
+			return err
+		}
+		applied, _, err := m.split(ctx, handlers, schema.version)
+		if err != nil {
+			return err
+		}
+		if len(applied) > 0 {
+			err = m.down(ctx, applied, len(applied))
+			if err != nil {
+				return err
+			}
+		}
+		// 2.重新执行全部处理程序
+		return m.upAll(ctx)
+	})
+}
+
+func (m *migrate) Fresh(ctx context.Context) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if !m.allowUnsafe {
+		return ErrUnsafe
+	}
+	return m.operate(ctx, "migrate.Fresh", func(ctx context.Context) error {
+		err := m.drop(ctx)
+		if err != nil {
+			return err
+		}
+		return m.upAll(ctx)
+	})
+}
+
+// drop 删除全部表及视图，并将版本存储重置为 0
+func (m *migrate) drop(ctx context.Context) error {
+	if m.dryRun != nil {
+		return printPlan(m.dryRun, dryRunDrop, "")
+	}
+	dialect, ok := m.dialect.(DropDialect)
+	if !ok {
+		return ErrDropNotSupported
+	}
+	m.logger.Warn("drop all tables and views")
+	err := dialect.DropAll(ctx, m.db)
+	if err != nil {
+		return err
+	}
+	// 非概要表的版本存储不随表删除
+	err = m.store.Init(ctx)
+	if err != nil {
+		return err
+	}
+	return m.store.SetDirty(ctx, 0, false)
+}
+
+// upAll 执行全部待执行的处理程序及可重复执行的处理程序，dry-run 时输出全部处理程序
+func (m *migrate) upAll(ctx context.Context) error {
+	if m.dryRun != nil {
+		handlers, err := m.initHandlers()
+		if err != nil {
+			return err
+		}
+		return m.printUp(m.filterTags(handlers))
+	}
+	handlers, schema, err := m.prepare(ctx)
+	if err != nil {
+		return err
+	}
+	_, pending, err := m.split(ctx, handlers, schema.version)
+	if err != nil {
+		return err
+	}
+	results, err := m.up(ctx, pending, schema.version)
+	if err != nil {
+		return err
+	}
+	version := schema.version
+	for _, result := range results {
+		version = max(version, result.Index)
+	}
+	_, err = m.repeat(ctx, version)
+	return err
+}
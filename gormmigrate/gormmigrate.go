@@ -0,0 +1,148 @@
+package gormmigrate
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"gorm.io/gorm"
+
+	"powerlaw.ai/powerlib/migrate"
+)
+
+/*
+GORM 执行器，处理程序接收 *gorm.DB，可调用 AutoMigrate 同步模型结构体作为一个迁移步骤，
+与 sql 文件共用同一 version 序列；处理程序在 migrate 开启的事务中执行，version 在同一事务中更新
+*/
+
+// Func 接收绑定当前事务的 *gorm.DB 的处理函数
+type Func func(ctx context.Context, db *gorm.DB) error
+
+// Handler GORM 处理程序
+type Handler struct {
+	index    int
+	name     string
+	timeout  time.Duration // 执行超时时间，0 表示使用 migrate 的配置
+	tags     []string      // 标签，仅在 migrate.WithTags 包含任一标签时执行
+	up       Func
+	down     Func   // 回滚函数，为空时不支持回滚
+	checksum string // 校验和，为空时不校验
+}
+
+// NewHandler 创建执行 up 的处理程序，up 中的 db 已绑定事务及 ctx
+func NewHandler(index int, name string, up Func) Handler {
+	return Handler{index: index, name: name, up: up}
+}
+
+// NewAutoMigrateHandler 创建对 models 执行 AutoMigrate 的处理程序，仅新增表、列及索引，不删除已有的列
+func NewAutoMigrateHandler(index int, name string, models ...interface{}) Handler {
+	return NewHandler(index, name, func(ctx context.Context, db *gorm.DB) error {
+		return db.AutoMigrate(models...)
+	})
+}
+
+// WithDown 设置回滚函数，如 db.Migrator().DropTable(models...)
+func (g Handler) WithDown(down Func) Handler {
+	g.down = down
+	return g
+}
+
+// WithTimeout 指定处理程序的执行超时时间
+func (g Handler) WithTimeout(timeout time.Duration) Handler {
+	g.timeout = timeout
+	return g
+}
+
+// WithChecksum 指定处理程序的校验和，已执行的处理程序校验和变更时拒绝执行
+func (g Handler) WithChecksum(checksum string) Handler {
+	g.checksum = checksum
+	return g
+}
+
+// WithTags 指定处理程序的标签，仅在 migrate.WithTags 包含任一标签时执行
+func (g Handler) WithTags(tags ...string) Handler {
+	g.tags = tags
+	return g
+}
+
+// executor GORM 执行器
+type executor struct {
+	db       *gorm.DB
+	handlers []Handler
+}
+
+// NewExecutor 创建执行 handlers 的执行器，migrate.New 需使用 db 底层的 *sql.DB
+func NewExecutor(db *gorm.DB, handlers ...Handler) migrate.Executor {
+	return &executor{db: db, handlers: handlers}
+}
+
+func (e *executor) ListHandlers() ([]migrate.Handler, error) {
+	handlers := make([]migrate.Handler, 0, len(e.handlers))
+	for idx := range e.handlers {
+		h := &handler{Handler: &e.handlers[idx], db: e.db}
+		if h.down != nil {
+			handlers = append(handlers, downHandler{handler: h})
+			continue
+		}
+		handlers = append(handlers, h)
+	}
+	return handlers, nil
+}
+
+// handler 绑定 *gorm.DB 的处理程序
+type handler struct {
+	*Handler
+	db *gorm.DB
+}
+
+func (g *handler) GetIndex() int {
+	return g.index
+}
+
+func (g *handler) GetName() string {
+	return g.name
+}
+
+func (g *handler) GetTimeout() time.Duration {
+	return g.timeout
+}
+
+func (g *handler) GetTags() []string {
+	return g.tags
+}
+
+func (g *handler) GetChecksum() string {
+	return g.checksum
+}
+
+func (g *handler) Exec(ctx context.Context) error {
+	return g.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return g.up(ctx, tx)
+	})
+}
+
+func (g *handler) ExecTx(ctx context.Context, tx *sql.Tx) error {
+	return g.up(ctx, g.session(ctx, tx))
+}
+
+// session 返回在 tx 中执行的 *gorm.DB
+func (g *handler) session(ctx context.Context, tx *sql.Tx) *gorm.DB {
+	db := g.db.Session(&gorm.Session{NewDB: true, Context: ctx})
+	db.Statement.ConnPool = tx
+	return db
+}
+
+// downHandler 可回滚的 handler
+type downHandler struct {
+	*handler
+}
+
+func (g downHandler) DownExec(ctx context.Context) error {
+	return g.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return g.down(ctx, tx)
+	})
+}
+
+func (g downHandler) DownExecTx(ctx context.Context, tx *sql.Tx) error {
+	return g.down(ctx, g.session(ctx, tx))
+}
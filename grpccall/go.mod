@@ -0,0 +1,24 @@
+module powerlaw.ai/powerlib/migrate/grpccall
+
+go 1.21
+
+require powerlaw.ai/powerlib/migrate v0.0.0-00010101000000-000000000000
+
+require (
+	github.com/pkg/errors v0.9.1
+	google.golang.org/grpc v1.62.1
+	google.golang.org/protobuf v1.33.0
+)
+
+require (
+	github.com/golang/protobuf v1.5.4 // indirect
+	github.com/robfig/cron/v3 v3.0.1 // indirect
+	go.opentelemetry.io/otel v1.24.0 // indirect
+	go.opentelemetry.io/otel/trace v1.24.0 // indirect
+	golang.org/x/net v0.22.0 // indirect
+	golang.org/x/sys v0.18.0 // indirect
+	golang.org/x/text v0.14.0 // indirect
+	google.golang.org/genproto/googleapis/rpc v0.0.0-20240311132316-a219d84964c2 // indirect
+)
+
+replace powerlaw.ai/powerlib/migrate => ..
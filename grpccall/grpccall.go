@@ -0,0 +1,73 @@
+package grpccall
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+
+	"github.com/pkg/errors"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/proto"
+
+	"powerlaw.ai/powerlib/migrate"
+)
+
+/*
+gRPC 调用处理程序，调用其他服务的一元 RPC 作为迁移步骤，用于触发其他服务的数据迁移，由 version 保证只调用一次；
+调用失败时标记 dirty，被调用的接口应可重复调用
+*/
+
+// handler 调用一元 RPC 的处理程序
+type handler struct {
+	index   int
+	name    string
+	conn    grpc.ClientConnInterface
+	method  string
+	request proto.Message
+	reply   proto.Message
+	options []grpc.CallOption
+}
+
+// NewHandler 创建以 request 调用 method 的处理程序，method 为完整方法名，如 /billing.v1.Billing/Backfill，
+// reply 用于接收响应
+func NewHandler(index int, name string, conn grpc.ClientConnInterface, method string, request, reply proto.Message, options ...grpc.CallOption) migrate.Handler {
+	return &handler{
+		index:   index,
+		name:    name,
+		conn:    conn,
+		method:  method,
+		request: request,
+		reply:   reply,
+		options: options,
+	}
+}
+
+func (h *handler) GetIndex() int {
+	return h.index
+}
+
+func (h *handler) GetName() string {
+	return h.name
+}
+
+// GetQuery 返回方法名，用于 dry-run
+func (h *handler) GetQuery() string {
+	return h.method
+}
+
+// GetChecksum 返回方法名及请求的 sha256，已执行的请求变更时拒绝执行
+func (h *handler) GetChecksum() string {
+	content, err := proto.MarshalOptions{Deterministic: true}.Marshal(h.request)
+	if err != nil {
+		return ""
+	}
+	hash := sha256.New()
+	hash.Write([]byte(h.method + "\n"))
+	hash.Write(content)
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+func (h *handler) Exec(ctx context.Context) error {
+	err := h.conn.Invoke(ctx, h.method, h.request, h.reply, h.options...)
+	return errors.Wrap(err, h.method)
+}
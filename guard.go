@@ -0,0 +1,43 @@
+package migrate
+
+import "github.com/pkg/errors"
+
+/*
+破坏性语句保护，待执行的处理程序包含 DROP TABLE、DROP COLUMN、TRUNCATE 等会丢失数据的语句时拒绝执行，
+防止误提交的迁移在服务启动时被自动执行；回滚由调用方显式发起，不做检查
+*/
+
+// DestructiveHandler 可检查破坏性语句的处理程序
+type DestructiveHandler interface {
+	Handler
+	// GetDestructive 返回第一条破坏性语句，不包含时返回空
+	GetDestructive() (string, error)
+}
+
+// WithAllowDestructive 允许执行包含破坏性语句的处理程序
+func WithAllowDestructive() Option {
+	return func(m *migrate) {
+		m.allowDestructive = true
+	}
+}
+
+// checkDestructive 在执行前检查全部处理程序，包含破坏性语句时返回 DestructiveError，不执行任何处理程序
+func (m *migrate) checkDestructive(handlers []Handler) error {
+	if m.allowDestructive {
+		return nil
+	}
+	for _, handler := range handlers {
+		h, ok := handler.(DestructiveHandler)
+		if !ok {
+			continue
+		}
+		statement, err := h.GetDestructive()
+		if err != nil {
+			return err
+		}
+		if statement != "" {
+			return errors.WithStack(&DestructiveError{Index: handler.GetIndex(), Name: nameOf(handler), Statement: statement})
+		}
+	}
+	return nil
+}
@@ -1,6 +1,10 @@
 package migrate
 
-import "context"
+import (
+	"context"
+	"database/sql"
+	"time"
+)
 
 /*
 Handler 处理程序实例，拥有执行索引 index，以及自身执行程序，可单独执行
@@ -10,3 +14,144 @@ type Handler interface {
 	GetIndex() int
 	Exec(ctx context.Context) error
 }
+
+// Named 拥有名称的处理程序，名称用于日志、历史表及状态输出
+type Named interface {
+	Handler
+	GetName() string
+}
+
+// nameOf 返回处理程序的名称，未实现 Named 时返回空
+func nameOf(handler Handler) string {
+	if h, ok := handler.(Named); ok {
+		return h.GetName()
+	}
+	return ""
+}
+
+// TaggedHandler 具有标签的处理程序，仅在 WithTags 包含其任一标签时执行
+type TaggedHandler interface {
+	Handler
+	GetTags() []string
+}
+
+// tagsOf 返回处理程序的标签，未实现 TaggedHandler 时返回空
+func tagsOf(handler Handler) []string {
+	if h, ok := handler.(TaggedHandler); ok {
+		return h.GetTags()
+	}
+	return nil
+}
+
+// DependentHandler 声明依赖的处理程序，依赖需为索引更小的处理程序；DependsOn 返回 nil 时视为未声明，
+// 依赖所有索引更小的处理程序，返回空切片时不依赖任何处理程序
+type DependentHandler interface {
+	Handler
+	DependsOn() []int
+}
+
+// dependenciesOf 返回处理程序声明的依赖，未声明时 ok 为 false
+func dependenciesOf(handler Handler) (dependencies []int, ok bool) {
+	if h, ok := handler.(DependentHandler); ok && h.DependsOn() != nil {
+		return h.DependsOn(), true
+	}
+	return nil, false
+}
+
+// BaselineHandler 由之前的处理程序压缩而成的基线处理程序，仅在新库上执行，不校验校验和；
+// 基线需为索引最小的处理程序，version 大于 0 且小于基线索引的库无法执行基线
+type BaselineHandler interface {
+	Handler
+	IsBaseline() bool
+}
+
+// isBaseline 判断是否为基线处理程序
+func isBaseline(handler Handler) bool {
+	h, ok := handler.(BaselineHandler)
+	return ok && h.IsBaseline()
+}
+
+// RepeatableHandler 可重复执行的处理程序，如视图、存储过程及基础数据；
+// 不参与索引排序及校验，在所有待执行处理程序之后按名称顺序执行，校验和变更时重新执行，名称需唯一
+type RepeatableHandler interface {
+	Named
+	Checksummer
+	Repeatable() bool
+}
+
+// isRepeatable 判断是否为可重复执行的处理程序
+func isRepeatable(handler Handler) bool {
+	h, ok := handler.(RepeatableHandler)
+	return ok && h.Repeatable()
+}
+
+// DownHandler 可回滚的处理程序，DownExec 用于撤销 Exec 所做的变更
+type DownHandler interface {
+	Handler
+	DownExec(ctx context.Context) error
+}
+
+// QueryHandler 可输出执行 sql 语句的处理程序，用于 dry-run
+type QueryHandler interface {
+	Handler
+	GetQuery() string
+}
+
+// DownQueryHandler 可输出回滚 sql 语句的处理程序，用于 dry-run
+type DownQueryHandler interface {
+	DownHandler
+	GetDownQuery() string
+}
+
+// QuerySizer 可返回 sql 语句字节数的处理程序，tracing 时优先使用，避免为统计长度读取整个文件
+type QuerySizer interface {
+	Handler
+	GetQuerySize(direction string) int
+}
+
+// SourceHandler 可返回来源文件的处理程序，执行失败时错误中包含来源文件
+type SourceHandler interface {
+	Handler
+	GetSource(direction string) string
+}
+
+// handlerError 以处理程序的索引、名称及来源文件包装错误
+func handlerError(handler Handler, direction string, err error) *HandlerError {
+	e := &HandlerError{Index: handler.GetIndex(), Name: nameOf(handler), Direction: direction, Err: err}
+	if h, ok := handler.(SourceHandler); ok {
+		e.Source = h.GetSource(direction)
+	}
+	return e
+}
+
+// Checksummer 可计算内容校验和的处理程序，已执行的处理程序内容变更时将拒绝执行
+type Checksummer interface {
+	Handler
+	GetChecksum() string
+}
+
+// TimeoutHandler 指定执行超时时间的处理程序，优先于 WithHandlerTimeout 的配置
+type TimeoutHandler interface {
+	Handler
+	GetTimeout() time.Duration
+}
+
+// TxHandler 可在外部事务中执行的处理程序，migrate 在同一事务中更新 version，保证两者原子性；
+// 事务由 migrate 的 db 连接开启，处理程序需操作同一数据库
+type TxHandler interface {
+	Handler
+	ExecTx(ctx context.Context, tx *sql.Tx) error
+}
+
+// NoTxHandler 需在事务外执行的处理程序，如 CREATE INDEX CONCURRENTLY，
+// NoTx 返回 true 时即使实现了 TxHandler 也不在事务中执行，version 在处理程序执行后单独更新
+type NoTxHandler interface {
+	Handler
+	NoTx() bool
+}
+
+// DownTxHandler 可在外部事务中回滚的处理程序
+type DownTxHandler interface {
+	DownHandler
+	DownExecTx(ctx context.Context, tx *sql.Tx) error
+}
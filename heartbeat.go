@@ -0,0 +1,220 @@
+package migrate
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+/*
+执行状态及心跳，处理程序执行期间在执行状态表中记录执行的处理程序、主机名、开始时间，并定期更新心跳时间，执行结束后删除；
+其他实例执行前读取执行状态表，心跳未超时视为其他实例执行中，返回 RunningError；
+心跳超时视为执行的实例在执行中途崩溃，将 version 标记为 dirty 并记录失败历史，返回 DirtyError；
+心跳时间取各实例的本地时间，超时时间需大于实例间的时钟偏差
+*/
+
+const (
+	defaultRunningTableSuffix = "_running"
+	heartbeatTimeoutFactor    = 3
+)
+
+const (
+	ErrCrashedFormat = "crashed mid-migration on %s, started at %s, last heartbeat at %s"
+)
+
+// RunningDialect 支持执行状态表的方言
+type RunningDialect interface {
+	// CreateRunningTable 以 options 创建执行状态表
+	CreateRunningTable(table string, options TableOptions) string
+	// InsertRunning 插入执行状态，参数为 version、name、direction、hostname、started_at、heartbeat_at
+	InsertRunning(table string) string
+	// UpdateHeartbeat 更新心跳时间，参数为 heartbeat_at、version
+	UpdateHeartbeat(table string) string
+	// DeleteRunning 删除执行状态，参数为 version
+	DeleteRunning(table string) string
+	// SelectRunning 查询全部执行状态，字段顺序同 InsertRunning
+	SelectRunning(table string) string
+}
+
+func (f *formatDialect) CreateRunningTable(table string, options TableOptions) string {
+	if f.createRunning == "" {
+		return ""
+	}
+	return fmt.Sprintf(f.createRunning, table) + f.tableSuffix(options)
+}
+
+func (f *formatDialect) InsertRunning(table string) string {
+	return fmt.Sprintf(f.insertRunning, table)
+}
+
+func (f *formatDialect) UpdateHeartbeat(table string) string {
+	return fmt.Sprintf(f.updateHeartbeat, table)
+}
+
+func (f *formatDialect) DeleteRunning(table string) string {
+	return fmt.Sprintf(f.deleteRunning, table)
+}
+
+func (f *formatDialect) SelectRunning(table string) string {
+	return fmt.Sprintf(f.selectRunning, table)
+}
+
+// WithHeartbeat 处理程序执行期间记录执行状态并每隔 interval 更新心跳，
+// 心跳超过 timeout 未更新视为执行的实例已崩溃，timeout 不大于 0 时为 interval 的 3 倍
+func WithHeartbeat(interval, timeout time.Duration) Option {
+	return func(m *migrate) {
+		if timeout <= 0 {
+			timeout = interval * heartbeatTimeoutFactor
+		}
+		m.heartbeatInterval, m.heartbeatTimeout = interval, timeout
+	}
+}
+
+// running 执行状态表中的一条记录
+type running struct {
+	version     int
+	name        string
+	direction   string
+	hostname    string
+	startedAt   time.Time
+	heartbeatAt time.Time
+}
+
+// runningDialect 返回支持执行状态表的方言，未开启心跳或不支持时返回 nil
+func (m *migrate) runningDialect() RunningDialect {
+	// 调用方传入的事务中执行，其他实例无法读取事务中的执行状态
+	if m.heartbeatInterval <= 0 || m.db == nil || m.external {
+		return nil
+	}
+	dialect, ok := m.dialect.(RunningDialect)
+	if !ok || dialect.CreateRunningTable(m.runningTable, m.tableOptions) == "" {
+		return nil
+	}
+	return dialect
+}
+
+// createRunningTable 创建执行状态表
+func (m *migrate) createRunningTable(ctx context.Context) error {
+	dialect := m.runningDialect()
+	if dialect == nil {
+		return nil
+	}
+	_, err := m.db.ExecContext(ctx, dialect.CreateRunningTable(m.qualify(m.runningTable), m.tableOptions))
+	return errors.WithStack(err)
+}
+
+// loadRunning 读取全部执行状态
+func (m *migrate) loadRunning(ctx context.Context, dialect RunningDialect) ([]running, error) {
+	rows, err := m.db.QueryContext(ctx, dialect.SelectRunning(m.qualify(m.runningTable)))
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	defer rows.Close()
+	var runnings []running
+	for rows.Next() {
+		var r running
+		err = rows.Scan(&r.version, &r.name, &r.direction, &r.hostname, (*timeScanner)(&r.startedAt), (*timeScanner)(&r.heartbeatAt))
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		runnings = append(runnings, r)
+	}
+	return runnings, errors.WithStack(rows.Err())
+}
+
+// checkRunning 校验没有其他实例正在执行处理程序，心跳未超时时返回 RunningError，
+// 心跳超时时将 version 标记为 dirty、记录失败历史并删除执行状态，返回 DirtyError
+func (m *migrate) checkRunning(ctx context.Context, version int) error {
+	dialect := m.runningDialect()
+	if dialect == nil {
+		return nil
+	}
+	runnings, err := m.loadRunning(ctx, dialect)
+	if err != nil {
+		return err
+	}
+	for _, r := range runnings {
+		if time.Since(r.heartbeatAt) < m.heartbeatTimeout {
+			return errors.WithStack(&RunningError{Version: r.version, Name: r.name, Direction: r.direction,
+				Hostname: r.hostname, StartedAt: r.startedAt, HeartbeatAt: r.heartbeatAt})
+		}
+	}
+	for _, r := range runnings {
+		// 与执行失败时一致，执行中崩溃标记 version，回滚中崩溃标记回滚中的处理程序
+		dirty := max(version, r.version)
+		if r.direction == DirectionDown {
+			dirty = r.version
+		}
+		reason := fmt.Sprintf(ErrCrashedFormat, r.hostname, r.startedAt.Format(time.RFC3339), r.heartbeatAt.Format(time.RFC3339))
+		m.logger.Error("handler crashed", "index", r.version, "name", r.name, "direction", r.direction, "hostname", r.hostname,
+			"started_at", r.startedAt, "heartbeat_at", r.heartbeatAt)
+		err = m.store.SetDirty(ctx, dirty, true)
+		if err != nil {
+			return err
+		}
+		err = m.recordHistory(ctx, history{version: r.version, name: r.name, direction: r.direction, appliedAt: r.startedAt,
+			duration: r.heartbeatAt.Sub(r.startedAt), errMsg: reason, hostname: r.hostname})
+		if err != nil {
+			return err
+		}
+		_, err = m.db.ExecContext(ctx, dialect.DeleteRunning(m.qualify(m.runningTable)), r.version)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		return errors.WithStack(&DirtyError{Version: dirty, Reason: reason})
+	}
+	return nil
+}
+
+// startRunning 记录处理程序的执行状态并定期更新心跳，返回结束执行时调用的函数，结束时删除执行状态
+func (m *migrate) startRunning(ctx context.Context, handler Handler, direction string) (func(), error) {
+	dialect := m.runningDialect()
+	if dialect == nil {
+		return func() {}, nil
+	}
+	// 执行状态不受处理程序超时及 ctx 取消影响，由返回的函数结束
+	ctx = context.WithoutCancel(ctx)
+	table := m.qualify(m.runningTable)
+	now := time.Now().UTC()
+	_, err := m.db.ExecContext(ctx, dialect.InsertRunning(table), handler.GetIndex(), nameOf(handler), direction, m.hostname, now, now)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		ticker := time.NewTicker(m.heartbeatInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				_, err := m.db.ExecContext(ctx, dialect.UpdateHeartbeat(table), time.Now().UTC(), handler.GetIndex())
+				if err != nil {
+					m.logger.Warn("update heartbeat failed", "index", handler.GetIndex(), "name", nameOf(handler), "error", err)
+				}
+			}
+		}
+	}()
+	return func() {
+		close(done)
+		wg.Wait()
+		_, err := m.db.ExecContext(ctx, dialect.DeleteRunning(table), handler.GetIndex())
+		if err != nil {
+			m.logger.Warn("delete running state failed", "index", handler.GetIndex(), "name", nameOf(handler), "error", err)
+		}
+	}, nil
+}
+
+// hostname 返回当前主机名，获取失败时返回空
+func hostname() string {
+	name, _ := os.Hostname()
+	return name
+}
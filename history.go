@@ -0,0 +1,225 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+/*
+历史表，记录每一次处理程序的执行及回滚，用于审计
+*/
+
+const (
+	defaultHistoryTableSuffix = "_history"
+)
+
+const (
+	DirectionUp     = "up"
+	DirectionDown   = "down"
+	DirectionRepeat = "repeat" // 可重复执行的处理程序
+)
+
+// HistoryDialect 支持历史表的方言
+type HistoryDialect interface {
+	// CreateHistoryTable 创建历史表
+	CreateHistoryTable(table string) string
+	// InsertHistory 插入历史记录，参数为 version、name、checksum、direction、applied_at、duration_ms、success、error_message
+	InsertHistory(table string) string
+	// SelectHistory 按写入顺序查询历史记录，字段顺序同 InsertHistory
+	SelectHistory(table string) string
+	// UpdateChecksum 更新 version 执行记录的校验和，参数为 checksum、version
+	UpdateChecksum(table string) string
+}
+
+func (f *formatDialect) CreateHistoryTable(table string) string {
+	return f.CreateHistoryTableWith(table, TableOptions{})
+}
+
+func (f *formatDialect) InsertHistory(table string) string {
+	return fmt.Sprintf(f.insertHistory, table)
+}
+
+func (f *formatDialect) SelectHistory(table string) string {
+	return fmt.Sprintf(f.selectHistory, table)
+}
+
+func (f *formatDialect) UpdateChecksum(table string) string {
+	return fmt.Sprintf(f.updateChecksum, table)
+}
+
+// history 单条历史记录
+type history struct {
+	version   int
+	name      string
+	checksum  string
+	direction string
+	appliedAt time.Time
+	duration  time.Duration
+	success   bool
+	errMsg    string // 失败原因，包含失败的语句
+	hostname  string // 执行的主机名，为空时为当前主机
+}
+
+// historyDialect 返回支持历史表的方言，不支持或已关闭历史表时返回 nil
+func (m *migrate) historyDialect() HistoryDialect {
+	// 未指定 db 时不记录历史表
+	if m.noHistory || m.db == nil {
+		return nil
+	}
+	dialect, ok := m.dialect.(HistoryDialect)
+	if !ok || dialect.CreateHistoryTable(m.historyTable) == "" {
+		return nil
+	}
+	return dialect
+}
+
+// createHistoryTable 创建历史表
+func (m *migrate) createHistoryTable(ctx context.Context) error {
+	dialect := m.historyDialect()
+	if dialect == nil {
+		return nil
+	}
+	query := dialect.CreateHistoryTable(m.qualify(m.historyTable))
+	if tableDialect, ok := m.dialect.(TableDialect); ok {
+		query = tableDialect.CreateHistoryTableWith(m.qualify(m.historyTable), m.tableOptions)
+	}
+	_, err := m.db.ExecContext(ctx, query)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	return m.addAuditColumns(ctx)
+}
+
+// recordHistory 写入一条历史记录
+func (m *migrate) recordHistory(ctx context.Context, h history) error {
+	return m.recordHistoryTo(ctx, m.execer(), h)
+}
+
+// recordHistoryTo 通过 exec 写入一条历史记录，在处理程序的事务中写入时与处理程序一同提交
+func (m *migrate) recordHistoryTo(ctx context.Context, exec execer, h history) error {
+	dialect := m.historyDialect()
+	if dialect == nil {
+		return nil
+	}
+	args := []any{h.version, h.name, h.checksum, h.direction, h.appliedAt.UTC(), h.duration.Milliseconds(), h.success, h.errMsg}
+	// 方言支持时记录执行者，未指定主机名时为当前主机
+	if auditDialect := m.auditDialect(); auditDialect != nil {
+		if h.hostname == "" {
+			h.hostname = m.hostname
+		}
+		_, err := exec.ExecContext(ctx, auditDialect.InsertAuditHistory(m.qualify(m.historyTable)),
+			append(args, h.hostname, m.appVersion, m.operator)...)
+		return errors.WithStack(err)
+	}
+	_, err := exec.ExecContext(ctx, dialect.InsertHistory(m.qualify(m.historyTable)), args...)
+	return errors.WithStack(err)
+}
+
+// loadHistory 按写入顺序读取全部历史记录，不支持历史表时返回空
+func (m *migrate) loadHistory(ctx context.Context) ([]history, error) {
+	dialect := m.historyDialect()
+	if dialect == nil {
+		return nil, nil
+	}
+	rows, err := m.db.QueryContext(ctx, dialect.SelectHistory(m.qualify(m.historyTable)))
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	defer rows.Close()
+	var histories []history
+	for rows.Next() {
+		var h history
+		var duration int64
+		var errMsg sql.NullString
+		err = rows.Scan(&h.version, &h.name, &h.checksum, &h.direction, (*timeScanner)(&h.appliedAt), &duration, &h.success, &errMsg)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		h.duration = time.Duration(duration) * time.Millisecond
+		h.errMsg = errMsg.String
+		histories = append(histories, h)
+	}
+	return histories, errors.WithStack(rows.Err())
+}
+
+// lastApplied 返回每个 version 最近一次成功执行的记录，已被成功回滚的 version 不包含在内
+func lastApplied(histories []history) map[int]history {
+	applied := make(map[int]history)
+	for _, h := range histories {
+		if !h.success {
+			continue
+		}
+		switch h.direction {
+		case DirectionUp:
+			applied[h.version] = h
+		case DirectionDown:
+			delete(applied, h.version)
+		}
+	}
+	return applied
+}
+
+// lastFailure 返回 version 最近一次失败的记录
+func lastFailure(histories []history, version int) (history, bool) {
+	for i := len(histories) - 1; i >= 0; i-- {
+		if histories[i].version == version && !histories[i].success {
+			return histories[i], true
+		}
+	}
+	return history{}, false
+}
+
+// newHistory 根据处理程序执行结果生成历史记录
+func newHistory(handler Handler, direction string, start time.Time, err error) history {
+	var errMsg string
+	if err != nil {
+		errMsg = err.Error()
+	}
+	return history{
+		version:   handler.GetIndex(),
+		name:      nameOf(handler),
+		checksum:  checksumOf(handler),
+		direction: direction,
+		appliedAt: start,
+		duration:  time.Since(start),
+		success:   err == nil,
+		errMsg:    errMsg,
+	}
+}
+
+// timeLayouts 驱动未解析时间字段时，以字符串形式返回的常见格式
+var timeLayouts = []string{
+	"2006-01-02 15:04:05.999999999 -0700 MST",
+	"2006-01-02 15:04:05.999999999",
+	time.RFC3339Nano,
+}
+
+// timeScanner 兼容驱动返回 time.Time、字符串或字节的时间字段，如未开启 parseTime 的 MySQL 连接
+type timeScanner time.Time
+
+func (t *timeScanner) Scan(src interface{}) error {
+	var value string
+	switch v := src.(type) {
+	case time.Time:
+		*t = timeScanner(v)
+		return nil
+	case string:
+		value = v
+	case []byte:
+		value = string(v)
+	default:
+		return errors.Errorf("unsupported time value %v", src)
+	}
+	for _, layout := range timeLayouts {
+		parsed, err := time.Parse(layout, value)
+		if err == nil {
+			*t = timeScanner(parsed)
+			return nil
+		}
+	}
+	return errors.Errorf("unsupported time format %s", value)
+}
@@ -0,0 +1,85 @@
+package migrate
+
+import (
+	"context"
+	"time"
+)
+
+/*
+Hooks 事件回调，可用于推送进度、发送通知或统计耗时，回调为空时忽略；
+WithParallelism 并发执行时回调串行调用，无需自行加锁
+*/
+
+type Hooks struct {
+	BeforeHandler func(ctx context.Context, event HandlerEvent)
+	AfterHandler  func(ctx context.Context, event HandlerEvent)
+	OnError       func(ctx context.Context, event HandlerEvent, err error)
+	OnComplete    func(ctx context.Context, event CompleteEvent)
+}
+
+// HandlerEvent 单个处理程序的执行事件
+type HandlerEvent struct {
+	Index     int           // 处理程序索引
+	Name      string        // 处理程序名称，未实现 Named 时为空
+	Direction string        // DirectionUp、DirectionDown 或 DirectionRepeat
+	Version   int           // 执行成功后概要表的 version，失败时为 dirty 的 version，已自动回滚或跳过时为不 dirty 的 version
+	Duration  time.Duration // 执行耗时，BeforeHandler 中为 0
+
+	RolledBack bool // OnError 中处理程序是否已通过 WithAutoRollback 回滚，为 true 时 version 不为 dirty
+	Skipped    bool // OnError 中失败的处理程序是否为可选处理程序，为 true 时 version 不为 dirty 且继续执行
+}
+
+// CompleteEvent 一次执行或回滚结束的事件
+type CompleteEvent struct {
+	Direction string        // DirectionUp 或 DirectionDown
+	Handlers  []int         // 执行成功的处理程序索引
+	Duration  time.Duration // 总耗时
+	Err       error         // 失败原因，成功时为空
+}
+
+func (m *migrate) beforeHandler(ctx context.Context, event HandlerEvent) {
+	m.hookMutex.Lock()
+	defer m.hookMutex.Unlock()
+	for _, hooks := range m.hooks {
+		if hooks.BeforeHandler != nil {
+			hooks.BeforeHandler(ctx, event)
+		}
+	}
+}
+
+func (m *migrate) afterHandler(ctx context.Context, event HandlerEvent) {
+	m.hookMutex.Lock()
+	defer m.hookMutex.Unlock()
+	for _, hooks := range m.hooks {
+		if hooks.AfterHandler != nil {
+			hooks.AfterHandler(ctx, event)
+		}
+	}
+}
+
+func (m *migrate) onError(ctx context.Context, event HandlerEvent, err error) {
+	m.hookMutex.Lock()
+	defer m.hookMutex.Unlock()
+	for _, hooks := range m.hooks {
+		if hooks.OnError != nil {
+			hooks.OnError(ctx, event, err)
+		}
+	}
+}
+
+// complete 触发 OnComplete 回调
+func (m *migrate) complete(ctx context.Context, direction string, handlers []int, start time.Time, err error) {
+	event := CompleteEvent{
+		Direction: direction,
+		Handlers:  handlers,
+		Duration:  time.Since(start),
+		Err:       err,
+	}
+	m.hookMutex.Lock()
+	defer m.hookMutex.Unlock()
+	for _, hooks := range m.hooks {
+		if hooks.OnComplete != nil {
+			hooks.OnComplete(ctx, event)
+		}
+	}
+}
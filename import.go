@@ -0,0 +1,150 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+/*
+导入其他迁移工具的概要表，已执行的处理程序只记录不执行，便于从其他工具迁移过来；
+golang-migrate 的概要表可与本库的概要表同名，此时直接沿用其 version，并为已执行的处理程序补充执行历史
+*/
+
+const (
+	ErrImportVersionFormat = "imported version %s is not an integer"
+)
+
+var (
+	ErrImportDirty = errors.New("imported schema is dirty")
+)
+
+const (
+	selectGolangMigrateQuery = "SELECT version, dirty FROM %s"
+	selectFlywayQuery        = "SELECT version, success FROM %s WHERE version IS NOT NULL"
+)
+
+// ImportSource 其他迁移工具的概要表
+type ImportSource interface {
+	// ReadVersion 读取已执行的最大版本
+	ReadVersion(ctx context.Context, db DBTX) (int, error)
+}
+
+// golangMigrateSource golang-migrate 的 schema_migrations 表，仅有一行 version 及 dirty
+type golangMigrateSource struct {
+	table string
+}
+
+// GolangMigrate 从 golang-migrate 的概要表导入，table 为空时使用 schema_migrations
+func GolangMigrate(table string) ImportSource {
+	if table == "" {
+		table = "schema_migrations"
+	}
+	return &golangMigrateSource{table: table}
+}
+
+func (g *golangMigrateSource) ReadVersion(ctx context.Context, db DBTX) (int, error) {
+	var version int64
+	var dirty bool
+	err := db.QueryRowContext(ctx, fmt.Sprintf(selectGolangMigrateQuery, g.table)).Scan(&version, &dirty)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, errors.WithStack(err)
+	}
+	if dirty {
+		return 0, ErrImportDirty
+	}
+	return int(version), nil
+}
+
+// flywaySource Flyway 的 flyway_schema_history 表，每次执行一行，可重复迁移的 version 为空
+type flywaySource struct {
+	table string
+}
+
+// Flyway 从 Flyway 的历史表导入，table 为空时使用 flyway_schema_history；仅支持整数版本
+func Flyway(table string) ImportSource {
+	if table == "" {
+		table = "flyway_schema_history"
+	}
+	return &flywaySource{table: table}
+}
+
+func (f *flywaySource) ReadVersion(ctx context.Context, db DBTX) (int, error) {
+	rows, err := db.QueryContext(ctx, fmt.Sprintf(selectFlywayQuery, f.table))
+	if err != nil {
+		return 0, errors.WithStack(err)
+	}
+	defer rows.Close()
+	max := 0
+	for rows.Next() {
+		var version string
+		var success bool
+		err = rows.Scan(&version, &success)
+		if err != nil {
+			return 0, errors.WithStack(err)
+		}
+		if !success {
+			return 0, ErrImportDirty
+		}
+		v, err := strconv.Atoi(version)
+		if err != nil {
+			return 0, errors.Errorf(ErrImportVersionFormat, version)
+		}
+		if v > max {
+			max = v
+		}
+	}
+	return max, errors.WithStack(rows.Err())
+}
+
+func (m *migrate) Import(ctx context.Context, source ImportSource) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return m.operate(ctx, "migrate.Import", func(ctx context.Context) error {
+		// 与概要表同名时，需在初始化概要表之前读取
+		version, err := source.ReadVersion(ctx, m.db)
+		if err != nil {
+			return err
+		}
+		err = m.baseline(ctx, version)
+		if err != nil {
+			return err
+		}
+		return m.recordImported(ctx, version)
+	})
+}
+
+// recordImported 为不大于 version 且缺少执行历史的处理程序补充历史记录；
+// 与概要表同名时初始化后 version 已为导入的版本，baseline 不记录历史，重复导入时不重复记录
+func (m *migrate) recordImported(ctx context.Context, version int) error {
+	if m.historyDialect() == nil {
+		return nil
+	}
+	handlers, _, err := m.load(ctx)
+	if err != nil {
+		return err
+	}
+	histories, err := m.loadHistory(ctx)
+	if err != nil {
+		return err
+	}
+	applied := lastApplied(histories)
+	now := time.Now()
+	for _, handler := range handlers[:searchPending(handlers, version)] {
+		if _, ok := applied[handler.GetIndex()]; ok {
+			continue
+		}
+		err = m.recordHistory(ctx, newHistory(handler, DirectionUp, now, nil))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
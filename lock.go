@@ -0,0 +1,146 @@
+package migrate
+
+import (
+	"context"
+	"hash/crc32"
+
+	"github.com/pkg/errors"
+)
+
+/*
+Locker 迁移锁，保证多个实例同时启动时只有一个实例执行迁移；
+可接入数据库咨询锁、etcd 租约、Redis 锁等实现，默认不加锁
+*/
+
+var (
+	ErrLockNotSupported = errors.New("dialect does not support advisory lock")
+	ErrLockFailed       = errors.New("failed to acquire advisory lock")
+	ErrLockTimeout      = errors.New("timed out acquiring migration lock")
+)
+
+type Locker interface {
+	// Lock 获取锁，阻塞直至成功或 ctx 结束
+	Lock(ctx context.Context) error
+	// Unlock 释放锁
+	Unlock(ctx context.Context) error
+}
+
+// noopLocker 不加锁
+type noopLocker struct{}
+
+func (noopLocker) Lock(ctx context.Context) error {
+	return nil
+}
+
+func (noopLocker) Unlock(ctx context.Context) error {
+	return nil
+}
+
+// LockDialect 支持咨询锁的方言，Lock 语句成功获取锁时需返回 1
+type LockDialect interface {
+	Lock(table string) (string, []interface{})
+	Unlock(table string) (string, []interface{})
+}
+
+func (f *formatDialect) Lock(table string) (string, []interface{}) {
+	return f.lock, []interface{}{f.lockKey(table)}
+}
+
+func (f *formatDialect) Unlock(table string) (string, []interface{}) {
+	return f.unlock, []interface{}{f.lockKey(table)}
+}
+
+// lockKey 生成锁名称，数值型锁使用表名的 crc32 值
+func (f *formatDialect) lockKey(table string) interface{} {
+	if f.numericLock {
+		return int64(crc32.ChecksumIEEE([]byte(table)))
+	}
+	return table
+}
+
+// advisoryLocker 基于数据库咨询锁的 Locker，锁名称由概要表名生成
+type advisoryLocker struct {
+	db      DBTX
+	dialect Dialect
+	table   string
+
+	session DBTX   // 咨询锁绑定会话，持有锁期间固定使用同一连接
+	release func() // 归还连接
+}
+
+func NewAdvisoryLocker(db DBTX, dialect Dialect, table string) Locker {
+	return &advisoryLocker{
+		db:      db,
+		dialect: dialect,
+		table:   table,
+	}
+}
+
+func (a *advisoryLocker) Lock(ctx context.Context) error {
+	dialect, ok := a.dialect.(LockDialect)
+	if !ok {
+		return ErrLockNotSupported
+	}
+	query, args := dialect.Lock(a.table)
+	if query == "" {
+		return ErrLockNotSupported
+	}
+	session, release, err := pinConn(ctx, a.db)
+	if err != nil {
+		return err
+	}
+	var locked int
+	err = session.QueryRowContext(ctx, query, args...).Scan(&locked)
+	if err != nil {
+		release()
+		return errors.WithStack(err)
+	}
+	if locked != 1 {
+		release()
+		return ErrLockFailed
+	}
+	a.session, a.release = session, release
+	return nil
+}
+
+func (a *advisoryLocker) Unlock(ctx context.Context) error {
+	if a.session == nil {
+		return nil
+	}
+	defer func() {
+		a.release()
+		a.session, a.release = nil, nil
+	}()
+	query, args := a.dialect.(LockDialect).Unlock(a.table)
+	_, err := a.session.ExecContext(ctx, query, args...)
+	return errors.WithStack(err)
+}
+
+// withLock 获取锁后执行 f，执行结束后释放锁
+func (m *migrate) withLock(ctx context.Context, f func() error) error {
+	err := m.lock(ctx)
+	if err != nil {
+		return err
+	}
+	err = f()
+	// 释放锁不受 ctx 取消影响
+	unlockErr := m.locker.Unlock(context.Background())
+	if err != nil {
+		return err
+	}
+	return unlockErr
+}
+
+// lock 获取锁，配置 WithLockTimeout 时超时返回 ErrLockTimeout
+func (m *migrate) lock(ctx context.Context) error {
+	if m.lockTimeout <= 0 {
+		return m.locker.Lock(ctx)
+	}
+	lockCtx, cancel := context.WithTimeout(ctx, m.lockTimeout)
+	defer cancel()
+	err := m.locker.Lock(lockCtx)
+	if err != nil && ctx.Err() == nil && lockCtx.Err() != nil {
+		return errors.Wrapf(ErrLockTimeout, "waited %s", m.lockTimeout)
+	}
+	return err
+}
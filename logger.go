@@ -0,0 +1,21 @@
+package migrate
+
+/*
+Logger 结构化日志接口，keysAndValues 为交替出现的键值对；
+默认不输出日志，slog、zap 适配器位于 logger 子包
+*/
+
+type Logger interface {
+	Info(msg string, keysAndValues ...interface{})
+	Warn(msg string, keysAndValues ...interface{})
+	Error(msg string, keysAndValues ...interface{})
+}
+
+// noopLogger 不输出日志
+type noopLogger struct{}
+
+func (noopLogger) Info(msg string, keysAndValues ...interface{}) {}
+
+func (noopLogger) Warn(msg string, keysAndValues ...interface{}) {}
+
+func (noopLogger) Error(msg string, keysAndValues ...interface{}) {}
@@ -0,0 +1,32 @@
+package logger
+
+import (
+	"log/slog"
+
+	"powerlaw.ai/powerlib/migrate"
+)
+
+// slogLogger 将日志输出到 log/slog
+type slogLogger struct {
+	logger *slog.Logger
+}
+
+// NewSlog 使用 slog.Logger 输出迁移日志，logger 为空时使用 slog.Default()
+func NewSlog(logger *slog.Logger) migrate.Logger {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return &slogLogger{logger: logger}
+}
+
+func (s *slogLogger) Info(msg string, keysAndValues ...interface{}) {
+	s.logger.Info(msg, keysAndValues...)
+}
+
+func (s *slogLogger) Warn(msg string, keysAndValues ...interface{}) {
+	s.logger.Warn(msg, keysAndValues...)
+}
+
+func (s *slogLogger) Error(msg string, keysAndValues ...interface{}) {
+	s.logger.Error(msg, keysAndValues...)
+}
@@ -0,0 +1,29 @@
+package logger
+
+import (
+	"go.uber.org/zap"
+
+	"powerlaw.ai/powerlib/migrate"
+)
+
+// zapLogger 将日志输出到 zap
+type zapLogger struct {
+	logger *zap.SugaredLogger
+}
+
+// NewZap 使用 zap.Logger 输出迁移日志
+func NewZap(logger *zap.Logger) migrate.Logger {
+	return &zapLogger{logger: logger.Sugar()}
+}
+
+func (z *zapLogger) Info(msg string, keysAndValues ...interface{}) {
+	z.logger.Infow(msg, keysAndValues...)
+}
+
+func (z *zapLogger) Warn(msg string, keysAndValues ...interface{}) {
+	z.logger.Warnw(msg, keysAndValues...)
+}
+
+func (z *zapLogger) Error(msg string, keysAndValues ...interface{}) {
+	z.logger.Errorw(msg, keysAndValues...)
+}
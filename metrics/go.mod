@@ -0,0 +1,25 @@
+module powerlaw.ai/powerlib/migrate/metrics
+
+go 1.21
+
+require powerlaw.ai/powerlib/migrate v0.0.0-00010101000000-000000000000
+
+require (
+	github.com/pkg/errors v0.9.1
+	github.com/prometheus/client_golang v1.19.1
+)
+
+require (
+	github.com/beorn7/perks v1.0.1 // indirect
+	github.com/cespare/xxhash/v2 v2.2.0 // indirect
+	github.com/prometheus/client_model v0.5.0 // indirect
+	github.com/prometheus/common v0.48.0 // indirect
+	github.com/prometheus/procfs v0.12.0 // indirect
+	github.com/robfig/cron/v3 v3.0.1 // indirect
+	go.opentelemetry.io/otel v1.24.0 // indirect
+	go.opentelemetry.io/otel/trace v1.24.0 // indirect
+	golang.org/x/sys v0.18.0 // indirect
+	google.golang.org/protobuf v1.33.0 // indirect
+)
+
+replace powerlaw.ai/powerlib/migrate => ..
@@ -0,0 +1,79 @@
+package metrics
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"powerlaw.ai/powerlib/migrate"
+)
+
+/*
+metrics 通过事件回调采集迁移指标并注册到 Prometheus
+*/
+
+const (
+	labelDirection = "direction"
+)
+
+// Metrics 迁移指标
+type Metrics struct {
+	applied  *prometheus.CounterVec   // 执行成功的处理程序数
+	failures *prometheus.CounterVec   // 执行失败的处理程序数
+	duration *prometheus.HistogramVec // 单个处理程序耗时
+	version  prometheus.Gauge         // 当前概要表 version
+}
+
+// New 创建迁移指标并注册到 registerer，registerer 为空时使用 prometheus.DefaultRegisterer
+func New(registerer prometheus.Registerer) (*Metrics, error) {
+	if registerer == nil {
+		registerer = prometheus.DefaultRegisterer
+	}
+	m := &Metrics{
+		applied: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "migrations_applied_total",
+			Help: "Total number of successfully executed migration handlers.",
+		}, []string{labelDirection}),
+		failures: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "migration_failures_total",
+			Help: "Total number of failed migration handlers.",
+		}, []string{labelDirection}),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "migration_duration_seconds",
+			Help:    "Duration of migration handlers in seconds.",
+			Buckets: prometheus.ExponentialBuckets(0.01, 4, 10),
+		}, []string{labelDirection}),
+		version: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "migration_schema_version",
+			Help: "Current schema version recorded by the last executed handler.",
+		}),
+	}
+	for _, collector := range []prometheus.Collector{m.applied, m.failures, m.duration, m.version} {
+		err := registerer.Register(collector)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+	}
+	return m, nil
+}
+
+// Hooks 返回采集指标的事件回调，配合 migrate.WithHooks 使用
+func (m *Metrics) Hooks() migrate.Hooks {
+	return migrate.Hooks{
+		AfterHandler: func(ctx context.Context, event migrate.HandlerEvent) {
+			m.applied.WithLabelValues(event.Direction).Inc()
+			m.duration.WithLabelValues(event.Direction).Observe(event.Duration.Seconds())
+			m.version.Set(float64(event.Version))
+		},
+		OnError: func(ctx context.Context, event migrate.HandlerEvent, err error) {
+			m.failures.WithLabelValues(event.Direction).Inc()
+			m.duration.WithLabelValues(event.Direction).Observe(event.Duration.Seconds())
+		},
+	}
+}
+
+// SetVersion 设置当前概要表 version，可在启动时以 Migrate.Version 的结果初始化
+func (m *Metrics) SetVersion(version int) {
+	m.version.Set(float64(version))
+}
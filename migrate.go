@@ -3,11 +3,15 @@ package migrate
 import (
 	"context"
 	"database/sql"
-	"fmt"
+	"io"
+	"os"
 	"sort"
 	"sync"
+	"time"
 
 	"github.com/pkg/errors"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
 )
 
 /*
@@ -21,21 +25,9 @@ const (
 )
 
 const (
-	ErrDuplicateIndexFormat = "duplicate index is %d"
-	ErrIndexGapLargeFormat  = "index gap is larger than 1, current index is %d"
-	ErrFindIndexDirtyFormat = "find dirty index %d"
-)
-
-const (
-	createSchemaTableQuery = "CREATE TABLE IF NOT EXISTS %s (`version` int NOT NULL DEFAULT 0, `dirty` tinyint(1) NOT NULL DEFAULT 1) ENGINE=InnoDB;"
-
-	selectSchemaQuery = "SELECT * FROM %s"
-
-	updateSchemaQuery = "UPDATE %s SET `version` = ?"
-
-	updateDirtyQuery = "UPDATE %s SET `version` = ?, `dirty` = ?"
-
-	insertDefaultSchema = "INSERT INTO %s (`version`, `dirty`) VALUES (0, 0)"
+	ErrNotDownHandlerFormat  = "handler %d does not support down"
+	ErrVersionNotFoundFormat = "version %d not found in handlers"
+	ErrBaselineFormat        = "database version %d is before the squashed baseline %d, migrate it with the handlers before squashing first"
 )
 
 var (
@@ -47,26 +39,158 @@ type Migrate interface {
 	AddHandlers(handlers ...Handler)
 
 	Run(ctx context.Context) error
+	// RunWithResult 执行所有未执行的处理程序，并返回执行报告，失败时报告包含已执行成功的处理程序
+	RunWithResult(ctx context.Context) (*Report, error)
+	// Down 回滚最近执行的 steps 个处理程序，steps 小于等于 0 时回滚全部
+	Down(ctx context.Context, steps int) error
+	// Steps n 大于 0 时最多执行 n 个待执行的处理程序，小于 0 时回滚最近执行的 -n 个处理程序
+	Steps(ctx context.Context, n int) error
+	// RunOne 执行索引为 index 的待执行处理程序，之前的处理程序需均已执行
+	RunOne(ctx context.Context, index int) error
+	// Redo 重新执行索引为 index 的已执行处理程序，不改变 version，处理程序需可重复执行
+	Redo(ctx context.Context, index int) error
+	// MigrateTo 执行或回滚处理程序，直至概要表 version 等于指定版本
+	MigrateTo(ctx context.Context, version int) error
+	// Status 列出已执行及待执行的处理程序，不执行任何处理程序
+	Status(ctx context.Context) (applied []HandlerInfo, pending []HandlerInfo, err error)
+	// Version 返回概要表记录的当前版本及是否 dirty
+	Version(ctx context.Context) (version int, dirty bool, err error)
+	// WaitForVersion 轮询概要表直至 version 不小于指定版本，用于非主实例等待主实例完成迁移，dirty 时返回 DirtyError
+	WaitForVersion(ctx context.Context, version int) error
+	// Ready 概要表 version 不低于处理程序的最大索引且不为 dirty 时返回空，否则返回 NotReadyError 或 DirtyError
+	Ready(ctx context.Context) error
+	// VerifyChecksums 校验已执行处理程序的校验和与历史表记录一致，不一致时返回 ChecksumMismatchError，不执行任何处理程序
+	VerifyChecksums(ctx context.Context) error
+	// Repair 以当前内容重新计算并覆盖已执行处理程序的校验和，用于有意修改历史文件后恢复
+	Repair(ctx context.Context) error
+	// Force 强制设置概要表 version 并清除 dirty，不执行任何处理程序，用于人工修复后恢复
+	Force(ctx context.Context, version int) error
+	// Baseline 将 version 及之前的处理程序标记为已执行，不实际执行
+	Baseline(ctx context.Context, version int) error
+	// Import 从其他迁移工具的概要表导入已执行的版本，不执行处理程序
+	Import(ctx context.Context, source ImportSource) error
+	// Validate 不连接数据库，校验处理程序索引及内容，返回全部校验错误
+	Validate(ctx context.Context) error
+	// DumpSchema 将当前库的表结构写入 w，不包含概要表及历史表
+	DumpSchema(ctx context.Context, w io.Writer) error
+	// Drop 删除当前库的全部表及视图并将 version 重置为 0，需开启 WithAllowUnsafe
+	Drop(ctx context.Context) error
+	// Reset 回滚全部已执行的处理程序后重新执行全部处理程序，需开启 WithAllowUnsafe
+	Reset(ctx context.Context) error
+	// Fresh 删除全部表及视图后重新执行全部处理程序，需开启 WithAllowUnsafe
+	Fresh(ctx context.Context) error
+	// RoundTrip 执行全部处理程序后逐个回滚再重新执行，校验回滚是否成功及是否残留表结构，需开启 WithAllowUnsafe
+	RoundTrip(ctx context.Context) error
 }
 
 type migrate struct {
 	mutex sync.Mutex
 
-	db          *sql.DB // db 连接
+	db          DBTX    // db 连接
 	schemaTable string  // 概要表，记录当前执行位置
+	dialect     Dialect // 概要表语句方言
+
+	historyTable string // 历史表，记录每一次执行
+	noHistory    bool   // 是否关闭历史表
+
+	tableOptions TableOptions // 概要表及历史表的建表选项
+
+	store        SchemaStore // 版本存储，默认为概要表
+	locker       Locker      // 迁移锁
+	advisoryLock bool        // 执行期间是否持有咨询锁
+
+	lockTimeout time.Duration // 获取迁移锁的超时时间，0 表示一直等待
+
+	dryRun io.Writer // 不为空时仅输出执行计划
+	logger Logger    // 日志
+	hooks  []Hooks   // 事件回调
+
+	hookMutex sync.Mutex // 串行调用并发执行的处理程序的回调
+
+	tracer trace.Tracer // 链路追踪
+
+	handlerTimeout time.Duration // 单个处理程序的超时时间，0 表示不限制
+	throttler      Throttler     // 限流器，为空时不限流
+
+	parallelism int // 并发执行互不依赖的处理程序的最大数量，不大于 1 表示顺序执行
+
+	retryAttempts int           // 瞬时错误时单个处理程序的最多执行次数，不大于 1 表示不重试
+	retryBackoff  time.Duration // 首次重试前的等待时间，之后每次翻倍
+
+	waitBackoff    time.Duration // 等待数据库就绪的首次退避时间，0 表示不等待
+	waitMaxBackoff time.Duration // 等待数据库就绪的最大退避时间
+	waitTimeout    time.Duration // 等待数据库就绪的最长时间，0 表示仅由 ctx 限制
+	pollInterval   time.Duration // WaitForVersion 轮询概要表的间隔
+
+	allowOutOfOrder bool // 是否执行索引小于 version 的未执行处理程序
+	allowGaps       bool // 是否允许索引不连续
+
+	tags []string // 启用的标签，设置标签的处理程序仅在包含其任一标签时执行
+
+	window     *Window    // 维护窗口，为空时不限制
+	windowMode WindowMode // 窗口外遇到受限处理程序时的行为
+	windowTags []string   // 受维护窗口限制的标签
+
+	allowDestructive bool // 是否允许执行包含破坏性语句的处理程序
+	allowUnsafe      bool // 是否允许执行 Drop、Reset 及 Fresh
+
+	repairSchema bool // 概要表存在多条记录时是否自动修复
+
+	autoRollback bool // 处理程序失败时是否自动执行其回滚
+
+	runningTable      string        // 执行状态表，记录执行中的处理程序及心跳
+	heartbeatInterval time.Duration // 心跳间隔，0 表示不记录执行状态
+	heartbeatTimeout  time.Duration // 心跳超时时间，超时视为执行的实例已崩溃
+
+	hostname   string // 当前主机名，记录到历史表及执行状态表
+	appVersion string // 记录到历史表的应用版本
+	operator   string // 记录到历史表的操作人
+
+	shadow     Migrate                             // 影子库迁移客户端，不为空时先在影子库执行
+	shadowDiff func(current, shadow string) string // 比较真实库及影子库表结构，为空时不比较
+
+	schemaDump func(schema string) error // 执行成功后写入表结构，为空时不导出
+
+	singleTx bool    // 是否在单个事务中执行全部待执行的处理程序
+	tx       *sql.Tx // 单事务模式执行期间的事务
+	external bool    // tx 是否由调用方通过 FromTx 传入
 
 	executors []Executor // 运行器列表
 	handlers  []Handler  // 运行单元列表
 }
 
-func New(db *sql.DB, options ...Option) Migrate {
+func New(db DBTX, options ...Option) Migrate {
 	migrate := migrate{
-		db:          db,
+		db:          normalizeDB(db),
 		schemaTable: defaultSchemaTableName,
+		dialect:     MySQL,
+		logger:      noopLogger{},
+		tracer:      noop.NewTracerProvider().Tracer(tracerName),
+		hostname:    hostname(),
 	}
 	for _, option := range options {
 		option(&migrate)
 	}
+	// 调用方传入的事务，全部语句在其中执行
+	if tx, ok := migrate.db.(txDB); ok {
+		migrate.tx, migrate.external = tx.Tx, true
+	}
+	if migrate.historyTable == "" {
+		migrate.historyTable = migrate.schemaTable + defaultHistoryTableSuffix
+	}
+	migrate.runningTable = migrate.schemaTable + defaultRunningTableSuffix
+	if migrate.locker == nil {
+		migrate.locker = noopLocker{}
+		if migrate.store != nil {
+			migrate.locker = migrate.store
+		} else if migrate.advisoryLock {
+			migrate.locker = NewAdvisoryLocker(migrate.db, migrate.dialect, migrate.schemaTable)
+		}
+	}
+	if migrate.store == nil {
+		migrate.store = &sqlSchemaStore{Locker: migrate.locker, db: migrate.db, dialect: migrate.dialect, table: migrate.qualify(migrate.schemaTable),
+			options: migrate.tableOptions, repair: migrate.repairSchema, logger: migrate.logger}
+	}
 	return &migrate
 }
 
@@ -83,100 +207,584 @@ func (m *migrate) AddHandlers(handlers ...Handler) {
 }
 
 func (m *migrate) Run(ctx context.Context) error {
+	_, err := m.RunWithResult(ctx)
+	return err
+}
+
+func (m *migrate) RunWithResult(ctx context.Context) (*Report, error) {
 	m.mutex.Lock()
 	defer m.mutex.Unlock()
-	// 1.进行 handlers 排序及 index 校验
-	err := m.initHandlers()
+	start := time.Now()
+	report := &Report{}
+	err := m.runShadow(ctx, report)
 	if err != nil {
-		return err
+		report.Duration = time.Since(start)
+		return report, err
 	}
-	// 2.创建 schema 表
-	_, err = m.db.Exec(fmt.Sprintf(createSchemaTableQuery, m.schemaTable))
+	err = m.operate(ctx, "migrate.Run", func(ctx context.Context) error {
+		handlers, schema, err := m.prepare(ctx)
+		if err != nil {
+			return err
+		}
+		applied, pending, err := m.split(ctx, handlers, schema.version)
+		if err != nil {
+			return err
+		}
+		report.StartVersion, report.FinalVersion, report.Skipped = schema.version, schema.version, len(applied)
+		// 维护窗口外推迟受限的处理程序
+		pending, deferred := m.deferWindow(pending)
+		for _, handler := range deferred {
+			report.Deferred = append(report.Deferred, HandlerResult{Index: handler.GetIndex(), Name: nameOf(handler)})
+		}
+		// 顺序执行未执行的处理程序
+		report.Applied, err = m.up(ctx, pending, schema.version)
+		for _, result := range report.Applied {
+			report.FinalVersion = max(report.FinalVersion, result.Index)
+		}
+		if err != nil {
+			// 并发执行失败时索引更大的处理程序可能已执行成功，以存储的 version 为准
+			if version, _, readErr := m.store.GetVersion(context.WithoutCancel(ctx)); readErr == nil && m.dryRun == nil {
+				report.FinalVersion = version
+			}
+			return err
+		}
+		// 最后执行校验和变更的可重复执行处理程序
+		report.Repeated, err = m.repeat(ctx, report.FinalVersion)
+		if err != nil {
+			return err
+		}
+		return m.writeSchemaDump(ctx)
+	})
+	report.Duration = time.Since(start)
+	return report, err
+}
+
+func (m *migrate) Down(ctx context.Context, steps int) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return m.operate(ctx, "migrate.Down", func(ctx context.Context) error {
+		handlers, schema, err := m.prepare(ctx)
+		if err != nil {
+			return err
+		}
+		applied, _, err := m.split(ctx, handlers, schema.version)
+		if err != nil {
+			return err
+		}
+		if steps <= 0 || steps > len(applied) {
+			steps = len(applied)
+		}
+		return m.down(ctx, applied, steps)
+	})
+}
+
+func (m *migrate) Steps(ctx context.Context, n int) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return m.operate(ctx, "migrate.Steps", func(ctx context.Context) error {
+		handlers, schema, err := m.prepare(ctx)
+		if err != nil {
+			return err
+		}
+		applied, pending, err := m.split(ctx, handlers, schema.version)
+		if err != nil {
+			return err
+		}
+		switch {
+		case n > 0:
+			_, err = m.up(ctx, pending[:min(n, len(pending))], schema.version)
+			return err
+		case n < 0:
+			return m.down(ctx, applied, min(-n, len(applied)))
+		}
+		return nil
+	})
+}
+
+func (m *migrate) MigrateTo(ctx context.Context, version int) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return m.operate(ctx, "migrate.MigrateTo", func(ctx context.Context) error {
+		handlers, schema, err := m.prepare(ctx)
+		if err != nil {
+			return err
+		}
+		// 目标版本必须为 0 或某个处理程序的索引
+		target := searchPending(handlers, version)
+		if version != 0 && (target == 0 || handlers[target-1].GetIndex() != version) {
+			return errors.Errorf(ErrVersionNotFoundFormat, version)
+		}
+		applied, pending, err := m.split(ctx, handlers, schema.version)
+		if err != nil {
+			return err
+		}
+		if version >= schema.version {
+			_, err = m.up(ctx, pending[:searchPending(pending, version)], schema.version)
+			return err
+		}
+		return m.down(ctx, applied, len(applied)-searchPending(applied, version))
+	})
+}
+
+func (m *migrate) Force(ctx context.Context, version int) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return m.operate(ctx, "migrate.Force", func(ctx context.Context) error {
+		_, err := m.initAndGetSchema(ctx)
+		if err != nil {
+			return err
+		}
+		return m.store.SetDirty(ctx, version, false)
+	})
+}
+
+// prepare 初始化处理程序列表及概要表，返回排序后的处理程序及当前概要，概要为 dirty 时返回错误
+func (m *migrate) prepare(ctx context.Context) ([]Handler, *schema, error) {
+	handlers, schema, err := m.load(ctx)
 	if err != nil {
+		return nil, nil, err
+	}
+	if schema.dirty {
+		return nil, nil, m.dirtyError(ctx, schema.version)
+	}
+	// 其他实例执行中或执行中途崩溃时返回错误
+	err = m.checkRunning(ctx, schema.version)
+	if err != nil {
+		return nil, nil, err
+	}
+	err = m.verifyChecksums(ctx, handlers[:searchPending(handlers, schema.version)])
+	if err != nil {
+		return nil, nil, err
+	}
+	return handlers, schema, nil
+}
+
+// dirtyError 返回 dirty 错误，历史表中有失败记录时附带失败原因
+func (m *migrate) dirtyError(ctx context.Context, version int) error {
+	err := &DirtyError{Version: version}
+	histories, innerErr := m.loadHistory(ctx)
+	if innerErr != nil {
 		return errors.WithStack(err)
 	}
-	// 3.获取当前 schema 并校验
-	schema, err := m.initAndGetSchema()
+	if h, ok := lastFailure(histories, version); ok {
+		err.Reason = h.errMsg
+	} else if n := len(histories); n > 0 && !histories[n-1].success {
+		// 乱序执行失败时，失败的处理程序索引小于 version
+		err.Reason = histories[n-1].errMsg
+	}
+	return errors.WithStack(err)
+}
+
+// load 初始化处理程序列表及概要表，返回排序后的处理程序及当前概要
+func (m *migrate) load(ctx context.Context) ([]Handler, *schema, error) {
+	// 1.进行 handlers 排序及 index 校验
+	handlers, err := m.initHandlers()
 	if err != nil {
-		return err
+		return nil, nil, err
+	}
+	// 2.创建历史表及执行状态表
+	err = m.createHistoryTable(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	err = m.createRunningTable(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	// 3.初始化并获取当前 schema 并校验
+	schema, err := m.initAndGetSchema(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(handlers) == 0 && schema.version > 0 ||
+		len(handlers) > 0 && schema.version > handlers[len(handlers)-1].GetIndex() {
+		return nil, nil, ErrIndexLessDatabaseVersion
+	}
+	// 基线仅能在新库上执行，压缩前未执行到基线的库需先用压缩前的处理程序迁移
+	if len(handlers) > 0 && isBaseline(handlers[0]) && schema.version > 0 && schema.version < handlers[0].GetIndex() {
+		return nil, nil, errors.Errorf(ErrBaselineFormat, schema.version, handlers[0].GetIndex())
+	}
+	return handlers, schema, nil
+}
+
+// up 顺序执行处理程序，每执行成功一个即更新 version
+func (m *migrate) up(ctx context.Context, handlers []Handler, version int) ([]HandlerResult, error) {
+	if m.dryRun != nil {
+		return nil, m.printUp(handlers)
+	}
+	err := m.checkDestructive(handlers)
+	if err != nil {
+		return nil, err
+	}
+	err = m.checkParallel()
+	if err != nil {
+		return nil, err
+	}
+	handlers, committed, err := m.skipCommitted(ctx, handlers)
+	if err != nil {
+		return nil, err
+	}
+	if m.singleTx && len(handlers) > 0 {
+		err = m.beginSingleTx(ctx, handlers)
+		if err != nil {
+			return nil, err
+		}
+		defer m.rollbackSingleTx()
+	}
+	m.logger.Info("migrate up start", "pending", len(handlers))
+	runStart := time.Now()
+	var applied []int
+	var results []HandlerResult
+	for _, batch := range m.batches(handlers) {
+		// 互不依赖的处理程序并发执行
+		if len(batch) > 1 {
+			batchResults, err := m.executeBatch(ctx, batch, version)
+			for _, result := range batchResults {
+				applied = append(applied, result.Index)
+				results = append(results, result)
+			}
+			if err != nil {
+				m.complete(ctx, DirectionUp, applied, runStart, err)
+				return results, err
+			}
+			version = max(version, batch[len(batch)-1].GetIndex())
+			continue
+		}
+		handler := batch[0]
+		// 乱序执行的处理程序不回退 version
+		version = max(version, handler.GetIndex())
+		// 受限的处理程序等待维护窗口开始
+		var duration time.Duration
+		err := m.waitWindow(ctx, handler)
+		if err == nil {
+			duration, err = m.execute(ctx, handler, DirectionUp, version)
+		}
+		// 可选处理程序失败时不计入执行成功的处理程序
+		if isSkipped(err) {
+			continue
+		}
+		if err != nil {
+			// 单事务模式下已执行的处理程序均已回滚
+			if m.singleTx {
+				applied, results = nil, nil
+			}
+			m.complete(ctx, DirectionUp, applied, runStart, err)
+			return results, err
+		}
+		applied = append(applied, handler.GetIndex())
+		results = append(results, HandlerResult{Index: handler.GetIndex(), Name: nameOf(handler), Duration: duration})
+	}
+	err = m.commitSingleTx()
+	if err != nil {
+		m.complete(ctx, DirectionUp, nil, runStart, err)
+		return nil, err
+	}
+	// 全部执行成功后，中断前已提交的处理程序计入 version
+	if committed > version {
+		err = m.setVersion(context.WithoutCancel(ctx), nil, committed)
+		if err != nil {
+			m.complete(ctx, DirectionUp, applied, runStart, err)
+			return results, err
+		}
+	}
+	m.logger.Info("migrate up finish", "applied", len(handlers))
+	m.complete(ctx, DirectionUp, applied, runStart, nil)
+	return results, nil
+}
+
+// down 逆序回滚 applied 中最后 steps 个处理程序，version 回退至前一个处理程序的索引
+func (m *migrate) down(ctx context.Context, applied []Handler, steps int) error {
+	// 回滚前校验所有处理程序均支持回滚，避免回滚到一半才失败
+	targets := applied[len(applied)-steps:]
+	for _, handler := range targets {
+		if _, ok := handler.(DownHandler); !ok {
+			return errors.Errorf(ErrNotDownHandlerFormat, handler.GetIndex())
+		}
 	}
-	if schema.version > len(m.handlers) {
-		return ErrIndexLessDatabaseVersion
+	if m.dryRun != nil {
+		reversed := make([]Handler, 0, steps)
+		for i := len(targets) - 1; i >= 0; i-- {
+			reversed = append(reversed, targets[i])
+		}
+		return m.printDown(reversed)
 	}
-	// 4.顺序执行
-	for idx := schema.version; idx < len(m.handlers); idx++ {
-		err = m.handlers[idx].Exec(ctx)
+	m.logger.Info("migrate down start", "steps", steps)
+	runStart := time.Now()
+	var reverted []int
+	for i := len(applied) - 1; i >= len(applied)-steps; i-- {
+		version := 0
+		if i > 0 {
+			version = applied[i-1].GetIndex()
+		}
+		_, err := m.execute(ctx, applied[i], DirectionDown, version)
 		if err != nil {
-			// 发生错误时，记录 dirty 到 schema 表
-			_, innerErr := m.db.Exec(fmt.Sprintf(updateDirtyQuery, m.schemaTable),
-				m.handlers[idx].GetIndex(), 1)
-			if innerErr != nil {
-				return errors.WithStack(innerErr)
+			m.complete(ctx, DirectionDown, reverted, runStart, err)
+			return err
+		}
+		reverted = append(reverted, applied[i].GetIndex())
+	}
+	m.logger.Info("migrate down finish", "reverted", steps)
+	m.complete(ctx, DirectionDown, reverted, runStart, nil)
+	return nil
+}
+
+// execute 执行或回滚单个处理程序，成功后将 version 更新为 version，失败时标记 dirty，返回执行耗时
+func (m *migrate) execute(ctx context.Context, handler Handler, direction string, version int) (time.Duration, error) {
+	h, err := m.run(ctx, handler, direction, version, version)
+	if err != nil {
+		return h.duration, m.handleFailure(ctx, handler, h, version, err)
+	}
+	event := HandlerEvent{Index: h.version, Name: h.name, Direction: direction, Version: version}
+	return h.duration, m.succeed(ctx, event, h)
+}
+
+// run 执行或回滚单个处理程序，执行成功后将 version 更新为 target，返回执行结果的历史记录，不记录结果，并发执行时由调用方按顺序处理结果
+func (m *migrate) run(ctx context.Context, handler Handler, direction string, version, target int) (history, error) {
+	event := HandlerEvent{Index: handler.GetIndex(), Name: nameOf(handler), Direction: direction, Version: version}
+	m.logger.Info("handler start", "index", event.Index, "name", event.Name, "direction", event.Direction)
+	m.beforeHandler(ctx, event)
+	start := time.Now()
+	spanCtx, span := m.startHandlerSpan(ctx, handler, direction)
+	handlerCtx, cancel := m.handlerContext(spanCtx, handler)
+	finish, err := m.startRunning(ctx, handler, direction)
+	if err == nil {
+		err = m.applyWithRetry(handlerCtx, handler, direction, target)
+		finish()
+	}
+	cancel()
+	endSpan(span, err)
+	return newHistory(handler, direction, start, err), err
+}
+
+// handleFailure 处理执行失败的处理程序，version 为执行成功后的 version
+func (m *migrate) handleFailure(ctx context.Context, handler Handler, h history, version int, err error) error {
+	// 发生错误时，记录 dirty 到 schema 表
+	// 回滚失败时标记回滚中的处理程序
+	dirty := version
+	if h.direction == DirectionDown {
+		dirty = handler.GetIndex()
+	}
+	cause := handlerError(handler, h.direction, err)
+	// 可选处理程序失败时不标记 dirty
+	if m.canSkip(handler, h.direction) {
+		return m.skip(ctx, h, version, cause)
+	}
+	// 开启自动回滚时执行回滚，不标记 dirty
+	if m.canRollback(handler, h.direction) {
+		return m.rollback(ctx, handler, h, version, cause)
+	}
+	return m.fail(ctx, h, dirty, cause)
+}
+
+// apply 执行处理程序并更新 version；
+// 处理程序支持事务时，在同一事务中更新 version，避免处理程序提交后 version 未更新；
+// version 为 batchVersion 时不更新 version，改为记录执行成功的历史，支持事务时在同一事务中记录
+func (m *migrate) apply(ctx context.Context, handler Handler, direction string, version int) error {
+	start := time.Now()
+	exec, execTx := handlerFuncs(handler, direction)
+	// 单事务模式下使用外部事务，由 up 统一提交
+	if m.tx != nil {
+		// 调用方传入的事务中，不支持事务的处理程序直接执行
+		if execTx == nil {
+			execTx = func(ctx context.Context, tx *sql.Tx) error {
+				return exec(ctx)
 			}
+		}
+		err := execTx(ctx, m.tx)
+		if err != nil {
+			return err
+		}
+		return m.setVersion(ctx, m.tx, version)
+	}
+	// 非数据库存储可不指定 db，处理程序不在事务中执行
+	if execTx == nil || m.db == nil {
+		err := exec(ctx)
+		if err != nil {
+			return err
+		}
+		// 可重复执行的处理程序不更新 version
+		if direction == DirectionRepeat {
+			return nil
+		}
+		// 处理程序已执行完成，更新 version 不受 ctx 取消影响
+		if version == batchVersion {
+			return m.recordHistory(context.WithoutCancel(ctx), newHistory(handler, direction, start, nil))
+		}
+		return m.setVersion(context.WithoutCancel(ctx), nil, version)
+	}
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	err = execTx(ctx, tx)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	if version == batchVersion {
+		err = m.recordHistoryTo(ctx, tx, newHistory(handler, direction, start, nil))
+		if err != nil {
+			tx.Rollback()
 			return err
 		}
-		// 成功时更新 version 字段
-		_, err = m.db.Exec(fmt.Sprintf(updateSchemaQuery, m.schemaTable),
-			m.handlers[idx].GetIndex())
+		return errors.WithStack(tx.Commit())
+	}
+	// 可重复执行的处理程序不更新 version；存储不支持事务时在提交后更新
+	_, inTx := m.store.(TxSchemaStore)
+	if direction != DirectionRepeat && inTx {
+		err = m.setVersion(ctx, tx, version)
 		if err != nil {
-			return errors.WithStack(err)
+			tx.Rollback()
+			return err
 		}
 	}
+	err = tx.Commit()
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if direction != DirectionRepeat && !inTx {
+		return m.setVersion(context.WithoutCancel(ctx), nil, version)
+	}
 	return nil
 }
 
-// initHandlers 初始化处理程序列表，并进行索引详细判断
-func (m *migrate) initHandlers() error {
-	// 1.获取所有的 handlers
-	for _, e := range m.executors {
-		handlers, err := e.ListHandlers()
+// handlerFuncs 返回处理程序对应方向的执行函数，execTx 为空表示不支持在外部事务中执行
+func handlerFuncs(handler Handler, direction string) (func(context.Context) error, func(context.Context, *sql.Tx) error) {
+	h, ok := handler.(NoTxHandler)
+	noTx := ok && h.NoTx()
+	if direction == DirectionDown {
+		if h, ok := handler.(DownTxHandler); ok && !noTx {
+			return h.DownExec, h.DownExecTx
+		}
+		return handler.(DownHandler).DownExec, nil
+	}
+	if h, ok := handler.(TxHandler); ok && !noTx {
+		return h.Exec, h.ExecTx
+	}
+	return handler.Exec, nil
+}
+
+// handlerContext 为处理程序设置限流器及超时时间，处理程序未指定时使用 WithHandlerTimeout 的配置
+func (m *migrate) handlerContext(ctx context.Context, handler Handler) (context.Context, context.CancelFunc) {
+	ctx = m.throttleContext(ctx, handler)
+	timeout := m.handlerTimeout
+	if h, ok := handler.(TimeoutHandler); ok && h.GetTimeout() > 0 {
+		timeout = h.GetTimeout()
+	}
+	if timeout <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+// succeed 记录成功日志及历史
+func (m *migrate) succeed(ctx context.Context, event HandlerEvent, h history) error {
+	// 处理程序已执行完成，记录结果不受 ctx 取消影响
+	ctx = context.WithoutCancel(ctx)
+	m.notifySuccess(ctx, event, h)
+	return m.recordHistory(ctx, h)
+}
+
+// notifySuccess 记录成功日志并触发 AfterHandler，不记录历史
+func (m *migrate) notifySuccess(ctx context.Context, event HandlerEvent, h history) {
+	m.logger.Info("handler finish", "index", h.version, "name", h.name, "direction", h.direction, "duration", h.duration)
+	event.Duration = h.duration
+	m.afterHandler(ctx, event)
+}
+
+// fail 将 version 标记为 dirty 并记录失败历史，返回原始错误
+func (m *migrate) fail(ctx context.Context, h history, version int, cause error) error {
+	ctx = context.WithoutCancel(ctx)
+	m.logger.Error("handler failed", "index", h.version, "name", h.name, "direction", h.direction, "duration", h.duration, "error", cause)
+	m.onError(ctx, HandlerEvent{Index: h.version, Name: h.name, Direction: h.direction, Version: version, Duration: h.duration}, cause)
+	// 调用方传入的事务由调用方回滚，事务可能已中止，不再写入
+	if m.external {
+		return cause
+	}
+	// 单事务模式下回滚全部变更，version 保持执行前的值；
+	// 可重复执行的处理程序失败不影响 version，修复后再次执行即可
+	if m.tx != nil {
+		m.rollbackSingleTx()
+		m.logger.Warn("rollback single transaction", "version", version)
+	} else if h.direction != DirectionRepeat {
+		err := m.store.SetDirty(ctx, version, true)
 		if err != nil {
+			m.logger.Error("mark dirty failed", "version", version, "error", err)
 			return err
 		}
-		m.handlers = append(m.handlers, handlers...)
+		m.logger.Warn("mark dirty", "version", version)
+	}
+	err := m.recordHistory(ctx, h)
+	if err != nil {
+		return err
+	}
+	return cause
+}
+
+// listHandlers 返回直接添加及运行器中的所有处理程序
+func (m *migrate) listHandlers() ([]Handler, error) {
+	handlers := append([]Handler{}, m.handlers...)
+	for _, e := range m.executors {
+		list, err := e.ListHandlers()
+		if err != nil {
+			return nil, err
+		}
+		handlers = append(handlers, list...)
+	}
+	return handlers, nil
+}
+
+// initHandlers 初始化处理程序列表，并进行索引详细判断
+func (m *migrate) initHandlers() ([]Handler, error) {
+	// 1.获取所有的 handlers，可重复执行的处理程序单独处理
+	all, err := m.listHandlers()
+	if err != nil {
+		return nil, err
+	}
+	var handlers []Handler
+	for _, handler := range all {
+		if !isRepeatable(handler) {
+			handlers = append(handlers, handler)
+		}
 	}
 	// 2.排序
-	sort.Slice(m.handlers, func(i, j int) bool {
-		return m.handlers[i].GetIndex() < m.handlers[j].GetIndex()
+	sort.Slice(handlers, func(i, j int) bool {
+		return handlers[i].GetIndex() < handlers[j].GetIndex()
 	})
 	// 3.进行 index 校验
-	length := len(m.handlers)
+	length := len(handlers)
 	for i := 0; i < length-1; i++ {
-		result := m.handlers[i+1].GetIndex() - m.handlers[i].GetIndex()
-		if result == 1 {
+		result := handlers[i+1].GetIndex() - handlers[i].GetIndex()
+		if result == 1 || result > 1 && m.allowGaps {
 			continue
 		} else if result == 0 {
-			return errors.Errorf(ErrDuplicateIndexFormat, m.handlers[i].GetIndex())
+			return nil, errors.WithStack(&DuplicateIndexError{Index: handlers[i].GetIndex()})
 		} else {
-			return errors.Errorf(ErrIndexGapLargeFormat, m.handlers[i].GetIndex())
+			return nil, errors.WithStack(&GapError{Index: handlers[i].GetIndex()})
 		}
 	}
-	return nil
-}
-
-// initAndGetSchema 初始化或获取概要记录
-func (m *migrate) initAndGetSchema() (*schema, error) {
-	rows, err := m.db.Query(fmt.Sprintf(selectSchemaQuery, m.schemaTable))
+	// 4.校验依赖
+	_, err = dependencyLevels(handlers)
 	if err != nil {
-		return nil, errors.WithStack(err)
-	}
-	var sche schema
-	if !rows.Next() {
-		_, err := m.db.Exec(fmt.Sprintf(insertDefaultSchema, m.schemaTable))
-		if err != nil {
-			return nil, errors.WithStack(err)
-		}
-	} else {
-		err = rows.Scan(&sche.version, &sche.dirty)
-		if err != nil {
-			return nil, errors.WithStack(err)
-		}
+		return nil, err
 	}
+	return handlers, nil
+}
 
-	if sche.dirty {
-		return nil, errors.Errorf(ErrFindIndexDirtyFormat, sche.version)
+// searchPending 返回第一个未执行处理程序的位置，handlers 需已排序
+func searchPending(handlers []Handler, version int) int {
+	return sort.Search(len(handlers), func(i int) bool {
+		return handlers[i].GetIndex() > version
+	})
+}
+
+// initAndGetSchema 初始化版本存储并获取概要记录
+func (m *migrate) initAndGetSchema(ctx context.Context) (*schema, error) {
+	err := m.store.Init(ctx)
+	if err != nil {
+		return nil, err
 	}
-	return &sche, nil
+	return m.readSchema(ctx)
 }
 
 type schema struct {
@@ -197,3 +805,104 @@ func WithExecutors(executors ...Executor) Option {
 		m.AddExecutors(executors...)
 	}
 }
+
+func WithDialect(dialect Dialect) Option {
+	return func(m *migrate) {
+		m.dialect = dialect
+	}
+}
+
+// WithAdvisoryLock 执行期间持有数据库咨询锁，防止多个实例并发执行迁移
+func WithAdvisoryLock() Option {
+	return func(m *migrate) {
+		m.advisoryLock = true
+	}
+}
+
+// WithLocker 指定迁移锁，优先于 WithAdvisoryLock
+func WithLocker(locker Locker) Option {
+	return func(m *migrate) {
+		m.locker = locker
+	}
+}
+
+// WithLockTimeout 获取迁移锁超过 timeout 时返回 ErrLockTimeout，默认一直等待
+func WithLockTimeout(timeout time.Duration) Option {
+	return func(m *migrate) {
+		m.lockTimeout = timeout
+	}
+}
+
+// WithDryRun 仅将待执行的处理程序及 sql 语句输出到 w，不执行也不更新概要表，w 为空时输出到标准输出
+func WithDryRun(w io.Writer) Option {
+	return func(m *migrate) {
+		if w == nil {
+			w = os.Stdout
+		}
+		m.dryRun = w
+	}
+}
+
+// WithHistoryTableName 指定历史表名称，默认为概要表名称加 _history 后缀
+func WithHistoryTableName(tableName string) Option {
+	return func(m *migrate) {
+		m.historyTable = tableName
+	}
+}
+
+// WithoutHistory 关闭历史表
+func WithoutHistory() Option {
+	return func(m *migrate) {
+		m.noHistory = true
+	}
+}
+
+// WithLogger 指定日志，默认不输出
+func WithLogger(logger Logger) Option {
+	return func(m *migrate) {
+		m.logger = logger
+	}
+}
+
+// WithHooks 注册事件回调，可多次调用，回调按注册顺序执行
+func WithHooks(hooks Hooks) Option {
+	return func(m *migrate) {
+		m.hooks = append(m.hooks, hooks)
+	}
+}
+
+// WithTracerProvider 指定 OpenTelemetry TracerProvider，默认不追踪
+func WithTracerProvider(provider trace.TracerProvider) Option {
+	return func(m *migrate) {
+		m.tracer = provider.Tracer(tracerName)
+	}
+}
+
+// WithAllowOutOfOrder 允许执行索引小于当前 version 的未执行处理程序，需开启历史表
+func WithAllowOutOfOrder() Option {
+	return func(m *migrate) {
+		m.allowOutOfOrder = true
+	}
+}
+
+// WithAllowGaps 允许处理程序索引不连续，如废弃或合并后删除的索引，重复索引仍会报错
+func WithAllowGaps() Option {
+	return func(m *migrate) {
+		m.allowGaps = true
+	}
+}
+
+// WithSingleTransaction 在单个事务中执行全部待执行的处理程序，失败时全部回滚，不标记 dirty；
+// 处理程序需均支持事务，数据库需支持事务性 DDL，如 Postgres
+func WithSingleTransaction() Option {
+	return func(m *migrate) {
+		m.singleTx = true
+	}
+}
+
+// WithHandlerTimeout 指定单个处理程序的超时时间，处理程序可通过 TimeoutHandler 覆盖
+func WithHandlerTimeout(timeout time.Duration) Option {
+	return func(m *migrate) {
+		m.handlerTimeout = timeout
+	}
+}
@@ -0,0 +1,88 @@
+package migratetest
+
+import (
+	"context"
+	"testing"
+
+	"github.com/pkg/errors"
+
+	"powerlaw.ai/powerlib/migrate"
+)
+
+/*
+迁移断言，失败时通过 testing.TB 报告并终止当前测试
+*/
+
+// New 创建在测试库上执行的迁移客户端，使用测试库的方言，测试库可随时删除，默认开启 WithAllowUnsafe
+func New(db *Database, options ...migrate.Option) migrate.Migrate {
+	return migrate.New(db.DB, append([]migrate.Option{migrate.WithDialect(db.Dialect), migrate.WithAllowUnsafe()}, options...)...)
+}
+
+// Run 执行全部待执行的处理程序，失败时终止测试
+func Run(t testing.TB, m migrate.Migrate) {
+	t.Helper()
+	err := m.Run(context.Background())
+	if err != nil {
+		t.Fatalf("migrate: %+v", err)
+	}
+}
+
+// RoundTrip 执行全部处理程序后逐个回滚再重新执行，回滚失败或残留表结构时终止测试
+func RoundTrip(t testing.TB, m migrate.Migrate) {
+	t.Helper()
+	err := m.RoundTrip(context.Background())
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+}
+
+// AssertVersion 断言当前 version 等于 version 且不为 dirty
+func AssertVersion(t testing.TB, m migrate.Migrate, version int) {
+	t.Helper()
+	current, dirty, err := m.Version(context.Background())
+	if err != nil {
+		t.Fatalf("read version: %+v", err)
+	}
+	if current != version || dirty {
+		t.Fatalf("version is %d (dirty %t), expected %d", current, dirty, version)
+	}
+}
+
+// AssertTableExists 断言当前库存在表 table
+func AssertTableExists(t testing.TB, db *Database, table string) {
+	t.Helper()
+	if !tableExists(t, db, table) {
+		t.Fatalf("table %s does not exist", table)
+	}
+}
+
+// AssertTableNotExists 断言当前库不存在表 table，用于校验回滚
+func AssertTableNotExists(t testing.TB, db *Database, table string) {
+	t.Helper()
+	if tableExists(t, db, table) {
+		t.Fatalf("table %s exists", table)
+	}
+}
+
+// AssertChecksums 断言已执行的处理程序与历史表记录的校验和一致
+func AssertChecksums(t testing.TB, m migrate.Migrate) {
+	t.Helper()
+	err := m.VerifyChecksums(context.Background())
+	var mismatch *migrate.ChecksumMismatchError
+	if errors.As(err, &mismatch) {
+		t.Fatalf("checksum of applied handler %d changed", mismatch.Index)
+	}
+	if err != nil {
+		t.Fatalf("verify checksums: %+v", err)
+	}
+}
+
+func tableExists(t testing.TB, db *Database, table string) bool {
+	t.Helper()
+	var n int
+	err := db.DB.QueryRowContext(context.Background(), db.tableQuery, table).Scan(&n)
+	if err != nil {
+		t.Fatalf("query table %s: %v", table, err)
+	}
+	return n > 0
+}
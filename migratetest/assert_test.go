@@ -0,0 +1,49 @@
+package migratetest_test
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"powerlaw.ai/powerlib/migrate"
+	"powerlaw.ai/powerlib/migrate/concrete"
+	"powerlaw.ai/powerlib/migrate/migratetest"
+)
+
+// fatalRecorder 记录首次 Fatalf 而不终止测试，用于断言辅助函数失败
+type fatalRecorder struct {
+	testing.TB
+	message string
+}
+
+func (r *fatalRecorder) Helper() {}
+
+func (r *fatalRecorder) Fatalf(format string, args ...any) {
+	if r.message == "" {
+		r.message = fmt.Sprintf(format, args...)
+	}
+}
+
+func TestAssertChecksumsEditedFile(t *testing.T) {
+	db := migratetest.SQLite(t)
+	dir := t.TempDir()
+	file := filepath.Join(dir, "1_a.up.sql")
+	err := os.WriteFile(file, []byte("CREATE TABLE a (id INTEGER);"), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	migratetest.Run(t, migratetest.New(db, migrate.WithExecutors(concrete.NewSQLExecutor(db.DB, dir))))
+	migratetest.AssertChecksums(t, migratetest.New(db, migrate.WithExecutors(concrete.NewSQLExecutor(db.DB, dir))))
+
+	// 修改已执行的文件
+	err = os.WriteFile(file, []byte("CREATE TABLE a (id INTEGER, name TEXT);"), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	recorder := &fatalRecorder{TB: t}
+	migratetest.AssertChecksums(recorder, migratetest.New(db, migrate.WithExecutors(concrete.NewSQLExecutor(db.DB, dir))))
+	if recorder.message != "checksum of applied handler 1 changed" {
+		t.Fatalf("AssertChecksums reported %q, expected a checksum mismatch", recorder.message)
+	}
+}
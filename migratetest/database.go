@@ -0,0 +1,174 @@
+package migratetest
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	_ "github.com/go-sql-driver/mysql"
+	_ "github.com/lib/pq"
+	"github.com/pkg/errors"
+	_ "modernc.org/sqlite"
+
+	"powerlaw.ai/powerlib/migrate"
+)
+
+/*
+迁移测试工具，启动一次性的 MySQL 或 Postgres 容器执行项目的迁移，并提供 version、表及校验和的断言；
+容器通过 docker 命令启动，测试结束时删除，未安装 docker 时跳过测试，SQLite 使用临时文件无需容器
+*/
+
+const (
+	defaultMySQLImage    = "mysql:8.0"
+	defaultPostgresImage = "postgres:16-alpine"
+	defaultStartTimeout  = 2 * time.Minute
+	pingInterval         = 500 * time.Millisecond
+	password             = "migrate"
+	database             = "migrate"
+)
+
+// Database 测试库
+type Database struct {
+	DB      *sql.DB
+	DSN     string
+	Dialect migrate.Dialect
+
+	tableQuery string // 查询表是否存在的语句，参数为表名
+}
+
+type config struct {
+	image   string
+	timeout time.Duration
+}
+
+type Option func(c *config)
+
+// WithImage 指定容器镜像，如 mysql:5.7 或 postgres:12
+func WithImage(image string) Option {
+	return func(c *config) {
+		c.image = image
+	}
+}
+
+// WithStartTimeout 指定等待数据库就绪的超时时间，默认为 2 分钟
+func WithStartTimeout(timeout time.Duration) Option {
+	return func(c *config) {
+		c.timeout = timeout
+	}
+}
+
+// MySQL 启动 MySQL 容器并返回其中的空库
+func MySQL(t testing.TB, options ...Option) *Database {
+	t.Helper()
+	c := newConfig(defaultMySQLImage, options)
+	port := startContainer(t, c, "3306/tcp", "MYSQL_ROOT_PASSWORD="+password, "MYSQL_DATABASE="+database)
+	dsn := fmt.Sprintf("root:%s@tcp(127.0.0.1:%s)/%s?parseTime=true", password, port, database)
+	return &Database{
+		DB:         open(t, c, "mysql", dsn),
+		DSN:        dsn,
+		Dialect:    migrate.MySQL,
+		tableQuery: "SELECT COUNT(*) FROM information_schema.tables WHERE table_schema = DATABASE() AND table_name = ?",
+	}
+}
+
+// Postgres 启动 Postgres 容器并返回其中的空库
+func Postgres(t testing.TB, options ...Option) *Database {
+	t.Helper()
+	c := newConfig(defaultPostgresImage, options)
+	port := startContainer(t, c, "5432/tcp", "POSTGRES_PASSWORD="+password, "POSTGRES_DB="+database)
+	dsn := fmt.Sprintf("postgres://postgres:%s@127.0.0.1:%s/%s?sslmode=disable", password, port, database)
+	return &Database{
+		DB:         open(t, c, "postgres", dsn),
+		DSN:        dsn,
+		Dialect:    migrate.Postgres,
+		tableQuery: "SELECT COUNT(*) FROM information_schema.tables WHERE table_schema = current_schema() AND table_name = $1",
+	}
+}
+
+// SQLite 在测试的临时目录中创建 SQLite 库，无需容器
+func SQLite(t testing.TB) *Database {
+	t.Helper()
+	dsn := filepath.Join(t.TempDir(), "migrate.db")
+	return &Database{
+		DB:         open(t, newConfig("", nil), "sqlite", dsn),
+		DSN:        dsn,
+		Dialect:    migrate.SQLite,
+		tableQuery: "SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = ?",
+	}
+}
+
+func newConfig(image string, options []Option) *config {
+	c := &config{image: image, timeout: defaultStartTimeout}
+	for _, option := range options {
+		option(c)
+	}
+	return c
+}
+
+// startContainer 启动容器并返回映射到本机的端口，测试结束时删除容器
+func startContainer(t testing.TB, c *config, port string, env ...string) string {
+	t.Helper()
+	if _, err := exec.LookPath("docker"); err != nil {
+		t.Skip("docker is not installed")
+	}
+	if err := exec.Command("docker", "info").Run(); err != nil {
+		t.Skip("docker is not available")
+	}
+	args := []string{"run", "-d", "-p", "127.0.0.1::" + strings.TrimSuffix(port, "/tcp")}
+	for _, e := range env {
+		args = append(args, "-e", e)
+	}
+	output, err := exec.Command("docker", append(args, c.image)...).Output()
+	if err != nil {
+		t.Fatalf("start %s: %v", c.image, commandError(err))
+	}
+	id := strings.TrimSpace(string(output))
+	t.Cleanup(func() {
+		exec.Command("docker", "rm", "-f", "-v", id).Run()
+	})
+	output, err = exec.Command("docker", "port", id, port).Output()
+	if err != nil {
+		t.Fatalf("port of %s: %v", c.image, commandError(err))
+	}
+	// 输出如 127.0.0.1:49153，可能包含多行
+	line := strings.SplitN(strings.TrimSpace(string(output)), "\n", 2)[0]
+	return line[strings.LastIndex(line, ":")+1:]
+}
+
+// open 连接数据库并等待就绪，测试结束时关闭
+func open(t testing.TB, c *config, driver, dsn string) *sql.DB {
+	t.Helper()
+	db, err := sql.Open(driver, dsn)
+	if err != nil {
+		t.Fatalf("open %s: %v", driver, err)
+	}
+	t.Cleanup(func() {
+		db.Close()
+	})
+	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+	defer cancel()
+	for {
+		err = db.PingContext(ctx)
+		if err == nil {
+			return db
+		}
+		select {
+		case <-ctx.Done():
+			t.Fatalf("wait for %s: %v", driver, err)
+		case <-time.After(pingInterval):
+		}
+	}
+}
+
+// commandError 返回包含命令标准错误的错误信息
+func commandError(err error) error {
+	if exitErr, ok := err.(*exec.ExitError); ok && len(exitErr.Stderr) > 0 {
+		return errors.WithMessage(err, strings.TrimSpace(string(exitErr.Stderr)))
+	}
+	return err
+}
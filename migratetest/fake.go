@@ -0,0 +1,216 @@
+package migratetest
+
+import (
+	"context"
+	"sync"
+
+	"github.com/pkg/errors"
+
+	"powerlaw.ai/powerlib/migrate"
+)
+
+/*
+测试替身，内存版本存储及按脚本执行并记录调用顺序的处理程序，无需真实数据库即可测试迁移的组装，
+如处理程序的顺序、标签、依赖及失败后的 dirty 状态：
+migrate.New(nil, migrate.WithSchemaStore(migratetest.NewMemoryStore()), migrate.WithExecutors(executor))
+*/
+
+// MemoryStore 内存版本存储，锁在同一进程内互斥
+type MemoryStore struct {
+	mutex   sync.Mutex
+	version int
+	dirty   bool
+	lock    chan struct{}
+}
+
+// NewMemoryStore 创建 version 为 0 的内存版本存储
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{lock: make(chan struct{}, 1)}
+}
+
+func (s *MemoryStore) Init(ctx context.Context) error {
+	return nil
+}
+
+func (s *MemoryStore) GetVersion(ctx context.Context) (int, bool, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.version, s.dirty, nil
+}
+
+func (s *MemoryStore) SetVersion(ctx context.Context, version int) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.version = version
+	return nil
+}
+
+func (s *MemoryStore) SetDirty(ctx context.Context, version int, dirty bool) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.version, s.dirty = version, dirty
+	return nil
+}
+
+// Lock 获取锁，已被持有时阻塞直至释放或 ctx 结束
+func (s *MemoryStore) Lock(ctx context.Context) error {
+	select {
+	case s.lock <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return errors.WithStack(ctx.Err())
+	}
+}
+
+func (s *MemoryStore) Unlock(ctx context.Context) error {
+	select {
+	case <-s.lock:
+	default:
+	}
+	return nil
+}
+
+// Call 处理程序的一次调用
+type Call struct {
+	Index     int
+	Direction string // migrate.DirectionUp 或 migrate.DirectionDown
+}
+
+// FakeHandler 按脚本执行的处理程序
+type FakeHandler struct {
+	index    int
+	name     string
+	err      error // 执行时返回的错误
+	down     bool  // 是否支持回滚
+	downErr  error // 回滚时返回的错误
+	checksum string
+	tags     []string
+}
+
+// NewFakeHandler 创建执行成功且不支持回滚的处理程序
+func NewFakeHandler(index int) FakeHandler {
+	return FakeHandler{index: index}
+}
+
+// WithName 指定处理程序的名称
+func (h FakeHandler) WithName(name string) FakeHandler {
+	h.name = name
+	return h
+}
+
+// WithError 执行时返回 err
+func (h FakeHandler) WithError(err error) FakeHandler {
+	h.err = err
+	return h
+}
+
+// WithDown 支持回滚，回滚时返回 err，err 为空时回滚成功
+func (h FakeHandler) WithDown(err error) FakeHandler {
+	h.down, h.downErr = true, err
+	return h
+}
+
+// WithChecksum 指定校验和
+func (h FakeHandler) WithChecksum(checksum string) FakeHandler {
+	h.checksum = checksum
+	return h
+}
+
+// WithTags 指定标签
+func (h FakeHandler) WithTags(tags ...string) FakeHandler {
+	h.tags = tags
+	return h
+}
+
+// FakeExecutor 记录处理程序调用顺序的执行器
+type FakeExecutor struct {
+	handlers []FakeHandler
+
+	mutex sync.Mutex
+	calls []Call
+}
+
+// NewFakeExecutor 创建执行 handlers 的执行器
+func NewFakeExecutor(handlers ...FakeHandler) *FakeExecutor {
+	return &FakeExecutor{handlers: handlers}
+}
+
+func (e *FakeExecutor) ListHandlers() ([]migrate.Handler, error) {
+	handlers := make([]migrate.Handler, 0, len(e.handlers))
+	for idx := range e.handlers {
+		h := &fakeHandler{FakeHandler: &e.handlers[idx], executor: e}
+		if h.down {
+			handlers = append(handlers, fakeDownHandler{fakeHandler: h})
+			continue
+		}
+		handlers = append(handlers, h)
+	}
+	return handlers, nil
+}
+
+// Calls 返回按调用顺序排列的全部调用，包括失败的调用
+func (e *FakeExecutor) Calls() []Call {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	return append([]Call{}, e.calls...)
+}
+
+// Indexes 返回 direction 方向按调用顺序排列的处理程序索引
+func (e *FakeExecutor) Indexes(direction string) []int {
+	var indexes []int
+	for _, call := range e.Calls() {
+		if call.Direction == direction {
+			indexes = append(indexes, call.Index)
+		}
+	}
+	return indexes
+}
+
+// Reset 清空调用记录
+func (e *FakeExecutor) Reset() {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	e.calls = nil
+}
+
+func (e *FakeExecutor) record(index int, direction string) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	e.calls = append(e.calls, Call{Index: index, Direction: direction})
+}
+
+// fakeHandler 绑定执行器的 FakeHandler
+type fakeHandler struct {
+	*FakeHandler
+	executor *FakeExecutor
+}
+
+func (h *fakeHandler) GetIndex() int {
+	return h.index
+}
+
+func (h *fakeHandler) GetName() string {
+	return h.name
+}
+
+func (h *fakeHandler) GetChecksum() string {
+	return h.checksum
+}
+
+func (h *fakeHandler) GetTags() []string {
+	return h.tags
+}
+
+func (h *fakeHandler) Exec(ctx context.Context) error {
+	h.executor.record(h.index, migrate.DirectionUp)
+	return h.err
+}
+
+type fakeDownHandler struct {
+	*fakeHandler
+}
+
+func (h fakeDownHandler) DownExec(ctx context.Context) error {
+	h.executor.record(h.index, migrate.DirectionDown)
+	return h.downErr
+}
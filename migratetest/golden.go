@@ -0,0 +1,68 @@
+package migratetest
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"powerlaw.ai/powerlib/migrate"
+)
+
+/*
+表结构快照测试，执行迁移后导出表结构并与提交到仓库的快照文件比较，未经审查的表结构变更将导致测试失败；
+确认变更后设置环境变量 MIGRATE_UPDATE_GOLDEN=1 重新运行测试即可更新快照文件
+*/
+
+const updateGoldenEnv = "MIGRATE_UPDATE_GOLDEN"
+
+// AssertGoldenSchema 断言当前表结构与快照文件 golden 一致，设置 MIGRATE_UPDATE_GOLDEN 时写入快照
+func AssertGoldenSchema(t testing.TB, m migrate.Migrate, golden string) {
+	t.Helper()
+	var buf bytes.Buffer
+	err := m.DumpSchema(context.Background(), &buf)
+	if err != nil {
+		t.Fatalf("dump schema: %+v", err)
+	}
+	actual := buf.String()
+	expected, err := os.ReadFile(golden)
+	if os.Getenv(updateGoldenEnv) != "" {
+		err = os.MkdirAll(filepath.Dir(golden), 0o755)
+		if err == nil {
+			err = os.WriteFile(golden, []byte(actual), 0o644)
+		}
+		if err != nil {
+			t.Fatalf("write golden schema: %v", err)
+		}
+		t.Logf("golden schema %s updated", golden)
+		return
+	}
+	if err != nil {
+		t.Fatalf("read golden schema: %v, run with %s=1 to create it", err, updateGoldenEnv)
+	}
+	if line, want, got, ok := firstDiff(string(expected), actual); !ok {
+		t.Fatalf("schema differs from %s at line %d:\n  golden: %s\n  actual: %s\nrun with %s=1 to update it after reviewing the change",
+			golden, line, want, got, updateGoldenEnv)
+	}
+}
+
+// firstDiff 返回第一处不一致的行号及两侧内容，一致时 ok 为 true
+func firstDiff(expected, actual string) (line int, want, got string, ok bool) {
+	wants := strings.Split(strings.ReplaceAll(expected, "\r\n", "\n"), "\n")
+	gots := strings.Split(actual, "\n")
+	for i := 0; i < len(wants) || i < len(gots); i++ {
+		want, got = "<end of file>", "<end of file>"
+		if i < len(wants) {
+			want = wants[i]
+		}
+		if i < len(gots) {
+			got = gots[i]
+		}
+		if want != got {
+			return i + 1, want, got, false
+		}
+	}
+	return 0, "", "", true
+}
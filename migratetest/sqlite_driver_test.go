@@ -0,0 +1,67 @@
+//go:build mattn
+
+package migratetest_test
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"path/filepath"
+	"testing"
+
+	_ "github.com/mattn/go-sqlite3"
+
+	"powerlaw.ai/powerlib/migrate"
+	"powerlaw.ai/powerlib/migrate/concrete"
+)
+
+// TestSQLiteDrivers 在 modernc.org/sqlite 及 mattn/go-sqlite3 上读写 schema 表及历史表，
+// mattn/go-sqlite3 需要 cgo，通过 go test -tags mattn 执行
+func TestSQLiteDrivers(t *testing.T) {
+	for _, driver := range []string{"sqlite", "sqlite3"} {
+		t.Run(driver, func(t *testing.T) {
+			ctx := context.Background()
+			db, err := sql.Open(driver, filepath.Join(t.TempDir(), "migrate.db"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer db.Close()
+			dir := t.TempDir()
+			files := map[string]string{
+				"1_a.up.sql": "CREATE TABLE a (id INTEGER);",
+				"2_b.up.sql": "CREATE TABLE b (id INTEGER);",
+				"3_c.up.sql": "CREATE TABLE a (id INTEGER);",
+			}
+			for name, content := range files {
+				err = os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644)
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+			m := migrate.New(db, migrate.WithDialect(migrate.SQLite), migrate.WithExecutors(concrete.NewSQLExecutor(db, dir)))
+
+			// 3_c 重复创建表失败，version 标记为 dirty
+			err = m.Run(ctx)
+			if err == nil {
+				t.Fatal("run: expected 3_c to fail")
+			}
+			version, dirty, err := m.Version(ctx)
+			if err != nil || version != 3 || !dirty {
+				t.Fatalf("version = %d, dirty = %v, err = %v, expected 3 dirty", version, dirty, err)
+			}
+			err = m.Force(ctx, 2)
+			if err != nil {
+				t.Fatalf("force: %+v", err)
+			}
+			version, dirty, err = m.Version(ctx)
+			if err != nil || version != 2 || dirty {
+				t.Fatalf("version = %d, dirty = %v, err = %v, expected 2 clean", version, dirty, err)
+			}
+			// 读取历史表中的执行记录
+			err = m.VerifyChecksums(ctx)
+			if err != nil {
+				t.Fatalf("verify checksums: %+v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,214 @@
+package migrate
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+/*
+多库迁移，对多个分片或租户库执行同一组处理程序，记录每个库的版本及执行结果并汇总返回；
+默认逐个执行，任一库失败后不再执行后续的库，WithContinueOnError 时执行全部库后返回全部错误
+*/
+
+// Target 多库迁移中的一个目标库
+type Target struct {
+	Name string // 目标库标识，如分片或租户名称，用于报告及错误
+	DB   DBTX
+}
+
+// TargetResult 单个目标库的执行结果
+type TargetResult struct {
+	Name    string  `json:"name"`
+	Version int     `json:"version"`          // 执行后的 version
+	Dirty   bool    `json:"dirty"`            // 执行后是否 dirty
+	Absent  bool    `json:"absent,omitempty"` // 概要表不存在，仅 Versions 返回
+	Skipped bool    `json:"skipped"`          // 其他库失败而未执行
+	Error   string  `json:"error,omitempty"`  // 失败原因
+	Report  *Report `json:"report,omitempty"` // 执行报告，未执行时为空
+}
+
+// MultiReport 多库迁移的汇总结果，Results 与目标库顺序一致
+type MultiReport struct {
+	Results  []TargetResult `json:"results"`
+	Duration time.Duration  `json:"duration"`
+}
+
+// MultiMigrate 多库迁移客户端
+type MultiMigrate struct {
+	targets func(ctx context.Context) ([]Target, error) // 目标库列表
+	factory func(target Target) Migrate                 // 为目标库创建迁移客户端
+
+	concurrency     int  // 同时执行的库数量
+	continueOnError bool // 失败后是否继续执行其他库
+
+	store         *tenantStore // 租户状态表，为空时不记录
+	tenantOptions TableOptions // 租户状态表的建表选项
+}
+
+type MultiOption func(mm *MultiMigrate)
+
+// NewMultiMigrate 创建多库迁移客户端，factory 为每个目标库创建迁移客户端，处理程序需使用 target.DB
+func NewMultiMigrate(targets []Target, factory func(target Target) Migrate, options ...MultiOption) *MultiMigrate {
+	return newMultiMigrate(func(ctx context.Context) ([]Target, error) {
+		return targets, nil
+	}, factory, options...)
+}
+
+func newMultiMigrate(targets func(ctx context.Context) ([]Target, error), factory func(target Target) Migrate, options ...MultiOption) *MultiMigrate {
+	mm := &MultiMigrate{
+		targets:     targets,
+		factory:     factory,
+		concurrency: 1,
+	}
+	for _, option := range options {
+		option(mm)
+	}
+	if mm.store != nil {
+		mm.store.options = mm.tenantOptions
+	}
+	return mm
+}
+
+// WithConcurrency 同时执行 n 个库，默认逐个执行
+func WithConcurrency(n int) MultiOption {
+	return func(mm *MultiMigrate) {
+		mm.concurrency = max(n, 1)
+	}
+}
+
+// WithContinueOnError 任一库失败后继续执行其他库，结束后返回全部错误
+func WithContinueOnError() MultiOption {
+	return func(mm *MultiMigrate) {
+		mm.continueOnError = true
+	}
+}
+
+// Run 对全部目标库执行待执行的处理程序，存在失败的库时返回 MultiError，报告包含全部库的结果
+func (mm *MultiMigrate) Run(ctx context.Context) (*MultiReport, error) {
+	return mm.each(ctx, func(ctx context.Context, client Migrate, result *TargetResult) error {
+		report, err := client.RunWithResult(ctx)
+		result.Report = report
+		return err
+	})
+}
+
+// Versions 只读查询全部目标库的 version 及 dirty，不执行任何处理程序，不创建概要表且不记录租户状态，
+// 概要表不存在时 Absent 为 true
+func (mm *MultiMigrate) Versions(ctx context.Context) (*MultiReport, error) {
+	return mm.each(ctx, nil)
+}
+
+// versionPeeker 可只读查询 version 的迁移客户端
+type versionPeeker interface {
+	peekVersion(ctx context.Context) (version int, dirty bool, absent bool, err error)
+}
+
+// each 按并发数对每个目标库执行 f，并记录执行后的 version；f 为空时只读查询 version
+func (mm *MultiMigrate) each(ctx context.Context, f func(ctx context.Context, client Migrate, result *TargetResult) error) (*MultiReport, error) {
+	start := time.Now()
+	targets, err := mm.targets(ctx)
+	if err != nil {
+		return nil, err
+	}
+	record := mm.store != nil && f != nil
+	if record {
+		err = mm.store.init(ctx)
+		if err != nil {
+			return nil, err
+		}
+	}
+	report := &MultiReport{Results: make([]TargetResult, len(targets))}
+	var (
+		wg     sync.WaitGroup
+		mutex  sync.Mutex
+		failed bool
+		errs   = make([]error, len(targets))
+	)
+	semaphore := make(chan struct{}, mm.concurrency)
+	for i, target := range targets {
+		report.Results[i].Name = target.Name
+		semaphore <- struct{}{}
+		mutex.Lock()
+		stop := failed && !mm.continueOnError
+		mutex.Unlock()
+		if stop || ctx.Err() != nil {
+			<-semaphore
+			report.Results[i].Skipped = true
+			continue
+		}
+		wg.Add(1)
+		go func(i int, target Target) {
+			defer func() {
+				<-semaphore
+				wg.Done()
+			}()
+			result := &report.Results[i]
+			client := mm.factory(target)
+			var err error
+			if f == nil {
+				err = peek(ctx, client, result)
+			} else {
+				err = mm.apply(ctx, client, result, f, record)
+			}
+			if err != nil {
+				result.Error = err.Error()
+				errs[i] = &TargetError{Target: target.Name, Err: err}
+				mutex.Lock()
+				failed = true
+				mutex.Unlock()
+			}
+		}(i, target)
+	}
+	wg.Wait()
+	report.Duration = time.Since(start)
+	var multiErr MultiError
+	for _, err := range errs {
+		if err != nil {
+			multiErr.Errors = append(multiErr.Errors, err)
+		}
+	}
+	if len(multiErr.Errors) > 0 {
+		return report, errors.WithStack(&multiErr)
+	}
+	if err := ctx.Err(); err != nil {
+		return report, errors.WithStack(err)
+	}
+	return report, nil
+}
+
+// apply 对目标库执行 f 并记录执行后的 version，record 为 true 时写入租户状态表
+func (mm *MultiMigrate) apply(ctx context.Context, client Migrate, result *TargetResult, f func(ctx context.Context, client Migrate, result *TargetResult) error, record bool) error {
+	err := f(ctx, client, result)
+	// 失败后仍记录 version，便于定位 dirty 的库
+	version, dirty, versionErr := client.Version(context.WithoutCancel(ctx))
+	if err == nil {
+		err = versionErr
+	}
+	result.Version, result.Dirty = version, dirty
+	if err != nil {
+		result.Error = err.Error()
+	}
+	if record && versionErr == nil {
+		recordErr := mm.store.record(context.WithoutCancel(ctx), *result)
+		if err == nil {
+			err = recordErr
+		}
+	}
+	return err
+}
+
+// peek 只读查询目标库的 version，客户端不支持只读查询时使用 Version
+func peek(ctx context.Context, client Migrate, result *TargetResult) error {
+	peeker, ok := client.(versionPeeker)
+	if !ok {
+		version, dirty, err := client.Version(ctx)
+		result.Version, result.Dirty = version, dirty
+		return err
+	}
+	version, dirty, absent, err := peeker.peekVersion(ctx)
+	result.Version, result.Dirty, result.Absent = version, dirty, absent
+	return err
+}
@@ -0,0 +1,212 @@
+package notifier
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"powerlaw.ai/powerlib/migrate"
+)
+
+/*
+notifier 通过事件回调在执行或回滚结束时向 webhook 推送结果摘要，支持通用 json 及 Slack Incoming Webhook 格式；
+推送失败仅记录日志，不影响迁移结果
+*/
+
+const defaultTimeout = 10 * time.Second
+
+var ErrUnexpectedStatus = errors.New("unexpected webhook response status")
+
+// Format 推送内容格式
+type Format int
+
+const (
+	FormatJSON  Format = iota // 通用 json，内容为 Summary
+	FormatSlack               // Slack Incoming Webhook，内容为 {"text": "..."}
+)
+
+// Summary 一次执行或回滚的结果摘要
+type Summary struct {
+	Name         string        `json:"name,omitempty"`          // 应用名称，WithName 配置
+	Direction    string        `json:"direction"`               // migrate.DirectionUp 或 migrate.DirectionDown
+	Success      bool          `json:"success"`                 // 是否执行成功
+	Handlers     []int         `json:"handlers"`                // 执行成功的处理程序索引
+	Duration     time.Duration `json:"duration"`                // 总耗时
+	Error        string        `json:"error,omitempty"`         // 失败原因
+	FailedIndex  int           `json:"failed_index,omitempty"`  // 执行失败的处理程序索引
+	DirtyVersion int           `json:"dirty_version,omitempty"` // 执行失败后标记为 dirty 的 version
+	RolledBack   bool          `json:"rolled_back,omitempty"`   // 失败的处理程序是否已自动回滚，为 true 时没有 dirty 的 version
+}
+
+// Notifier webhook 通知
+type Notifier struct {
+	url         string
+	format      Format
+	name        string
+	onlyFailure bool
+	client      *http.Client
+	logger      migrate.Logger
+
+	mu     sync.Mutex
+	failed *migrate.HandlerEvent // 本次执行中失败的处理程序，结束时清空
+}
+
+type Option func(n *Notifier)
+
+// New 创建向 url 推送的通知
+func New(url string, options ...Option) *Notifier {
+	n := &Notifier{
+		url:    url,
+		client: &http.Client{Timeout: defaultTimeout},
+	}
+	for _, option := range options {
+		option(n)
+	}
+	return n
+}
+
+// WithSlack 使用 Slack Incoming Webhook 格式推送
+func WithSlack() Option {
+	return func(n *Notifier) {
+		n.format = FormatSlack
+	}
+}
+
+// WithName 配置应用名称，用于区分推送到同一 webhook 的多个服务
+func WithName(name string) Option {
+	return func(n *Notifier) {
+		n.name = name
+	}
+}
+
+// WithOnlyFailure 仅在执行失败时推送
+func WithOnlyFailure() Option {
+	return func(n *Notifier) {
+		n.onlyFailure = true
+	}
+}
+
+// WithHTTPClient 配置 http 客户端，默认超时时间为 10 秒
+func WithHTTPClient(client *http.Client) Option {
+	return func(n *Notifier) {
+		n.client = client
+	}
+}
+
+// WithLogger 配置日志，用于记录推送失败
+func WithLogger(logger migrate.Logger) Option {
+	return func(n *Notifier) {
+		n.logger = logger
+	}
+}
+
+// Hooks 返回推送通知的事件回调，配合 migrate.WithHooks 使用
+func (n *Notifier) Hooks() migrate.Hooks {
+	return migrate.Hooks{
+		OnError: func(ctx context.Context, event migrate.HandlerEvent, err error) {
+			n.mu.Lock()
+			defer n.mu.Unlock()
+			// 跳过的可选处理程序不覆盖中断执行的失败，并发批次中仅通知首个中断执行的失败
+			if n.failed != nil && (event.Skipped || !n.failed.Skipped) {
+				return
+			}
+			n.failed = &event
+		},
+		OnComplete: func(ctx context.Context, event migrate.CompleteEvent) {
+			n.mu.Lock()
+			failed := n.failed
+			n.failed = nil
+			n.mu.Unlock()
+			if n.onlyFailure && event.Err == nil {
+				return
+			}
+			summary := Summary{
+				Name:      n.name,
+				Direction: event.Direction,
+				Success:   event.Err == nil,
+				Handlers:  event.Handlers,
+				Duration:  event.Duration,
+			}
+			if event.Err != nil {
+				summary.Error = event.Err.Error()
+			}
+			// 跳过的可选处理程序失败不中断执行，不计入失败的处理程序
+			if failed != nil && failed.Direction != migrate.DirectionRepeat && !failed.Skipped {
+				summary.FailedIndex = failed.Index
+				// 已自动回滚的处理程序未标记 dirty
+				if failed.RolledBack {
+					summary.RolledBack = true
+				} else {
+					summary.DirtyVersion = failed.Version
+				}
+			}
+			// 迁移已结束，推送不受 ctx 取消影响
+			err := n.Notify(context.WithoutCancel(ctx), summary)
+			if err != nil && n.logger != nil {
+				n.logger.Error("notify failed", "url", n.url, "error", err)
+			}
+		},
+	}
+}
+
+// Notify 推送结果摘要
+func (n *Notifier) Notify(ctx context.Context, summary Summary) error {
+	var payload interface{} = summary
+	if n.format == FormatSlack {
+		payload = map[string]string{"text": summary.Text()}
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.url, bytes.NewReader(body))
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return errors.Wrapf(ErrUnexpectedStatus, "status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// Text 返回可读的摘要文本
+func (s Summary) Text() string {
+	var b strings.Builder
+	if s.Name != "" {
+		b.WriteString("[" + s.Name + "] ")
+	}
+	if s.Success {
+		fmt.Fprintf(&b, "migrate %s succeeded in %s", s.Direction, s.Duration)
+	} else {
+		fmt.Fprintf(&b, "migrate %s failed in %s", s.Direction, s.Duration)
+	}
+	if len(s.Handlers) > 0 {
+		indexes := make([]string, 0, len(s.Handlers))
+		for _, index := range s.Handlers {
+			indexes = append(indexes, fmt.Sprint(index))
+		}
+		fmt.Fprintf(&b, ", applied %s", strings.Join(indexes, ", "))
+	}
+	if s.DirtyVersion > 0 {
+		fmt.Fprintf(&b, ", handler %d failed and version %d is dirty", s.FailedIndex, s.DirtyVersion)
+	} else if s.RolledBack {
+		fmt.Fprintf(&b, ", handler %d failed and was rolled back", s.FailedIndex)
+	}
+	if s.Error != "" {
+		b.WriteString(": " + s.Error)
+	}
+	return b.String()
+}
@@ -0,0 +1,64 @@
+package migrate
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+)
+
+/*
+按索引执行单个处理程序，用于生产环境的精确干预
+*/
+
+const (
+	ErrHandlerNotPendingFormat  = "handler %d is not pending"
+	ErrHandlerNotAppliedFormat  = "handler %d is not applied"
+	ErrPredecessorPendingFormat = "handler %d is pending before handler %d"
+)
+
+func (m *migrate) RunOne(ctx context.Context, index int) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return m.operate(ctx, "migrate.RunOne", func(ctx context.Context) error {
+		handlers, schema, err := m.prepare(ctx)
+		if err != nil {
+			return err
+		}
+		_, pending, err := m.split(ctx, handlers, schema.version)
+		if err != nil {
+			return err
+		}
+		// 之前的处理程序均需已执行
+		pos := searchPending(pending, index)
+		if pos == 0 || pending[pos-1].GetIndex() != index {
+			return errors.Errorf(ErrHandlerNotPendingFormat, index)
+		}
+		if pos > 1 {
+			return errors.Errorf(ErrPredecessorPendingFormat, pending[0].GetIndex(), index)
+		}
+		_, err = m.up(ctx, pending[:1], schema.version)
+		return err
+	})
+}
+
+func (m *migrate) Redo(ctx context.Context, index int) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return m.operate(ctx, "migrate.Redo", func(ctx context.Context) error {
+		handlers, schema, err := m.prepare(ctx)
+		if err != nil {
+			return err
+		}
+		applied, _, err := m.split(ctx, handlers, schema.version)
+		if err != nil {
+			return err
+		}
+		pos := searchPending(applied, index)
+		if pos == 0 || applied[pos-1].GetIndex() != index {
+			return errors.Errorf(ErrHandlerNotAppliedFormat, index)
+		}
+		// 重新执行不改变 version
+		_, err = m.up(ctx, applied[pos-1:pos], schema.version)
+		return err
+	})
+}
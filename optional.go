@@ -0,0 +1,59 @@
+package migrate
+
+import (
+	"context"
+)
+
+/*
+可选处理程序，执行失败时记录日志及失败历史，version 照常推进且不标记 dirty，继续执行后续处理程序，
+用于不应阻塞部署的清理类迁移；单事务模式及调用方传入的事务中事务已中止，可选处理程序失败时仍中断执行
+*/
+
+// OptionalHandler 可选处理程序，IsOptional 返回 true 时执行失败不中断执行
+type OptionalHandler interface {
+	Handler
+	IsOptional() bool
+}
+
+// isOptional 判断是否为可选处理程序
+func isOptional(handler Handler) bool {
+	h, ok := handler.(OptionalHandler)
+	return ok && h.IsOptional()
+}
+
+// skippedError 可选处理程序执行失败，已记录失败历史，调用方跳过该处理程序继续执行
+type skippedError struct {
+	error
+}
+
+func (e *skippedError) Unwrap() error {
+	return e.error
+}
+
+// isSkipped 判断是否为可选处理程序的失败
+func isSkipped(err error) bool {
+	_, ok := err.(*skippedError)
+	return ok
+}
+
+// canSkip 判断失败的处理程序是否可跳过，仅执行方向且不在事务中时可跳过
+func (m *migrate) canSkip(handler Handler, direction string) bool {
+	return direction == DirectionUp && m.tx == nil && !m.singleTx && isOptional(handler)
+}
+
+// skip 记录可选处理程序的失败日志及历史，将 version 更新为 version，返回 *skippedError
+func (m *migrate) skip(ctx context.Context, h history, version int, cause error) error {
+	ctx = context.WithoutCancel(ctx)
+	m.logger.Warn("optional handler failed", "index", h.version, "name", h.name, "direction", h.direction, "duration", h.duration, "error", cause)
+	m.onError(ctx, HandlerEvent{Index: h.version, Name: h.name, Direction: h.direction, Version: version, Duration: h.duration,
+		Skipped: true}, cause)
+	err := m.setVersion(ctx, nil, version)
+	if err != nil {
+		return err
+	}
+	err = m.recordHistory(ctx, h)
+	if err != nil {
+		return err
+	}
+	return &skippedError{cause}
+}
@@ -0,0 +1,35 @@
+package migrate
+
+import "context"
+
+/*
+乱序执行，多分支并行开发时，合并较晚的低索引处理程序在高索引执行后仍可执行；
+依赖历史表记录每个已执行的处理程序，历史表为空时视为历史表创建前均已执行
+*/
+
+// split 将处理程序划分为已执行及待执行，开启乱序执行时，索引不大于 version 但未执行的处理程序归入待执行
+// 标签不匹配的处理程序不属于任何一方
+func (m *migrate) split(ctx context.Context, handlers []Handler, version int) ([]Handler, []Handler, error) {
+	handlers = m.filterTags(handlers)
+	pos := searchPending(handlers, version)
+	if !m.allowOutOfOrder || pos == 0 {
+		return handlers[:pos], handlers[pos:], nil
+	}
+	histories, err := m.loadHistory(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	records := lastApplied(histories)
+	if len(records) == 0 {
+		return handlers[:pos], handlers[pos:], nil
+	}
+	var applied, pending []Handler
+	for _, handler := range handlers[:pos] {
+		if _, ok := records[handler.GetIndex()]; ok {
+			applied = append(applied, handler)
+		} else {
+			pending = append(pending, handler)
+		}
+	}
+	return applied, append(pending, handlers[pos:]...), nil
+}
@@ -0,0 +1,178 @@
+package migrate
+
+import (
+	"context"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+var ErrParallelSession = errors.New("parallelism requires a connection pool, not a transaction or a single connection")
+
+// batchVersion 并发批次中的处理程序不更新 version，由 executeBatch 统一更新
+const batchVersion = -1
+
+/*
+并发执行，索引连续且互不依赖的处理程序分为一批并发执行，各处理程序不更新 version，避免并发争用 schema 表的行锁，
+执行成功时在其事务中记录执行成功的历史，全部结束后按索引顺序触发回调，整批成功后将 version 更新为批次的最大索引；
+批次中有处理程序失败时将 version 更新为失败前连续成功的最大索引，再以失败的最小索引标记 dirty 或自动回滚；
+同批次中索引更大的处理程序可能已提交，中断或修复后重新执行时按历史表跳过已提交的处理程序，
+因此以 Force 降低 version 后需先回滚处理程序才能再次执行；
+处理程序执行期间 Hooks 的回调串行调用，Logger 需可并发调用
+*/
+
+// WithParallelism 最多并发执行 n 个互不依赖的处理程序，依赖通过 DependentHandler 声明，
+// 未声明依赖的处理程序依赖之前所有处理程序；需使用连接池，与单事务模式、FromTx 及 *sql.Conn 同时使用时返回 ErrParallelSession
+func WithParallelism(n int) Option {
+	return func(m *migrate) {
+		m.parallelism = n
+	}
+}
+
+// batches 将已排序的待执行处理程序按顺序分批，处理程序依赖当前批次中的任一处理程序或未声明依赖时开始新的批次
+func (m *migrate) batches(handlers []Handler) [][]Handler {
+	var batches [][]Handler
+	inBatch := make(map[int]bool)
+	for _, handler := range handlers {
+		last := len(batches) - 1
+		if last >= 0 && m.parallelism > 1 && len(batches[last]) < m.parallelism &&
+			independent(handler, inBatch) {
+			batches[last] = append(batches[last], handler)
+			inBatch[handler.GetIndex()] = true
+			continue
+		}
+		batches = append(batches, []Handler{handler})
+		inBatch = map[int]bool{handler.GetIndex(): true}
+	}
+	return batches
+}
+
+// checkParallel 检查并发执行是否可用，并发的处理程序不能共用事务或固定的连接
+func (m *migrate) checkParallel() error {
+	if m.parallelism <= 1 {
+		return nil
+	}
+	if _, ok := m.db.(Conner); !ok || m.singleTx || m.external {
+		return ErrParallelSession
+	}
+	return nil
+}
+
+// independent 判断处理程序是否声明了依赖且不依赖 inBatch 中的处理程序
+func independent(handler Handler, inBatch map[int]bool) bool {
+	dependencies, ok := dependenciesOf(handler)
+	if !ok {
+		return false
+	}
+	for _, dependency := range dependencies {
+		if inBatch[dependency] {
+			return false
+		}
+	}
+	return true
+}
+
+// executeBatch 并发执行一批处理程序，全部结束后按索引顺序处理结果：version 更新为首个失败前连续成功的最大索引，
+// 首个失败的处理程序以其索引标记 dirty，返回执行成功的处理程序
+func (m *migrate) executeBatch(ctx context.Context, batch []Handler, version int) ([]HandlerResult, error) {
+	for _, handler := range batch {
+		err := m.waitWindow(ctx, handler)
+		if err != nil {
+			return nil, err
+		}
+	}
+	m.logger.Info("handler batch start", "from", batch[0].GetIndex(), "to", batch[len(batch)-1].GetIndex(), "size", len(batch))
+	histories := make([]history, len(batch))
+	errs := make([]error, len(batch))
+	var wg sync.WaitGroup
+	for i, handler := range batch {
+		wg.Add(1)
+		go func(i int, handler Handler) {
+			defer wg.Done()
+			histories[i], errs[i] = m.run(ctx, handler, DirectionUp, version, batchVersion)
+		}(i, handler)
+	}
+	wg.Wait()
+	// 处理程序已执行完成，记录结果及更新 version 不受 ctx 取消影响
+	ctx = context.WithoutCancel(ctx)
+	var results []HandlerResult
+	var failed error
+	// 有处理程序失败后，其后执行成功的处理程序不计入 version，事件中为失败后记录的 version
+	var stored int
+	for i, handler := range batch {
+		index := handler.GetIndex()
+		if errs[i] == nil {
+			eventVersion := max(version, index)
+			if failed != nil {
+				eventVersion = stored
+			}
+			// 执行成功的历史已在处理程序的事务中记录
+			m.notifySuccess(ctx, HandlerEvent{Index: index, Name: histories[i].name, Direction: DirectionUp, Version: eventVersion}, histories[i])
+			results = append(results, HandlerResult{Index: index, Name: histories[i].name, Duration: histories[i].duration})
+			if failed == nil {
+				version = eventVersion
+			}
+			continue
+		}
+		// 已有处理程序失败时，version 已确定，其后失败的处理程序支持自动回滚且未计入 version 时回滚，否则仅记录日志及失败历史
+		if failed != nil {
+			if m.canRollback(handler, DirectionUp) && index > stored {
+				err := m.rollbackAfter(ctx, handler, histories[i], stored, handlerError(handler, DirectionUp, errs[i]))
+				if err != nil {
+					return results, err
+				}
+				continue
+			}
+			m.logger.Error("handler failed", "index", index, "name", histories[i].name, "direction", DirectionUp, "duration", histories[i].duration, "error", errs[i])
+			err := m.recordHistory(ctx, histories[i])
+			if err != nil {
+				return results, err
+			}
+			continue
+		}
+		// 失败前连续成功的处理程序计入 version，再以失败的处理程序的索引处理失败
+		err := m.setVersion(ctx, nil, version)
+		if err != nil {
+			return results, err
+		}
+		err = m.handleFailure(ctx, handler, histories[i], max(version, index), errs[i])
+		if isSkipped(err) {
+			version = max(version, index)
+			continue
+		}
+		failed, stored = err, max(version, index)
+		if rolledBack(err) {
+			stored = version
+		}
+	}
+	if failed != nil {
+		return results, failed
+	}
+	return results, m.setVersion(ctx, nil, version)
+}
+
+// skipCommitted 并发执行时，从待执行的处理程序中移除历史表中已执行成功的处理程序，返回其中的最大索引；
+// 并发批次中的处理程序提交时不更新 version，中断后这些处理程序仅记录在历史表中
+func (m *migrate) skipCommitted(ctx context.Context, handlers []Handler) ([]Handler, int, error) {
+	if m.parallelism <= 1 || len(handlers) == 0 {
+		return handlers, 0, nil
+	}
+	histories, err := m.loadHistory(ctx)
+	if err != nil {
+		return nil, 0, err
+	}
+	records := lastApplied(histories)
+	var committed int
+	pending := make([]Handler, 0, len(handlers))
+	for _, handler := range handlers {
+		// 仅声明了依赖的处理程序可能在并发批次中执行
+		_, batched := dependenciesOf(handler)
+		if _, ok := records[handler.GetIndex()]; ok && batched {
+			m.logger.Info("handler already committed", "index", handler.GetIndex(), "name", nameOf(handler))
+			committed = max(committed, handler.GetIndex())
+			continue
+		}
+		pending = append(pending, handler)
+	}
+	return pending, committed, nil
+}
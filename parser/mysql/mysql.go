@@ -0,0 +1,33 @@
+package mysql
+
+import (
+	"sync"
+
+	"github.com/pingcap/tidb/pkg/parser"
+	_ "github.com/pingcap/tidb/pkg/parser/test_driver"
+	"github.com/pkg/errors"
+
+	"powerlaw.ai/powerlib/migrate/concrete"
+)
+
+/*
+MySQL 语法解析器，基于 TiDB 解析器，不连接数据库；
+TiDB 特有的语法同样可以通过解析
+*/
+
+type mysqlParser struct {
+	sync.Mutex // TiDB 解析器不支持并发
+	parser     *parser.Parser
+}
+
+// New 创建 MySQL 语法解析器，用于 concrete.WithParser
+func New() concrete.SQLParser {
+	return &mysqlParser{parser: parser.New()}
+}
+
+func (p *mysqlParser) Parse(query string) error {
+	p.Lock()
+	defer p.Unlock()
+	_, _, err := p.parser.Parse(query, "", "")
+	return errors.WithStack(err)
+}
@@ -0,0 +1,26 @@
+package pgxnative
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/pkg/errors"
+)
+
+/*
+COPY 处理程序，以 COPY FROM STDIN 批量写入数据，用于回填大量数据，速度远高于逐行 INSERT；
+数据源在事务中创建，可先查询已有数据再生成待写入的行
+*/
+
+// NewCopyHandler 创建以 COPY 向 table 的 columns 写入 source 返回的数据的处理程序，table 如 pgx.Identifier{"public", "users"}
+func NewCopyHandler(index int, name string, table pgx.Identifier, columns []string,
+	source func(ctx context.Context, tx pgx.Tx) (pgx.CopyFromSource, error)) Handler {
+	return NewHandler(index, name, func(ctx context.Context, tx pgx.Tx) error {
+		rows, err := source(ctx, tx)
+		if err != nil {
+			return err
+		}
+		_, err = tx.CopyFrom(ctx, table, columns, rows)
+		return errors.Wrapf(err, "copy into %s", table.Sanitize())
+	})
+}
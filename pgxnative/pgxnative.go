@@ -0,0 +1,222 @@
+package pgxnative
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/fs"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/pkg/errors"
+
+	"powerlaw.ai/powerlib/migrate"
+	"powerlaw.ai/powerlib/migrate/concrete"
+)
+
+/*
+基于 pgx 的 Postgres 执行器，直接使用 pgx.Conn 或 pgxpool.Pool，不经过 database/sql，版本记录及迁移锁见 NewStore；
+sql 文件在事务中以简单协议整体执行，声明 -- +migrate NoTransaction 的文件在事务外逐条执行，用于 CREATE INDEX CONCURRENTLY 等语句
+*/
+
+// filePattern sql 文件名，如 0001_users.sql、0001_users.up.sql 及 0001_users.down.sql
+var filePattern = regexp.MustCompile(`^(\d+)_(.+?)(\.up|\.down)?\.sql$`)
+
+// noTxPattern 在事务外执行的指令，与 sql 执行器的指令相同
+var noTxPattern = regexp.MustCompile(`(?m)^\s*-- \+migrate NoTransaction\s*$`)
+
+// DB pgx 连接，*pgx.Conn、*pgxpool.Pool 及 pgx.Tx 均可使用
+type DB interface {
+	Exec(ctx context.Context, sql string, arguments ...any) (pgconn.CommandTag, error)
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+	Begin(ctx context.Context) (pgx.Tx, error)
+}
+
+// Handler pgx 处理程序，up 及 down 在同一事务中执行
+type Handler struct {
+	index int
+	name  string
+	up    func(ctx context.Context, tx pgx.Tx) error
+	down  func(ctx context.Context, tx pgx.Tx) error // 回滚函数，为空时不支持回滚
+}
+
+// NewHandler 创建在事务中执行 up 的处理程序，用于以 pgx 编写的数据迁移
+func NewHandler(index int, name string, up func(ctx context.Context, tx pgx.Tx) error) Handler {
+	return Handler{index: index, name: name, up: up}
+}
+
+// WithDown 设置回滚函数
+func (h Handler) WithDown(down func(ctx context.Context, tx pgx.Tx) error) Handler {
+	h.down = down
+	return h
+}
+
+// executor pgx 执行器
+type executor struct {
+	db       DB
+	handlers []Handler
+	fsys     fs.FS
+}
+
+// NewExecutor 创建执行 handlers 的执行器
+func NewExecutor(db DB, handlers ...Handler) migrate.Executor {
+	return &executor{db: db, handlers: handlers}
+}
+
+// NewFileExecutor 创建读取 fsys 根目录 sql 文件的执行器
+func NewFileExecutor(db DB, fsys fs.FS) migrate.Executor {
+	return &executor{db: db, fsys: fsys}
+}
+
+func (e *executor) ListHandlers() ([]migrate.Handler, error) {
+	if e.fsys != nil {
+		return readFiles(e.db, e.fsys)
+	}
+	var result []migrate.Handler
+	for _, h := range e.handlers {
+		base := &handler{db: e.db, index: h.index, name: h.name, up: inTx(h.up)}
+		if h.down != nil {
+			result = append(result, &downHandler{handler: base, down: inTx(h.down)})
+			continue
+		}
+		result = append(result, base)
+	}
+	return result, nil
+}
+
+// readFiles 读取 sql 文件，同一索引的 up 及 down 文件合并为一个处理程序
+func readFiles(db DB, fsys fs.FS) ([]migrate.Handler, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	byIndex := make(map[int]*handler)
+	downs := make(map[int][2]string) // 回滚文件名及内容
+	for _, entry := range entries {
+		match := filePattern.FindStringSubmatch(entry.Name())
+		if entry.IsDir() || match == nil {
+			continue
+		}
+		index, err := strconv.Atoi(match[1])
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		content, err := fs.ReadFile(fsys, entry.Name())
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		h, ok := byIndex[index]
+		if !ok {
+			h = &handler{db: db, index: index, name: match[2]}
+			byIndex[index] = h
+		}
+		if match[3] == ".down" {
+			downs[index] = [2]string{entry.Name(), string(content)}
+			continue
+		}
+		h.query = string(content)
+		h.up = fileFunc(entry.Name(), h.query)
+	}
+	indexes := make([]int, 0, len(byIndex))
+	for index := range byIndex {
+		indexes = append(indexes, index)
+	}
+	sort.Ints(indexes)
+	handlers := make([]migrate.Handler, 0, len(indexes))
+	for _, index := range indexes {
+		h := byIndex[index]
+		if h.up == nil {
+			return nil, errors.Errorf("%d_%s: missing up file", index, h.name)
+		}
+		if down, ok := downs[index]; ok {
+			handlers = append(handlers, &downHandler{handler: h, down: fileFunc(down[0], down[1]), downQuery: down[1]})
+			continue
+		}
+		handlers = append(handlers, h)
+	}
+	return handlers, nil
+}
+
+// inTx 将事务函数包装为开启事务并执行的函数
+func inTx(f func(ctx context.Context, tx pgx.Tx) error) func(ctx context.Context, db DB) error {
+	return func(ctx context.Context, db DB) error {
+		return pgx.BeginFunc(ctx, db, func(tx pgx.Tx) error {
+			return f(ctx, tx)
+		})
+	}
+}
+
+// fileFunc 返回执行 sql 文件的函数，默认在事务中整体执行，NoTransaction 时在事务外逐条执行
+func fileFunc(file, content string) func(ctx context.Context, db DB) error {
+	if !noTxPattern.MatchString(content) {
+		return inTx(func(ctx context.Context, tx pgx.Tx) error {
+			_, err := tx.Exec(ctx, content)
+			return errors.Wrap(err, file)
+		})
+	}
+	return func(ctx context.Context, db DB) error {
+		n := 0
+		return concrete.StreamStatements(strings.NewReader(content), func(query string, line int) error {
+			n++
+			_, err := db.Exec(ctx, query)
+			if err != nil {
+				return errors.Wrapf(err, "%s: statement %d at line %d", file, n, line)
+			}
+			return nil
+		})
+	}
+}
+
+// handler 绑定连接的处理程序
+type handler struct {
+	db    DB
+	index int
+	name  string
+	query string // sql 文件内容，Go 处理程序为空
+	up    func(ctx context.Context, db DB) error
+}
+
+func (h *handler) GetIndex() int {
+	return h.index
+}
+
+func (h *handler) GetName() string {
+	return h.name
+}
+
+func (h *handler) Exec(ctx context.Context) error {
+	return h.up(ctx, h.db)
+}
+
+// GetQuery 返回 sql 文件内容，用于 dry-run
+func (h *handler) GetQuery() string {
+	return h.query
+}
+
+// GetChecksum 返回 sql 文件内容的 sha256，Go 处理程序不校验
+func (h *handler) GetChecksum() string {
+	if h.query == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(h.query))
+	return hex.EncodeToString(sum[:])
+}
+
+type downHandler struct {
+	*handler
+	down      func(ctx context.Context, db DB) error
+	downQuery string
+}
+
+func (h *downHandler) DownExec(ctx context.Context) error {
+	return h.down(ctx, h.db)
+}
+
+func (h *downHandler) GetDownQuery() string {
+	return h.downQuery
+}
@@ -0,0 +1,144 @@
+package pgxnative
+
+import (
+	"context"
+	"fmt"
+	"hash/crc32"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/pkg/errors"
+
+	"powerlaw.ai/powerlib/migrate"
+)
+
+/*
+版本存储，version 及 dirty 记录于概要表，表结构与 migrate.Postgres 方言相同，可与 database/sql 方式互相切换；
+迁移锁为 pg_advisory_lock，锁名称与 migrate.WithAdvisoryLock 相同，两种方式同时执行时也互斥
+*/
+
+const defaultTable = "schema_migrations"
+
+// store 基于概要表的版本存储
+type store struct {
+	db    DB
+	table string // 概要表名，可包含 schema
+
+	release func() // 释放持有咨询锁的连接，未持有锁时为空
+	session DB     // 持有咨询锁的连接
+}
+
+type StoreOption func(s *store)
+
+// NewStore 创建记录于概要表的版本存储，配合 migrate.WithSchemaStore 使用
+func NewStore(db DB, options ...StoreOption) migrate.SchemaStore {
+	s := &store{db: db, table: defaultTable}
+	for _, option := range options {
+		option(s)
+	}
+	return s
+}
+
+// WithTable 指定概要表名，如 ops.schema_migrations，默认为 schema_migrations
+func WithTable(table string) StoreOption {
+	return func(s *store) {
+		s.table = table
+	}
+}
+
+// tableName 返回引用后的表名
+func (s *store) tableName() string {
+	return pgx.Identifier(strings.Split(s.table, ".")).Sanitize()
+}
+
+func (s *store) Init(ctx context.Context) error {
+	_, err := s.db.Exec(ctx, fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (version integer NOT NULL DEFAULT 0, dirty boolean NOT NULL DEFAULT true)", s.tableName()))
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	var n int
+	err = s.db.QueryRow(ctx, fmt.Sprintf("SELECT COUNT(*) FROM %s", s.tableName())).Scan(&n)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	switch {
+	case n == 0:
+		_, err = s.db.Exec(ctx, fmt.Sprintf("INSERT INTO %s (version, dirty) VALUES (0, false)", s.tableName()))
+		return errors.WithStack(err)
+	case n > 1:
+		return errors.WithStack(&migrate.SchemaRowsError{Table: s.table, Rows: n})
+	}
+	return nil
+}
+
+func (s *store) GetVersion(ctx context.Context) (int, bool, error) {
+	rows, err := s.db.Query(ctx, fmt.Sprintf("SELECT version, dirty FROM %s", s.tableName()))
+	if err != nil {
+		return 0, false, errors.WithStack(err)
+	}
+	defer rows.Close()
+	var version, n int
+	var dirty bool
+	for rows.Next() {
+		n++
+		err = rows.Scan(&version, &dirty)
+		if err != nil {
+			return 0, false, errors.WithStack(err)
+		}
+	}
+	if err = rows.Err(); err != nil {
+		return 0, false, errors.WithStack(err)
+	}
+	if n > 1 {
+		return 0, false, errors.WithStack(&migrate.SchemaRowsError{Table: s.table, Rows: n})
+	}
+	return version, dirty, nil
+}
+
+func (s *store) SetVersion(ctx context.Context, version int) error {
+	_, err := s.db.Exec(ctx, fmt.Sprintf("UPDATE %s SET version = $1", s.tableName()), version)
+	return errors.WithStack(err)
+}
+
+func (s *store) SetDirty(ctx context.Context, version int, dirty bool) error {
+	_, err := s.db.Exec(ctx, fmt.Sprintf("UPDATE %s SET version = $1, dirty = $2", s.tableName()), version, dirty)
+	return errors.WithStack(err)
+}
+
+// Lock 获取咨询锁，连接池中获取独占连接并持有至 Unlock
+func (s *store) Lock(ctx context.Context) error {
+	session, release := s.db, func() {}
+	if pool, ok := s.db.(*pgxpool.Pool); ok {
+		conn, err := pool.Acquire(ctx)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		session, release = conn, conn.Release
+	}
+	_, err := session.Exec(ctx, "SELECT pg_advisory_lock($1)", s.lockKey())
+	if err != nil {
+		release()
+		return errors.WithStack(err)
+	}
+	s.session, s.release = session, release
+	return nil
+}
+
+// Unlock 释放咨询锁并归还连接
+func (s *store) Unlock(ctx context.Context) error {
+	if s.session == nil {
+		return nil
+	}
+	defer func() {
+		s.release()
+		s.session, s.release = nil, nil
+	}()
+	_, err := s.session.Exec(ctx, "SELECT pg_advisory_unlock($1)", s.lockKey())
+	return errors.WithStack(err)
+}
+
+// lockKey 由概要表名生成咨询锁名称，与 migrate.Postgres 方言相同
+func (s *store) lockKey() int64 {
+	return int64(crc32.ChecksumIEEE([]byte(s.table)))
+}
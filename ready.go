@@ -0,0 +1,49 @@
+package migrate
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/pkg/errors"
+)
+
+/*
+就绪检查，比较概要表 version 与程序中处理程序的最大索引，用于 Kubernetes 就绪探针，避免在未迁移的表结构上提供服务；
+version 高于最大索引时视为就绪，滚动发布期间旧版本实例可继续服务
+*/
+
+func (m *migrate) Ready(ctx context.Context) error {
+	// 不加锁，迁移执行期间探针仍可返回
+	handlers, err := m.initHandlers()
+	if err != nil {
+		return err
+	}
+	latest := 0
+	for _, handler := range m.filterTags(handlers) {
+		latest = max(latest, handler.GetIndex())
+	}
+	schema, err := m.readSchema(ctx)
+	if err != nil {
+		return err
+	}
+	if schema.dirty {
+		return m.dirtyError(ctx, schema.version)
+	}
+	if schema.version < latest {
+		return errors.WithStack(&NotReadyError{Version: schema.version, Latest: latest})
+	}
+	return nil
+}
+
+// ReadyHandler 返回就绪探针的 http.Handler，就绪时返回 200，否则返回 503 及原因
+func ReadyHandler(m Migrate) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		err := m.Ready(r.Context())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("ok\n"))
+	})
+}
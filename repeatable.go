@@ -0,0 +1,101 @@
+package migrate
+
+import (
+	"context"
+	"sort"
+
+	"github.com/pkg/errors"
+)
+
+/*
+可重复执行的处理程序，在版本化的处理程序之后执行，以名称区分，校验和与上次成功执行的记录不同时重新执行
+*/
+
+const (
+	ErrDuplicateRepeatableFormat = "duplicate repeatable handler %s"
+)
+
+var (
+	ErrRepeatableNoName = errors.New("repeatable handler has no name")
+)
+
+// initRepeatables 返回全部可重复执行的处理程序，按名称排序，名称需唯一
+func (m *migrate) initRepeatables() ([]Handler, error) {
+	all, err := m.listHandlers()
+	if err != nil {
+		return nil, err
+	}
+	var repeatables []Handler
+	names := make(map[string]bool)
+	for _, handler := range all {
+		if !isRepeatable(handler) {
+			continue
+		}
+		name := nameOf(handler)
+		if name == "" {
+			return nil, ErrRepeatableNoName
+		}
+		if names[name] {
+			return nil, errors.Errorf(ErrDuplicateRepeatableFormat, name)
+		}
+		names[name] = true
+		repeatables = append(repeatables, handler)
+	}
+	sort.Slice(repeatables, func(i, j int) bool {
+		return nameOf(repeatables[i]) < nameOf(repeatables[j])
+	})
+	return repeatables, nil
+}
+
+// changedRepeatables 返回校验和与上次成功执行不同的可重复执行处理程序，按名称排序
+func (m *migrate) changedRepeatables(ctx context.Context) ([]Handler, error) {
+	repeatables, err := m.initRepeatables()
+	if err != nil {
+		return nil, err
+	}
+	repeatables = m.filterTags(repeatables)
+	if len(repeatables) == 0 {
+		return nil, nil
+	}
+	histories, err := m.loadHistory(ctx)
+	if err != nil {
+		return nil, err
+	}
+	checksums := make(map[string]string)
+	for _, h := range histories {
+		if h.direction == DirectionRepeat && h.success {
+			checksums[h.name] = h.checksum
+		}
+	}
+	var changed []Handler
+	for _, handler := range repeatables {
+		if checksum, ok := checksums[nameOf(handler)]; !ok || checksum != checksumOf(handler) {
+			changed = append(changed, handler)
+		}
+	}
+	return changed, nil
+}
+
+// repeat 执行校验和变更的可重复执行处理程序，version 为当前概要表 version
+func (m *migrate) repeat(ctx context.Context, version int) ([]HandlerResult, error) {
+	handlers, err := m.changedRepeatables(ctx)
+	if err != nil || len(handlers) == 0 {
+		return nil, err
+	}
+	if m.dryRun != nil {
+		return nil, m.printRepeat(handlers)
+	}
+	err = m.checkDestructive(handlers)
+	if err != nil {
+		return nil, err
+	}
+	var results []HandlerResult
+	for _, handler := range handlers {
+		duration, err := m.execute(ctx, handler, DirectionRepeat, version)
+		if err != nil {
+			return results, err
+		}
+		results = append(results, HandlerResult{Index: handler.GetIndex(), Name: nameOf(handler), Duration: duration})
+	}
+	return results, nil
+}
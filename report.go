@@ -0,0 +1,23 @@
+package migrate
+
+import "time"
+
+// Report 一次执行的结果，可序列化为 json 供部署工具读取
+type Report struct {
+	StartVersion int             `json:"start_version"`         // 执行前的 version
+	FinalVersion int             `json:"final_version"`         // 执行后的 version
+	Applied      []HandlerResult `json:"applied"`               // 执行成功的处理程序
+	Repeated     []HandlerResult `json:"repeated"`              // 执行成功的可重复执行处理程序
+	Skipped      int             `json:"skipped"`               // 已执行而跳过的处理程序数量
+	Deferred     []HandlerResult `json:"deferred,omitempty"`    // 维护窗口外推迟执行的处理程序
+	Duration     time.Duration   `json:"duration"`              // 总耗时
+	Shadow       *Report         `json:"shadow,omitempty"`      // 影子库的执行结果，未开启影子库时为空
+	ShadowDiff   string          `json:"shadow_diff,omitempty"` // 真实库执行前与影子库执行后的表结构差异，未开启 WithShadowDiff 或无差异时为空
+}
+
+// HandlerResult 单个处理程序的执行结果
+type HandlerResult struct {
+	Index    int           `json:"index"`
+	Name     string        `json:"name"`
+	Duration time.Duration `json:"duration"`
+}
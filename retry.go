@@ -0,0 +1,74 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"io"
+	"regexp"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+/*
+瞬时错误重试，处理程序因连接断开、死锁或锁等待超时失败时，按退避时间重新执行，重试耗尽后再标记 dirty；
+重新执行的是整个处理程序，不在事务中执行的 DDL 可能已部分生效，此类处理程序应保证可重复执行
+*/
+
+// TransientDialect 可识别瞬时错误的方言，未实现时按连接错误、MySQL 1213/1205 及 Postgres SQLSTATE 识别
+type TransientDialect interface {
+	Dialect
+	// IsTransient 判断错误是否可以重试
+	IsTransient(err error) bool
+}
+
+var (
+	// mysqlTransientPattern MySQL 死锁 1213、锁等待超时 1205 及连接失效
+	mysqlTransientPattern = regexp.MustCompile(`Error (1213|1205)\b|invalid connection`)
+	// postgresTransientStates Postgres 死锁、序列化失败及锁获取失败
+	postgresTransientStates = map[string]bool{"40P01": true, "40001": true, "55P03": true}
+)
+
+// WithRetry 处理程序因瞬时错误失败时最多执行 attempts 次，第 n 次重试前等待 backoff * 2^(n-1)；
+// 单事务模式下不重试
+func WithRetry(attempts int, backoff time.Duration) Option {
+	return func(m *migrate) {
+		m.retryAttempts = attempts
+		m.retryBackoff = backoff
+	}
+}
+
+// applyWithRetry 执行处理程序，瞬时错误时按退避时间重试
+func (m *migrate) applyWithRetry(ctx context.Context, handler Handler, direction string, version int) error {
+	backoff := m.retryBackoff
+	for attempt := 1; ; attempt++ {
+		err := m.apply(ctx, handler, direction, version)
+		if err == nil || attempt >= m.retryAttempts || m.tx != nil || !m.isTransient(err) {
+			return err
+		}
+		m.logger.Warn("handler retry", "index", handler.GetIndex(), "name", nameOf(handler), "direction", direction,
+			"attempt", attempt, "backoff", backoff, "error", err)
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+// isTransient 判断错误是否为可重试的瞬时错误
+func (m *migrate) isTransient(err error) bool {
+	if d, ok := m.dialect.(TransientDialect); ok {
+		return d.IsTransient(err)
+	}
+	if errors.Is(err, driver.ErrBadConn) || errors.Is(err, sql.ErrConnDone) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return true
+	}
+	var state interface{ SQLState() string }
+	if errors.As(err, &state) && postgresTransientStates[state.SQLState()] {
+		return true
+	}
+	return mysqlTransientPattern.MatchString(err.Error())
+}
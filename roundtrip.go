@@ -0,0 +1,149 @@
+package migrate
+
+import (
+	"context"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+/*
+往返测试，执行全部待执行的处理程序后逐个回滚再重新执行，校验每个回滚能否成功以及回滚后表结构是否与执行前一致，
+确保需要回滚时回滚路径可用；方言不支持导出表结构时仅校验回滚能否成功；会回滚库中的全部数据，需开启 WithAllowUnsafe
+*/
+
+func (m *migrate) RoundTrip(ctx context.Context) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if !m.allowUnsafe {
+		return ErrUnsafe
+	}
+	if m.dryRun != nil {
+		return m.operate(ctx, "migrate.RoundTrip", m.upAll)
+	}
+	return m.operate(ctx, "migrate.RoundTrip", m.roundTrip)
+}
+
+// roundTrip 记录每个版本的表结构，逐个执行后逐个回滚并比较，最后重新执行全部处理程序
+func (m *migrate) roundTrip(ctx context.Context) error {
+	handlers, schema, err := m.prepare(ctx)
+	if err != nil {
+		return err
+	}
+	applied, pending, err := m.split(ctx, handlers, schema.version)
+	if err != nil {
+		return err
+	}
+	// 1.逐个执行并记录执行后的表结构
+	snapshots := make(map[int]string)
+	err = m.snapshot(ctx, snapshots, schema.version)
+	if err != nil {
+		return err
+	}
+	err = m.upEach(ctx, pending, schema.version, snapshots, true)
+	if err != nil {
+		return err
+	}
+	// 2.逐个回滚，回滚后的表结构需与执行前一致
+	all := append(append([]Handler{}, applied...), pending...)
+	for i := len(all) - 1; i >= 0; i-- {
+		err = m.down(ctx, all[:i+1], 1)
+		if err != nil {
+			return errors.WithStack(&RoundTripError{Index: all[i].GetIndex(), Direction: DirectionDown, Err: err})
+		}
+		previous := 0
+		if i > 0 {
+			previous = all[i-1].GetIndex()
+		}
+		err = m.compareSnapshot(ctx, snapshots, previous, all[i].GetIndex(), DirectionDown)
+		if err != nil {
+			return err
+		}
+	}
+	// 3.重新逐个执行，表结构需与第一次执行后一致
+	return m.upEach(ctx, all, 0, snapshots, false)
+}
+
+// upEach 逐个执行处理程序，record 时记录执行后的表结构，否则与记录的表结构比较
+func (m *migrate) upEach(ctx context.Context, handlers []Handler, version int, snapshots map[int]string, record bool) error {
+	for _, handler := range handlers {
+		_, err := m.up(ctx, []Handler{handler}, version)
+		if err != nil {
+			return errors.WithStack(&RoundTripError{Index: handler.GetIndex(), Direction: DirectionUp, Err: err})
+		}
+		version = max(version, handler.GetIndex())
+		if record {
+			err = m.snapshot(ctx, snapshots, version)
+		} else {
+			err = m.compareSnapshot(ctx, snapshots, version, handler.GetIndex(), DirectionUp)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// snapshot 记录 version 对应的表结构，方言不支持导出时不记录
+func (m *migrate) snapshot(ctx context.Context, snapshots map[int]string, version int) error {
+	schema, err := m.dumpSchema(ctx)
+	if errors.Is(err, ErrDumpNotSupported) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	snapshots[version] = schema
+	return nil
+}
+
+// compareSnapshot 比较当前表结构与 version 记录的表结构，不一致时返回处理程序 index 的 RoundTripError
+func (m *migrate) compareSnapshot(ctx context.Context, snapshots map[int]string, version, index int, direction string) error {
+	expected, ok := snapshots[version]
+	if !ok {
+		return nil
+	}
+	current, err := m.dumpSchema(ctx)
+	if err != nil {
+		return err
+	}
+	residue := diffLines(expected, current)
+	if len(residue) > 0 {
+		return errors.WithStack(&RoundTripError{Index: index, Direction: direction, Residue: residue})
+	}
+	return nil
+}
+
+// diffLines 返回 current 中多出的行 (以 + 开头) 及缺少的行 (以 - 开头)，忽略行的顺序及空行
+func diffLines(expected, current string) []string {
+	counts := make(map[string]int)
+	for _, line := range nonEmptyLines(expected) {
+		counts[line]++
+	}
+	var added []string
+	for _, line := range nonEmptyLines(current) {
+		if counts[line] > 0 {
+			counts[line]--
+			continue
+		}
+		added = append(added, "+ "+line)
+	}
+	var missing []string
+	for _, line := range nonEmptyLines(expected) {
+		if counts[line] > 0 {
+			counts[line]--
+			missing = append(missing, "- "+line)
+		}
+	}
+	return append(added, missing...)
+}
+
+func nonEmptyLines(s string) []string {
+	var lines []string
+	for _, line := range strings.Split(s, "\n") {
+		if strings.TrimSpace(line) != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
@@ -0,0 +1,235 @@
+package migrate
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+/*
+表结构导出，执行成功后将当前库的表结构写入文件或 writer，使仓库中的 schema.sql 与迁移保持同步；
+MySQL 使用 SHOW CREATE TABLE，SQLite 读取 sqlite_master，Postgres 由系统表拼接建表语句；
+按名称排序且不包含概要表及历史表，便于比较差异
+*/
+
+var (
+	ErrDumpNotSupported = errors.New("dialect does not support schema dump")
+)
+
+// autoIncrementPattern MySQL 建表语句中随数据变化的自增值
+var autoIncrementPattern = regexp.MustCompile(` AUTO_INCREMENT=\d+`)
+
+// SchemaDialect 支持导出表结构的方言
+type SchemaDialect interface {
+	// DumpSchema 导出当前库的建表语句，不包含 exclude 中的表
+	DumpSchema(ctx context.Context, db DBTX, exclude ...string) (string, error)
+}
+
+func (f *formatDialect) DumpSchema(ctx context.Context, db DBTX, exclude ...string) (string, error) {
+	if f.dumpSchema == nil {
+		return "", ErrDumpNotSupported
+	}
+	excluded := make(map[string]bool)
+	for _, table := range exclude {
+		excluded[table] = true
+	}
+	statements, err := f.dumpSchema(ctx, db, excluded)
+	if err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	for _, statement := range statements {
+		b.WriteString(strings.TrimSuffix(strings.TrimSpace(statement), ";"))
+		b.WriteString(";\n\n")
+	}
+	return b.String(), nil
+}
+
+// WithSchemaDump 执行成功后将表结构写入 w
+func WithSchemaDump(w io.Writer) Option {
+	return func(m *migrate) {
+		m.schemaDump = func(schema string) error {
+			_, err := io.WriteString(w, schema)
+			return errors.WithStack(err)
+		}
+	}
+}
+
+// WithSchemaDumpFile 执行成功后将表结构写入文件 path，先写入临时文件再重命名，避免写入失败时破坏原文件
+func WithSchemaDumpFile(path string) Option {
+	return func(m *migrate) {
+		m.schemaDump = func(schema string) error {
+			tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+			if err != nil {
+				return errors.WithStack(err)
+			}
+			defer os.Remove(tmp.Name())
+			_, err = tmp.WriteString(schema)
+			if closeErr := tmp.Close(); err == nil {
+				err = closeErr
+			}
+			if err != nil {
+				return errors.WithStack(err)
+			}
+			return errors.WithStack(os.Rename(tmp.Name(), path))
+		}
+	}
+}
+
+func (m *migrate) DumpSchema(ctx context.Context, w io.Writer) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	err := m.waitForDB(ctx)
+	if err != nil {
+		return err
+	}
+	schema, err := m.dumpSchema(ctx)
+	if err != nil {
+		return err
+	}
+	_, err = io.WriteString(w, schema)
+	return errors.WithStack(err)
+}
+
+// dumpSchema 导出表结构，不包含概要表、历史表及执行状态表
+func (m *migrate) dumpSchema(ctx context.Context) (string, error) {
+	dialect, ok := m.dialect.(SchemaDialect)
+	if !ok {
+		return "", ErrDumpNotSupported
+	}
+	return dialect.DumpSchema(ctx, m.db, m.schemaTable, m.historyTable, m.runningTable)
+}
+
+// writeSchemaDump 执行成功后按配置导出表结构
+func (m *migrate) writeSchemaDump(ctx context.Context) error {
+	if m.schemaDump == nil || m.dryRun != nil {
+		return nil
+	}
+	schema, err := m.dumpSchema(ctx)
+	if err != nil {
+		return err
+	}
+	return m.schemaDump(schema)
+}
+
+// dumpMySQL 以 SHOW CREATE TABLE/VIEW 导出当前库的表及视图
+func dumpMySQL(ctx context.Context, db DBTX, exclude map[string]bool) ([]string, error) {
+	tables, err := queryPairs(ctx, db, "SELECT table_name, table_type FROM information_schema.tables WHERE table_schema = DATABASE() ORDER BY table_name")
+	if err != nil {
+		return nil, err
+	}
+	var statements []string
+	for _, table := range tables {
+		name, tableType := table[0], table[1]
+		if exclude[name] {
+			continue
+		}
+		var create, ignore string
+		if tableType == "VIEW" {
+			var charset, collation string
+			err = db.QueryRowContext(ctx, fmt.Sprintf("SHOW CREATE VIEW `%s`", name)).Scan(&ignore, &create, &charset, &collation)
+		} else {
+			err = db.QueryRowContext(ctx, fmt.Sprintf("SHOW CREATE TABLE `%s`", name)).Scan(&ignore, &create)
+		}
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		statements = append(statements, autoIncrementPattern.ReplaceAllString(create, ""))
+	}
+	return statements, nil
+}
+
+// dumpSQLite 读取 sqlite_master 中的建表、索引、视图及触发器语句，表在前
+func dumpSQLite(ctx context.Context, db DBTX, exclude map[string]bool) ([]string, error) {
+	objects, err := queryPairs(ctx, db, "SELECT tbl_name, sql FROM sqlite_master WHERE sql IS NOT NULL AND name NOT LIKE 'sqlite_%' ORDER BY type != 'table', type, name")
+	if err != nil {
+		return nil, err
+	}
+	var statements []string
+	for _, object := range objects {
+		if !exclude[object[0]] {
+			statements = append(statements, object[1])
+		}
+	}
+	return statements, nil
+}
+
+// dumpPostgres 由系统表拼接当前 schema 的建表语句，包含列、约束及索引
+func dumpPostgres(ctx context.Context, db DBTX, exclude map[string]bool) ([]string, error) {
+	tables, err := queryPairs(ctx, db, "SELECT table_name, table_type FROM information_schema.tables WHERE table_schema = current_schema() ORDER BY table_name")
+	if err != nil {
+		return nil, err
+	}
+	var statements []string
+	for _, table := range tables {
+		name, tableType := table[0], table[1]
+		if exclude[name] {
+			continue
+		}
+		if tableType == "VIEW" {
+			var definition string
+			err = db.QueryRowContext(ctx, "SELECT pg_get_viewdef(quote_ident($1)::regclass, true)", name).Scan(&definition)
+			if err != nil {
+				return nil, errors.WithStack(err)
+			}
+			statements = append(statements, fmt.Sprintf("CREATE VIEW %s AS\n%s", name, definition))
+			continue
+		}
+		// 1.列及默认值
+		columns, err := queryPairs(ctx, db, `SELECT a.attname, format_type(a.atttypid, a.atttypmod)
+			|| CASE WHEN a.attnotnull THEN ' NOT NULL' ELSE '' END
+			|| COALESCE(' DEFAULT ' || pg_get_expr(d.adbin, d.adrelid), '')
+			FROM pg_attribute a LEFT JOIN pg_attrdef d ON d.adrelid = a.attrelid AND d.adnum = a.attnum
+			WHERE a.attrelid = quote_ident($1)::regclass AND a.attnum > 0 AND NOT a.attisdropped ORDER BY a.attnum`, name)
+		if err != nil {
+			return nil, err
+		}
+		// 2.约束
+		constraints, err := queryPairs(ctx, db, "SELECT conname, pg_get_constraintdef(oid) FROM pg_constraint WHERE conrelid = quote_ident($1)::regclass ORDER BY conname", name)
+		if err != nil {
+			return nil, err
+		}
+		var lines []string
+		for _, column := range columns {
+			lines = append(lines, fmt.Sprintf("  %s %s", column[0], column[1]))
+		}
+		for _, constraint := range constraints {
+			lines = append(lines, fmt.Sprintf("  CONSTRAINT %s %s", constraint[0], constraint[1]))
+		}
+		statements = append(statements, fmt.Sprintf("CREATE TABLE %s (\n%s\n)", name, strings.Join(lines, ",\n")))
+		// 3.不属于约束的索引
+		indexes, err := queryPairs(ctx, db, `SELECT indexname, indexdef FROM pg_indexes WHERE schemaname = current_schema() AND tablename = $1
+			AND indexname NOT IN (SELECT conname FROM pg_constraint WHERE conrelid = quote_ident($1)::regclass) ORDER BY indexname`, name)
+		if err != nil {
+			return nil, err
+		}
+		for _, index := range indexes {
+			statements = append(statements, index[1])
+		}
+	}
+	return statements, nil
+}
+
+// queryPairs 查询两列字符串结果
+func queryPairs(ctx context.Context, db DBTX, query string, args ...any) ([][2]string, error) {
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	defer rows.Close()
+	var pairs [][2]string
+	for rows.Next() {
+		var pair [2]string
+		if err := rows.Scan(&pair[0], &pair[1]); err != nil {
+			return nil, errors.WithStack(err)
+		}
+		pairs = append(pairs, pair)
+	}
+	return pairs, errors.WithStack(rows.Err())
+}
@@ -0,0 +1,14 @@
+package migrate
+
+/*
+数据初始化，开发及测试环境的初始数据使用独立的概要表及历史表记录，不占用结构迁移的 version 序列
+*/
+
+const (
+	defaultSeedTableName = "seed_migrations"
+)
+
+// NewSeeder 创建数据初始化客户端，概要表默认为 seed_migrations，可通过 WithTableName 修改
+func NewSeeder(db DBTX, options ...Option) Migrate {
+	return New(db, append([]Option{WithTableName(defaultSeedTableName)}, options...)...)
+}
@@ -0,0 +1,65 @@
+package migrate
+
+import (
+	"context"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+/*
+影子库，执行前先在一次性的影子库上执行处理程序，影子库执行成功后才执行真实库，
+用于在 CI 中验证有风险的 DDL；配合 WithDryRun 时只在影子库执行，真实库仅输出执行计划；
+通过 WithShadowDiff 比较真实库与影子库执行后的表结构，差异即为待执行的处理程序对真实库的变更
+*/
+
+// WithShadow 指定影子库的迁移客户端，shadow 的数据库连接及处理程序均需指向影子库，影子库应为空库，
+// 从头执行全部处理程序；影子库执行失败时返回 ShadowError，不执行真实库
+func WithShadow(shadow Migrate) Option {
+	return func(m *migrate) {
+		m.shadow = shadow
+	}
+}
+
+// WithShadowDiff 影子库执行成功后，以 diff 比较真实库执行前及影子库执行后的 DumpSchema 表结构，
+// 结果写入 Report.ShadowDiff，如 concrete.Diff；真实库及影子库的方言均需支持 SchemaDialect
+func WithShadowDiff(diff func(current, shadow string) string) Option {
+	return func(m *migrate) {
+		m.shadowDiff = diff
+	}
+}
+
+// runShadow 在影子库上执行全部待执行的处理程序，结果写入 report.Shadow，开启 WithShadowDiff 时比较表结构
+func (m *migrate) runShadow(ctx context.Context, report *Report) error {
+	if m.shadow == nil {
+		return nil
+	}
+	m.logger.Info("migrate shadow start")
+	shadow, err := m.shadow.RunWithResult(ctx)
+	report.Shadow = shadow
+	if err != nil {
+		return errors.WithStack(&ShadowError{Err: err})
+	}
+	m.logger.Info("migrate shadow finish", "applied", len(shadow.Applied))
+	if m.shadowDiff == nil {
+		return nil
+	}
+	// 1.导出影子库执行后的表结构
+	var desired strings.Builder
+	err = m.shadow.DumpSchema(ctx, &desired)
+	if err != nil {
+		return errors.WithStack(&ShadowError{Err: err})
+	}
+	// 2.导出真实库执行前的表结构并比较
+	err = m.waitForDB(ctx)
+	if err != nil {
+		return err
+	}
+	current, err := m.dumpSchema(ctx)
+	if err != nil {
+		return err
+	}
+	report.ShadowDiff = m.shadowDiff(current, desired.String())
+	m.logger.Info("migrate shadow diff", "diff", report.ShadowDiff)
+	return nil
+}
@@ -0,0 +1,58 @@
+package azblob
+
+import (
+	"context"
+	"io"
+	"strings"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob"
+	"github.com/pkg/errors"
+
+	"powerlaw.ai/powerlib/migrate/concrete"
+)
+
+/*
+Azure Blob 来源，列出并读取容器 prefix 下的 sql 文件，不读取子目录
+*/
+
+type source struct {
+	client    *azblob.Client
+	container string
+	prefix    string
+}
+
+// New 从 container 的 prefix 目录读取文件，prefix 为空时读取根目录
+func New(client *azblob.Client, container, prefix string) concrete.Source {
+	if prefix != "" && !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+	return &source{client: client, container: container, prefix: prefix}
+}
+
+func (s *source) List() ([]concrete.File, error) {
+	var files []concrete.File
+	pager := s.client.NewListBlobsFlatPager(s.container, &azblob.ListBlobsFlatOptions{Prefix: &s.prefix})
+	for pager.More() {
+		page, err := pager.NextPage(context.Background())
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		for _, item := range page.Segment.BlobItems {
+			name := strings.TrimPrefix(*item.Name, s.prefix)
+			// 忽略子目录中的文件
+			if strings.Contains(name, "/") {
+				continue
+			}
+			files = append(files, concrete.File{Name: name})
+		}
+	}
+	return files, nil
+}
+
+func (s *source) Open(name string) (io.ReadCloser, error) {
+	resp, err := s.client.DownloadStream(context.Background(), s.container, s.prefix+name, nil)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return resp.Body, nil
+}
@@ -0,0 +1,55 @@
+package gcs
+
+import (
+	"context"
+	"io"
+	"strings"
+
+	"cloud.google.com/go/storage"
+	"github.com/pkg/errors"
+	"google.golang.org/api/iterator"
+
+	"powerlaw.ai/powerlib/migrate/concrete"
+)
+
+/*
+gcs 来源，列出并读取存储桶 prefix 下的 sql 文件，不读取子目录
+*/
+
+type source struct {
+	bucket *storage.BucketHandle
+	prefix string
+}
+
+// New 从 bucket 的 prefix 目录读取文件，prefix 为空时读取根目录
+func New(client *storage.Client, bucket, prefix string) concrete.Source {
+	if prefix != "" && !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+	return &source{bucket: client.Bucket(bucket), prefix: prefix}
+}
+
+func (s *source) List() ([]concrete.File, error) {
+	var files []concrete.File
+	it := s.bucket.Objects(context.Background(), &storage.Query{Prefix: s.prefix, Delimiter: "/"})
+	for {
+		attrs, err := it.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		// 子目录仅包含 Prefix
+		if attrs.Name == "" {
+			continue
+		}
+		files = append(files, concrete.File{Name: strings.TrimPrefix(attrs.Name, s.prefix)})
+	}
+	return files, nil
+}
+
+func (s *source) Open(name string) (io.ReadCloser, error) {
+	reader, err := s.bucket.Object(s.prefix + name).NewReader(context.Background())
+	return reader, errors.WithStack(err)
+}
@@ -0,0 +1,61 @@
+package s3
+
+import (
+	"context"
+	"io"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/pkg/errors"
+
+	"powerlaw.ai/powerlib/migrate/concrete"
+)
+
+/*
+s3 来源，列出并读取存储桶 prefix 下的 sql 文件，不读取子目录
+*/
+
+type source struct {
+	client *s3.Client
+	bucket string
+	prefix string
+}
+
+// New 从 bucket 的 prefix 目录读取文件，prefix 为空时读取根目录
+func New(client *s3.Client, bucket, prefix string) concrete.Source {
+	if prefix != "" && !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+	return &source{client: client, bucket: bucket, prefix: prefix}
+}
+
+func (s *source) List() ([]concrete.File, error) {
+	var files []concrete.File
+	paginator := s3.NewListObjectsV2Paginator(s.client, &s3.ListObjectsV2Input{
+		Bucket:    aws.String(s.bucket),
+		Prefix:    aws.String(s.prefix),
+		Delimiter: aws.String("/"),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(context.Background())
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		for _, object := range page.Contents {
+			files = append(files, concrete.File{Name: strings.TrimPrefix(aws.ToString(object.Key), s.prefix)})
+		}
+	}
+	return files, nil
+}
+
+func (s *source) Open(name string) (io.ReadCloser, error) {
+	output, err := s.client.GetObject(context.Background(), &s3.GetObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(s.prefix + name),
+	})
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return output.Body, nil
+}
@@ -0,0 +1,119 @@
+package sqlxmigrate
+
+import (
+	"context"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+
+	"powerlaw.ai/powerlib/migrate"
+)
+
+/*
+sqlx 处理程序，Go 数据迁移中直接使用 *sqlx.Tx 的命名参数及结构体扫描，无需手动包装 *sql.Tx；
+处理程序在执行器以 BeginTxx 开启的事务中执行，version 在事务提交后更新
+*/
+
+// Func 在事务中执行的 sqlx 处理函数，返回错误时事务回滚
+type Func func(ctx context.Context, tx *sqlx.Tx) error
+
+// Handler sqlx 处理程序
+type Handler struct {
+	index   int
+	name    string
+	timeout time.Duration
+	up      Func
+	down    Func // 回滚函数，为空时不支持回滚
+}
+
+// NewSqlxHandler 创建在事务中执行 f 的处理程序，需添加到 NewExecutor 中执行
+func NewSqlxHandler(index int, f Func) Handler {
+	return Handler{index: index, up: f}
+}
+
+// WithName 指定处理程序的名称，用于日志、历史表及状态输出
+func (h Handler) WithName(name string) Handler {
+	h.name = name
+	return h
+}
+
+// WithDown 设置回滚函数
+func (h Handler) WithDown(down Func) Handler {
+	h.down = down
+	return h
+}
+
+// WithTimeout 指定处理程序的执行超时时间
+func (h Handler) WithTimeout(timeout time.Duration) Handler {
+	h.timeout = timeout
+	return h
+}
+
+// executor sqlx 执行器
+type executor struct {
+	db       *sqlx.DB
+	handlers []Handler
+}
+
+// NewExecutor 创建在 db 上执行 handlers 的执行器，migrate.New 可使用 db.DB
+func NewExecutor(db *sqlx.DB, handlers ...Handler) migrate.Executor {
+	return &executor{db: db, handlers: handlers}
+}
+
+func (e *executor) ListHandlers() ([]migrate.Handler, error) {
+	handlers := make([]migrate.Handler, 0, len(e.handlers))
+	for idx := range e.handlers {
+		h := &handler{Handler: &e.handlers[idx], db: e.db}
+		if h.down != nil {
+			handlers = append(handlers, downHandler{handler: h})
+			continue
+		}
+		handlers = append(handlers, h)
+	}
+	return handlers, nil
+}
+
+// handler 绑定 *sqlx.DB 的处理程序
+type handler struct {
+	*Handler
+	db *sqlx.DB
+}
+
+func (h *handler) GetIndex() int {
+	return h.index
+}
+
+func (h *handler) GetName() string {
+	return h.name
+}
+
+func (h *handler) GetTimeout() time.Duration {
+	return h.timeout
+}
+
+func (h *handler) Exec(ctx context.Context) error {
+	return inTx(ctx, h.db, h.up)
+}
+
+type downHandler struct {
+	*handler
+}
+
+func (h downHandler) DownExec(ctx context.Context) error {
+	return inTx(ctx, h.db, h.down)
+}
+
+// inTx 开启事务执行 f，f 返回错误时回滚
+func inTx(ctx context.Context, db *sqlx.DB, f Func) error {
+	tx, err := db.BeginTxx(ctx, nil)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	err = f(ctx, tx)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	return errors.WithStack(tx.Commit())
+}
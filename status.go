@@ -0,0 +1,94 @@
+package migrate
+
+import "context"
+
+// HandlerState 处理程序执行状态
+type HandlerState string
+
+const (
+	StateApplied HandlerState = "applied" // 已执行
+	StatePending HandlerState = "pending" // 待执行
+	StateDirty   HandlerState = "dirty"   // 执行或回滚失败
+)
+
+// HandlerInfo 处理程序概要信息
+type HandlerInfo struct {
+	Index int
+	Name  string
+	State HandlerState
+}
+
+func (m *migrate) Status(ctx context.Context) ([]HandlerInfo, []HandlerInfo, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	err := m.waitForDB(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	handlers, schema, err := m.load(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	appliedHandlers, pendingHandlers, err := m.split(ctx, handlers, schema.version)
+	if err != nil {
+		return nil, nil, err
+	}
+	var applied, pending []HandlerInfo
+	for _, handler := range appliedHandlers {
+		info := HandlerInfo{Index: handler.GetIndex(), Name: nameOf(handler), State: StateApplied}
+		if info.Index == schema.version && schema.dirty {
+			// dirty 的处理程序需修复后重新执行，归入待执行列表
+			info.State = StateDirty
+			pending = append(pending, info)
+			continue
+		}
+		applied = append(applied, info)
+	}
+	for _, handler := range pendingHandlers {
+		info := HandlerInfo{Index: handler.GetIndex(), Name: nameOf(handler), State: StatePending}
+		if info.Index == schema.version && schema.dirty {
+			info.State = StateDirty
+		}
+		pending = append(pending, info)
+	}
+	return applied, pending, nil
+}
+
+func (m *migrate) Version(ctx context.Context) (int, bool, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	err := m.waitForDB(ctx)
+	if err != nil {
+		return 0, false, err
+	}
+	schema, err := m.initAndGetSchema(ctx)
+	if err != nil {
+		return 0, false, err
+	}
+	return schema.version, schema.dirty, nil
+}
+
+// peekVersion 只读查询概要表的 version 及 dirty，概要表不存在时 absent 为 true 且不创建
+func (m *migrate) peekVersion(ctx context.Context) (version int, dirty bool, absent bool, err error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	err = m.waitForDB(ctx)
+	if err != nil {
+		return 0, false, false, err
+	}
+	// 自定义存储未初始化时 GetVersion 返回 0
+	if store, ok := m.store.(*sqlSchemaStore); ok {
+		exists, err := store.exists(ctx)
+		if err != nil {
+			return 0, false, false, err
+		}
+		if !exists {
+			return 0, false, true, nil
+		}
+	}
+	schema, err := m.readSchema(ctx)
+	if err != nil {
+		return 0, false, false, err
+	}
+	return schema.version, schema.dirty, false, nil
+}
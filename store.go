@@ -0,0 +1,205 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/pkg/errors"
+)
+
+/*
+SchemaStore 版本存储，记录当前 version 及 dirty，默认存储于数据库的概要表；
+迁移 etcd、Elasticsearch 等非关系型系统时，可将版本存储于 etcd、Consul、DynamoDB 等外部系统
+*/
+
+var ErrStoreNotTx = errors.New("schema store does not support updating the version in a transaction")
+
+// deleteSchemaQuery 清空概要表，各数据库语法相同
+const deleteSchemaQuery = "DELETE FROM %s"
+
+// SchemaStore 版本存储，Lock 及 Unlock 用作迁移锁，WithLocker 优先
+type SchemaStore interface {
+	Locker
+	// Init 初始化存储，如建表及写入初始记录，每次执行前调用，需可重复调用
+	Init(ctx context.Context) error
+	// GetVersion 返回当前 version 及是否 dirty，未初始化时返回 0 及 false
+	GetVersion(ctx context.Context) (version int, dirty bool, err error)
+	// SetVersion 设置 version，不改变 dirty
+	SetVersion(ctx context.Context, version int) error
+	// SetDirty 设置 version 及 dirty
+	SetDirty(ctx context.Context, version int, dirty bool) error
+}
+
+// TxSchemaStore 可在处理程序的事务中更新 version 的存储，处理程序与 version 同时提交；
+// 未实现时在处理程序的事务提交后更新 version，WithSingleTransaction 需存储实现此接口
+type TxSchemaStore interface {
+	SchemaStore
+	// SetVersionTx 在事务中设置 version
+	SetVersionTx(ctx context.Context, tx *sql.Tx, version int) error
+}
+
+// WithSchemaStore 指定版本存储，默认使用数据库的概要表，未指定 WithLocker 时使用存储的锁；
+// 迁移非关系型系统时 db 可为空，此时不记录历史表且处理程序不在事务中执行
+func WithSchemaStore(store SchemaStore) Option {
+	return func(m *migrate) {
+		m.store = store
+	}
+}
+
+// WithSchemaRepair 概要表存在多条记录时自动修复，保留最小的 version，任一记录 dirty 时标记 dirty；
+// 默认返回 SchemaRowsError，修复后建议核对数据库状态，必要时调用 Force
+func WithSchemaRepair() Option {
+	return func(m *migrate) {
+		m.repairSchema = true
+	}
+}
+
+// sqlSchemaStore 基于概要表的版本存储
+type sqlSchemaStore struct {
+	Locker
+	db      DBTX
+	dialect Dialect
+	table   string       // 已按方言引用的表名
+	options TableOptions // 建表选项
+	repair  bool         // 存在多条记录时是否自动修复
+	logger  Logger       // 修复时输出告警
+}
+
+// schemaRow 概要表记录
+type schemaRow struct {
+	version int
+	dirty   bool
+}
+
+func (s *sqlSchemaStore) Init(ctx context.Context) error {
+	query := s.dialect.CreateTable(s.table)
+	if dialect, ok := s.dialect.(TableDialect); ok {
+		query = dialect.CreateTableWith(s.table, s.options)
+	}
+	_, err := s.db.ExecContext(ctx, query)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	rows, err := s.rows(ctx)
+	if err != nil {
+		return err
+	}
+	switch {
+	case len(rows) == 0:
+		_, err = s.db.ExecContext(ctx, s.dialect.Insert(s.table))
+		return errors.WithStack(err)
+	case len(rows) > 1 && s.repair:
+		return s.heal(ctx, rows)
+	case len(rows) > 1:
+		return errors.WithStack(&SchemaRowsError{Table: s.table, Rows: len(rows)})
+	}
+	return nil
+}
+
+// exists 只读判断概要表是否存在，查询失败而连接可用时视为不存在
+func (s *sqlSchemaStore) exists(ctx context.Context) (bool, error) {
+	rows, err := s.db.QueryContext(ctx, fmt.Sprintf(tableProbe, s.table))
+	if err == nil {
+		rows.Close()
+		return true, nil
+	}
+	pinger, ok := s.db.(Pinger)
+	if !ok {
+		return false, errors.WithStack(err)
+	}
+	pingErr := pinger.PingContext(ctx)
+	if pingErr != nil {
+		return false, errors.WithStack(pingErr)
+	}
+	return false, nil
+}
+
+func (s *sqlSchemaStore) GetVersion(ctx context.Context) (int, bool, error) {
+	rows, err := s.rows(ctx)
+	if err != nil {
+		return 0, false, err
+	}
+	switch len(rows) {
+	case 0:
+		return 0, false, nil
+	case 1:
+		return rows[0].version, rows[0].dirty, nil
+	}
+	return 0, false, errors.WithStack(&SchemaRowsError{Table: s.table, Rows: len(rows)})
+}
+
+// rows 读取概要表的全部记录，正常情况下至多一条
+func (s *sqlSchemaStore) rows(ctx context.Context) ([]schemaRow, error) {
+	rows, err := s.db.QueryContext(ctx, s.dialect.Select(s.table))
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	defer rows.Close()
+	var result []schemaRow
+	for rows.Next() {
+		var row schemaRow
+		err = rows.Scan(&row.version, &row.dirty)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		result = append(result, row)
+	}
+	return result, errors.WithStack(rows.Err())
+}
+
+// heal 在事务中将多条记录合并为一条，保留最小的 version，重复执行已执行的处理程序会失败并标记 dirty，
+// 而跳过未执行的处理程序无法被发现
+func (s *sqlSchemaStore) heal(ctx context.Context, rows []schemaRow) error {
+	kept := rows[0]
+	for _, row := range rows[1:] {
+		if row.version < kept.version {
+			kept.version = row.version
+		}
+		kept.dirty = kept.dirty || row.dirty
+	}
+	s.logger.Warn("repair schema table", "table", s.table, "rows", len(rows), "version", kept.version, "dirty", kept.dirty)
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	defer tx.Rollback()
+	// 1.清空概要表
+	_, err = tx.ExecContext(ctx, fmt.Sprintf(deleteSchemaQuery, s.table))
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	// 2.写入合并后的记录
+	_, err = tx.ExecContext(ctx, s.dialect.Insert(s.table))
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	_, err = tx.ExecContext(ctx, s.dialect.UpdateDirty(s.table), kept.version, kept.dirty)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	return errors.WithStack(tx.Commit())
+}
+
+func (s *sqlSchemaStore) SetVersion(ctx context.Context, version int) error {
+	_, err := s.db.ExecContext(ctx, s.dialect.Update(s.table), version)
+	return errors.WithStack(err)
+}
+
+func (s *sqlSchemaStore) SetDirty(ctx context.Context, version int, dirty bool) error {
+	_, err := s.db.ExecContext(ctx, s.dialect.UpdateDirty(s.table), version, dirty)
+	return errors.WithStack(err)
+}
+
+func (s *sqlSchemaStore) SetVersionTx(ctx context.Context, tx *sql.Tx, version int) error {
+	_, err := tx.ExecContext(ctx, s.dialect.Update(s.table), version)
+	return errors.WithStack(err)
+}
+
+// setVersion 处理程序执行成功后更新 version，tx 不为空且存储支持事务时在 tx 中更新
+func (m *migrate) setVersion(ctx context.Context, tx *sql.Tx, version int) error {
+	if store, ok := m.store.(TxSchemaStore); ok && tx != nil {
+		return store.SetVersionTx(ctx, tx, version)
+	}
+	return m.store.SetVersion(ctx, version)
+}
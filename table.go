@@ -0,0 +1,94 @@
+package migrate
+
+import (
+	"fmt"
+	"strings"
+)
+
+/*
+概要表及历史表的建表选项，如 MySQL 的存储引擎、字符集及排序规则，以及表所在的库或 schema；
+表名按方言引用，避免与关键字冲突，表名中的 . 视为库名分隔符
+*/
+
+const (
+	defaultMySQLEngine = "InnoDB"
+	tableProbe         = "SELECT * FROM %s WHERE 1 = 0" // 查询表的字段而不读取记录，表不存在时失败
+)
+
+// TableOptions 概要表及历史表的建表选项，不支持的选项被方言忽略
+type TableOptions struct {
+	Schema    string // 表所在的库或 schema，为空时使用连接的默认库
+	Engine    string // 存储引擎，仅 MySQL，默认为 InnoDB
+	Charset   string // 字符集，仅 MySQL，为空时使用库的默认字符集
+	Collation string // 排序规则，仅 MySQL，为空时使用字符集的默认排序规则
+}
+
+// TableDialect 支持建表选项及标识符引用的方言
+type TableDialect interface {
+	// QuoteIdentifier 引用标识符
+	QuoteIdentifier(name string) string
+	// CreateTableWith 以 options 创建概要表
+	CreateTableWith(table string, options TableOptions) string
+	// CreateHistoryTableWith 以 options 创建历史表，不支持历史表时返回空
+	CreateHistoryTableWith(table string, options TableOptions) string
+}
+
+// WithTableOptions 指定概要表及历史表的建表选项，仅在表不存在时生效
+func WithTableOptions(options TableOptions) Option {
+	return func(m *migrate) {
+		m.tableOptions = options
+	}
+}
+
+func (f *formatDialect) QuoteIdentifier(name string) string {
+	if f.quote == "" {
+		return name
+	}
+	return f.quote + strings.ReplaceAll(name, f.quote, f.quote+f.quote) + f.quote
+}
+
+func (f *formatDialect) CreateTableWith(table string, options TableOptions) string {
+	return fmt.Sprintf(f.createTable, table) + f.tableSuffix(options)
+}
+
+func (f *formatDialect) CreateHistoryTableWith(table string, options TableOptions) string {
+	if f.createHistory == "" {
+		return ""
+	}
+	return fmt.Sprintf(f.createHistory, table) + f.tableSuffix(options)
+}
+
+// tableSuffix 返回追加到建表语句末尾的表选项
+func (f *formatDialect) tableSuffix(options TableOptions) string {
+	if f.name != "mysql" {
+		return ""
+	}
+	engine := options.Engine
+	if engine == "" {
+		engine = defaultMySQLEngine
+	}
+	suffix := " ENGINE=" + engine
+	if options.Charset != "" {
+		suffix += " DEFAULT CHARSET=" + options.Charset
+	}
+	if options.Collation != "" {
+		suffix += " COLLATE=" + options.Collation
+	}
+	return suffix + ";"
+}
+
+// qualify 返回语句中使用的表名，方言支持时按方言引用并加上 schema 前缀
+func (m *migrate) qualify(table string) string {
+	dialect, ok := m.dialect.(TableDialect)
+	if !ok {
+		return table
+	}
+	parts := strings.Split(table, ".")
+	if m.tableOptions.Schema != "" {
+		parts = append([]string{m.tableOptions.Schema}, parts...)
+	}
+	for i, part := range parts {
+		parts[i] = dialect.QuoteIdentifier(part)
+	}
+	return strings.Join(parts, ".")
+}
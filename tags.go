@@ -0,0 +1,41 @@
+package migrate
+
+/*
+标签过滤，处理程序可通过标签限定执行环境，如仅在开发环境执行的种子数据、仅在维护窗口执行的大索引构建；
+未设置标签的处理程序总是执行，设置标签的处理程序仅在 WithTags 包含其任一标签时执行，否则视为不存在
+*/
+
+// WithTags 指定启用的标签，设置标签的处理程序仅在包含其任一标签时执行；
+// 被过滤的处理程序在其他环境执行后，低于 version 的处理程序需配合 WithAllowOutOfOrder 执行
+func WithTags(tags ...string) Option {
+	return func(m *migrate) {
+		m.tags = tags
+	}
+}
+
+// matchTags 判断处理程序的标签是否匹配启用的标签
+func (m *migrate) matchTags(handler Handler) bool {
+	tags := tagsOf(handler)
+	if len(tags) == 0 {
+		return true
+	}
+	for _, tag := range tags {
+		for _, enabled := range m.tags {
+			if tag == enabled {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// filterTags 返回标签匹配的处理程序
+func (m *migrate) filterTags(handlers []Handler) []Handler {
+	var matched []Handler
+	for _, handler := range handlers {
+		if m.matchTags(handler) {
+			matched = append(matched, handler)
+		}
+	}
+	return matched
+}
@@ -0,0 +1,147 @@
+package migrate
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+/*
+多租户迁移，每次执行时通过 TenantProvider 列出租户库并执行待执行的处理程序，新增的租户在下一次执行时迁移；
+可将每个租户的 version 及执行结果记录到控制库的租户状态表，便于查询迁移进度及失败的租户
+*/
+
+const defaultTenantTableName = "tenant_migrations"
+
+var ErrTenantNotSupported = errors.New("dialect does not support tenant status table")
+
+// TenantProvider 运行时列出租户库，Target.Name 为租户标识；
+// 每租户一个 schema 时，可为每个租户打开 search_path 指向其 schema 的连接
+type TenantProvider func(ctx context.Context) ([]Target, error)
+
+// TenantDialect 支持租户状态表的方言
+type TenantDialect interface {
+	// CreateTenantTable 以 options 创建租户状态表
+	CreateTenantTable(table string, options TableOptions) string
+	// DeleteTenant 删除租户状态，参数为 tenant
+	DeleteTenant(table string) string
+	// InsertTenant 插入租户状态，参数为 tenant、version、dirty、error_message、updated_at
+	InsertTenant(table string) string
+	// SelectTenants 按租户查询状态，字段顺序同 InsertTenant
+	SelectTenants(table string) string
+}
+
+func (f *formatDialect) CreateTenantTable(table string, options TableOptions) string {
+	if f.createTenant == "" {
+		return ""
+	}
+	return fmt.Sprintf(f.createTenant, table) + f.tableSuffix(options)
+}
+
+func (f *formatDialect) DeleteTenant(table string) string {
+	return fmt.Sprintf(f.deleteTenant, table)
+}
+
+func (f *formatDialect) InsertTenant(table string) string {
+	return fmt.Sprintf(f.insertTenant, table)
+}
+
+func (f *formatDialect) SelectTenants(table string) string {
+	return fmt.Sprintf(f.selectTenants, table)
+}
+
+// TenantStatus 租户状态表中的一条记录
+type TenantStatus struct {
+	Tenant    string
+	Version   int
+	Dirty     bool
+	Error     string // 最近一次执行的失败原因，成功时为空
+	UpdatedAt time.Time
+}
+
+// tenantStore 租户状态表
+type tenantStore struct {
+	db      DBTX
+	dialect TenantDialect
+	table   string
+	options TableOptions // 建表选项
+}
+
+// NewTenantMigrate 创建多租户迁移客户端，每次执行时调用 provider 获取租户列表
+func NewTenantMigrate(provider TenantProvider, factory func(target Target) Migrate, options ...MultiOption) *MultiMigrate {
+	return newMultiMigrate(provider, factory, options...)
+}
+
+// WithTenantStatus 将每个租户执行后的 version 及失败原因记录到 db 的租户状态表，table 为空时使用 tenant_migrations
+func WithTenantStatus(db DBTX, dialect Dialect, table string) MultiOption {
+	return func(mm *MultiMigrate) {
+		if table == "" {
+			table = defaultTenantTableName
+		}
+		tenantDialect, _ := dialect.(TenantDialect)
+		mm.store = &tenantStore{db: db, dialect: tenantDialect, table: table}
+	}
+}
+
+// WithTenantTableOptions 指定租户状态表的建表选项，仅在表不存在时生效
+func WithTenantTableOptions(options TableOptions) MultiOption {
+	return func(mm *MultiMigrate) {
+		mm.tenantOptions = options
+	}
+}
+
+// TenantStatus 返回租户状态表中的全部记录，未配置 WithTenantStatus 时返回空
+func (mm *MultiMigrate) TenantStatus(ctx context.Context) ([]TenantStatus, error) {
+	if mm.store == nil {
+		return nil, nil
+	}
+	err := mm.store.init(ctx)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := mm.store.db.QueryContext(ctx, mm.store.dialect.SelectTenants(mm.store.table))
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	defer rows.Close()
+	var statuses []TenantStatus
+	for rows.Next() {
+		var status TenantStatus
+		err = rows.Scan(&status.Tenant, &status.Version, &status.Dirty, &status.Error, (*timeScanner)(&status.UpdatedAt))
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses, errors.WithStack(rows.Err())
+}
+
+// init 创建租户状态表
+func (s *tenantStore) init(ctx context.Context) error {
+	if s.dialect == nil || s.dialect.CreateTenantTable(s.table, s.options) == "" {
+		return ErrTenantNotSupported
+	}
+	_, err := s.db.ExecContext(ctx, s.dialect.CreateTenantTable(s.table, s.options))
+	return errors.WithStack(err)
+}
+
+// record 覆盖写入租户状态
+func (s *tenantStore) record(ctx context.Context, result TargetResult) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	_, err = tx.ExecContext(ctx, s.dialect.DeleteTenant(s.table), result.Name)
+	if err != nil {
+		tx.Rollback()
+		return errors.WithStack(err)
+	}
+	_, err = tx.ExecContext(ctx, s.dialect.InsertTenant(s.table), result.Name, result.Version, result.Dirty, result.Error, time.Now().UTC())
+	if err != nil {
+		tx.Rollback()
+		return errors.WithStack(err)
+	}
+	return errors.WithStack(tx.Commit())
+}
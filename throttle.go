@@ -0,0 +1,103 @@
+package migrate
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+/*
+限流，长时间运行的数据迁移在业务高峰期执行时，限制语句的执行速率，避免占满主库资源；
+限流器通过 ctx 传递给处理程序，由处理程序在每条语句或每批数据前调用 Throttle
+*/
+
+// Throttler 限流器，Wait 阻塞直至允许执行下一条语句，可基于速率、复制延迟或业务时段实现
+type Throttler interface {
+	Wait(ctx context.Context) error
+}
+
+// ThrottledHandler 指定限流器的处理程序，优先于 WithThrottle 的配置，返回空时使用 WithThrottle 的配置
+type ThrottledHandler interface {
+	Handler
+	GetThrottler() Throttler
+}
+
+type throttlerKey struct{}
+
+// WithThrottle 限制处理程序每秒最多执行 maxStatementsPerSecond 条语句
+func WithThrottle(maxStatementsPerSecond float64) Option {
+	return func(m *migrate) {
+		m.throttler = NewRateThrottler(maxStatementsPerSecond)
+	}
+}
+
+// WithThrottler 使用自定义的限流器，如复制延迟超过阈值时等待
+func WithThrottler(throttler Throttler) Option {
+	return func(m *migrate) {
+		m.throttler = throttler
+	}
+}
+
+// Throttle 等待 ctx 中的限流器允许执行下一条语句，未配置限流时立即返回；
+// 自定义处理程序应在每条语句或每批数据前调用
+func Throttle(ctx context.Context) error {
+	throttler, ok := ctx.Value(throttlerKey{}).(Throttler)
+	if !ok {
+		return nil
+	}
+	return throttler.Wait(ctx)
+}
+
+// throttleContext 将处理程序的限流器放入 ctx
+func (m *migrate) throttleContext(ctx context.Context, handler Handler) context.Context {
+	throttler := m.throttler
+	if h, ok := handler.(ThrottledHandler); ok && h.GetThrottler() != nil {
+		throttler = h.GetThrottler()
+	}
+	if throttler == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, throttlerKey{}, throttler)
+}
+
+// rateThrottler 按固定间隔放行的限流器
+type rateThrottler struct {
+	mu       sync.Mutex
+	interval time.Duration
+	next     time.Time // 下一条语句最早的执行时间
+}
+
+// NewRateThrottler 创建每秒最多放行 perSecond 次的限流器，perSecond 不大于 0 时不限流
+func NewRateThrottler(perSecond float64) Throttler {
+	if perSecond <= 0 {
+		return &rateThrottler{}
+	}
+	return &rateThrottler{interval: time.Duration(float64(time.Second) / perSecond)}
+}
+
+func (r *rateThrottler) Wait(ctx context.Context) error {
+	if r.interval <= 0 {
+		return nil
+	}
+	r.mu.Lock()
+	now := time.Now()
+	if r.next.Before(now) {
+		r.next = now
+	}
+	wait := r.next.Sub(now)
+	r.next = r.next.Add(r.interval)
+	r.mu.Unlock()
+	if wait <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return errors.WithStack(ctx.Err())
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,62 @@
+package migrate
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+/*
+OpenTelemetry 链路追踪，每次操作一个 span，每个处理程序一个子 span
+*/
+
+const (
+	tracerName = "powerlaw.ai/powerlib/migrate"
+
+	attrIndex     = attribute.Key("migrate.index")
+	attrName      = attribute.Key("migrate.name")
+	attrDirection = attribute.Key("migrate.direction")
+	attrSQLBytes  = attribute.Key("migrate.sql_bytes")
+)
+
+// operate 在 span 中等待数据库就绪后持有迁移锁执行 f
+func (m *migrate) operate(ctx context.Context, name string, f func(ctx context.Context) error) error {
+	ctx, span := m.tracer.Start(ctx, name)
+	err := m.waitForDB(ctx)
+	if err == nil {
+		err = m.withLock(ctx, func() error {
+			return f(ctx)
+		})
+	}
+	endSpan(span, err)
+	return err
+}
+
+// startHandlerSpan 为处理程序创建子 span
+func (m *migrate) startHandlerSpan(ctx context.Context, handler Handler, direction string) (context.Context, trace.Span) {
+	attrs := []attribute.KeyValue{
+		attrIndex.Int(handler.GetIndex()),
+		attrName.String(nameOf(handler)),
+		attrDirection.String(direction),
+	}
+	if h, ok := handler.(QuerySizer); ok {
+		attrs = append(attrs, attrSQLBytes.Int(h.GetQuerySize(direction)))
+	} else if h, ok := handler.(QueryHandler); ok && direction == DirectionUp {
+		attrs = append(attrs, attrSQLBytes.Int(len(h.GetQuery())))
+	}
+	if h, ok := handler.(DownQueryHandler); ok && direction == DirectionDown {
+		attrs = append(attrs, attrSQLBytes.Int(len(h.GetDownQuery())))
+	}
+	return m.tracer.Start(ctx, "migrate.handler", trace.WithAttributes(attrs...))
+}
+
+// endSpan 记录错误并结束 span
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
@@ -0,0 +1,70 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/pkg/errors"
+)
+
+/*
+单事务模式，所有待执行的处理程序及 version、历史记录在同一事务中执行，
+任一处理程序失败时全部回滚，数据库保持在执行前的版本；需数据库支持事务性 DDL，如 Postgres、SQLite
+*/
+
+const (
+	ErrNotTxHandlerFormat = "handler %d does not support transaction"
+)
+
+// execer 可执行 sql 语句的 DBTX 或 *sql.Tx
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+// execer 返回单事务模式中的事务，不在单事务中时返回 db
+func (m *migrate) execer() execer {
+	if m.tx != nil {
+		return m.tx
+	}
+	return m.db
+}
+
+// beginSingleTx 校验处理程序均支持事务后开启事务
+func (m *migrate) beginSingleTx(ctx context.Context, handlers []Handler) error {
+	if m.external {
+		return nil
+	}
+	if _, ok := m.store.(TxSchemaStore); !ok || m.db == nil {
+		return ErrStoreNotTx
+	}
+	for _, handler := range handlers {
+		if _, execTx := handlerFuncs(handler, DirectionUp); execTx == nil {
+			return errors.Errorf(ErrNotTxHandlerFormat, handler.GetIndex())
+		}
+	}
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	m.tx = tx
+	return nil
+}
+
+// commitSingleTx 提交事务
+func (m *migrate) commitSingleTx() error {
+	if m.tx == nil || m.external {
+		return nil
+	}
+	err := m.tx.Commit()
+	m.tx = nil
+	return errors.WithStack(err)
+}
+
+// rollbackSingleTx 回滚事务，用于处理程序失败时撤销全部变更
+func (m *migrate) rollbackSingleTx() {
+	if m.tx == nil || m.external {
+		return
+	}
+	m.tx.Rollback()
+	m.tx = nil
+}
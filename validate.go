@@ -0,0 +1,50 @@
+package migrate
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+)
+
+/*
+校验，不连接数据库，检查处理程序索引是否重复或不连续、可重复执行的处理程序名称是否唯一，
+并由处理程序自行校验内容，如解析 sql 语法，用于在合并请求的 CI 中提前发现错误
+*/
+
+// ValidatableHandler 可校验内容的处理程序
+type ValidatableHandler interface {
+	Handler
+	Validate() error
+}
+
+func (m *migrate) Validate(ctx context.Context) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	// 1.校验索引及可重复执行的处理程序名称
+	handlers, err := m.initHandlers()
+	if err != nil {
+		return err
+	}
+	repeatables, err := m.initRepeatables()
+	if err != nil {
+		return err
+	}
+	// 2.校验全部处理程序的内容，汇总所有错误
+	var errs []error
+	for _, handler := range append(handlers, repeatables...) {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		h, ok := handler.(ValidatableHandler)
+		if !ok {
+			continue
+		}
+		if err := h.Validate(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) > 0 {
+		return errors.WithStack(&ValidationError{Errors: errs})
+	}
+	return nil
+}
@@ -0,0 +1,104 @@
+package migrate
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+/*
+等待数据库就绪，容器编排中服务可能先于数据库启动，执行前以指数退避 ping 数据库直到成功或 ctx 结束；
+等待迁移完成，多副本部署时仅主实例执行迁移，其他实例轮询概要表直至达到指定版本
+*/
+
+const defaultPollInterval = time.Second
+
+var ErrDBUnavailable = errors.New("database unavailable")
+
+// WithWaitForDB 执行及查询前 ping 数据库直到成功，首次失败后等待 backoff，之后每次翻倍且不超过 maxBackoff；
+// 等待时间由 ctx 及 WithWaitTimeout 限制，结束时返回包含最后一次 ping 错误的 ErrDBUnavailable
+func WithWaitForDB(backoff, maxBackoff time.Duration) Option {
+	return func(m *migrate) {
+		m.waitBackoff = backoff
+		m.waitMaxBackoff = maxBackoff
+	}
+}
+
+// WithWaitTimeout 指定 WithWaitForDB 等待数据库就绪的最长时间，0 表示仅由 ctx 限制
+func WithWaitTimeout(timeout time.Duration) Option {
+	return func(m *migrate) {
+		m.waitTimeout = timeout
+	}
+}
+
+// waitForDB 等待数据库就绪，未开启时直接返回
+func (m *migrate) waitForDB(ctx context.Context) error {
+	pinger, ok := m.db.(Pinger)
+	if m.waitBackoff <= 0 || !ok {
+		return nil
+	}
+	if m.waitTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, m.waitTimeout)
+		defer cancel()
+	}
+	start := time.Now()
+	backoff := m.waitBackoff
+	for attempt := 1; ; attempt++ {
+		err := pinger.PingContext(ctx)
+		if err == nil {
+			return nil
+		}
+		m.logger.Warn("wait for database", "attempt", attempt, "backoff", backoff, "error", err)
+		select {
+		case <-ctx.Done():
+			return errors.Wrapf(ErrDBUnavailable, "waited %s, last error %v", time.Since(start).Round(time.Millisecond), err)
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, max(m.waitMaxBackoff, m.waitBackoff))
+	}
+}
+
+// WithPollInterval 配置 WaitForVersion 轮询概要表的间隔，默认为 1 秒
+func WithPollInterval(interval time.Duration) Option {
+	return func(m *migrate) {
+		m.pollInterval = interval
+	}
+}
+
+func (m *migrate) WaitForVersion(ctx context.Context, version int) error {
+	interval := m.pollInterval
+	if interval <= 0 {
+		interval = defaultPollInterval
+	}
+	for {
+		// 不加锁且不创建概要表，主实例尚未建表时查询失败，继续等待
+		schema, err := m.readSchema(ctx)
+		switch {
+		case err != nil:
+			m.logger.Warn("wait for version", "version", version, "error", err)
+		case schema.dirty:
+			return m.dirtyError(ctx, schema.version)
+		case schema.version >= version:
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			if err == nil {
+				err = errors.Errorf("current version is %d", schema.version)
+			}
+			return errors.WithMessagef(err, "wait for version %d: %v", version, ctx.Err())
+		case <-time.After(interval):
+		}
+	}
+}
+
+// readSchema 只读查询版本存储，未初始化时返回 version 0
+func (m *migrate) readSchema(ctx context.Context) (*schema, error) {
+	version, dirty, err := m.store.GetVersion(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &schema{version: version, dirty: dirty}, nil
+}
@@ -0,0 +1,123 @@
+package migrate
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/robfig/cron/v3"
+)
+
+/*
+维护窗口，标记为 heavy 等标签的处理程序仅在窗口内执行，如大表的索引构建及数据回填；
+窗口由 cron 表达式指定开始时间及持续时间，窗口外遇到此类处理程序时等待窗口开始，或停止执行并在结果中报告推迟的处理程序
+*/
+
+// defaultWindowTag 未指定标签时受维护窗口限制的标签
+const defaultWindowTag = "heavy"
+
+// WindowMode 窗口外遇到受限处理程序时的行为
+type WindowMode int
+
+const (
+	// WindowWait 等待窗口开始后执行
+	WindowWait WindowMode = iota
+	// WindowSkip 停止执行，受限处理程序及其后的处理程序推迟到下次执行，见 Report.Deferred
+	WindowSkip
+)
+
+// Window 维护窗口
+type Window struct {
+	schedule cron.Schedule
+	duration time.Duration
+}
+
+// ParseWindow 解析维护窗口，spec 为窗口开始时间的 cron 表达式，如 "0 2 * * *" 或 "CRON_TZ=Asia/Shanghai 0 2 * * 6"，
+// duration 为窗口持续时间
+func ParseWindow(spec string, duration time.Duration) (*Window, error) {
+	schedule, err := cron.ParseStandard(spec)
+	if err != nil {
+		return nil, errors.Wrap(err, "parse window")
+	}
+	if duration <= 0 {
+		return nil, errors.Errorf("window duration must be positive, got %s", duration)
+	}
+	return &Window{schedule: schedule, duration: duration}, nil
+}
+
+// Contains 判断 t 是否在窗口内，即 (t-duration, t] 内存在窗口开始时间
+func (w *Window) Contains(t time.Time) bool {
+	return !w.schedule.Next(t.Add(-w.duration)).After(t)
+}
+
+// Next 返回 t 之后下一个窗口的开始时间，t 在窗口内时返回 t
+func (w *Window) Next(t time.Time) time.Time {
+	if w.Contains(t) {
+		return t
+	}
+	return w.schedule.Next(t)
+}
+
+// WithMaintenanceWindow 带有 tags 中任一标签的处理程序仅在 window 内执行，tags 为空时为 heavy；
+// 处理程序仍需通过 WithTags 启用
+func WithMaintenanceWindow(window *Window, mode WindowMode, tags ...string) Option {
+	if len(tags) == 0 {
+		tags = []string{defaultWindowTag}
+	}
+	return func(m *migrate) {
+		m.window = window
+		m.windowMode = mode
+		m.windowTags = tags
+	}
+}
+
+// inWindowScope 判断处理程序是否受维护窗口限制
+func (m *migrate) inWindowScope(handler Handler) bool {
+	if m.window == nil {
+		return false
+	}
+	for _, tag := range tagsOf(handler) {
+		for _, windowTag := range m.windowTags {
+			if tag == windowTag {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// deferWindow WindowSkip 模式下，窗口外时返回首个受限处理程序之前的处理程序及被推迟的处理程序
+func (m *migrate) deferWindow(handlers []Handler) ([]Handler, []Handler) {
+	if m.windowMode != WindowSkip || m.window == nil || m.window.Contains(time.Now()) {
+		return handlers, nil
+	}
+	for i, handler := range handlers {
+		if m.inWindowScope(handler) {
+			m.logger.Warn("handler deferred to maintenance window", "index", handler.GetIndex(), "name", nameOf(handler),
+				"deferred", len(handlers)-i, "next", m.window.Next(time.Now()))
+			return handlers[:i], handlers[i:]
+		}
+	}
+	return handlers, nil
+}
+
+// waitWindow WindowWait 模式下，受限处理程序等待窗口开始
+func (m *migrate) waitWindow(ctx context.Context, handler Handler) error {
+	if m.windowMode != WindowWait || !m.inWindowScope(handler) {
+		return nil
+	}
+	now := time.Now()
+	next := m.window.Next(now)
+	if !next.After(now) {
+		return nil
+	}
+	m.logger.Info("wait for maintenance window", "index", handler.GetIndex(), "name", nameOf(handler), "next", next)
+	timer := time.NewTimer(next.Sub(now))
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return errors.WithMessagef(ctx.Err(), "wait for maintenance window at %s", next.Format(time.RFC3339))
+	case <-timer.C:
+		return nil
+	}
+}